@@ -0,0 +1,136 @@
+package acme
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"slices"
+	"time"
+
+	"github.com/rs/zerolog/log"
+	xacme "golang.org/x/crypto/acme"
+	"golang.org/x/crypto/acme/autocert"
+)
+
+// Challenge types the gateway can answer itself.
+const (
+	ChallengeHTTP01    = "http-01"
+	ChallengeTLSALPN01 = "tls-alpn-01"
+)
+
+// ALPNProto is the protocol a listener must advertise for TLS-ALPN-01.
+const ALPNProto = xacme.ALPNProto
+
+type Config struct {
+	Hosts        []string
+	Email        string
+	DirectoryURL string
+	CAFile       string
+	AcceptTOS    bool
+	Challenges   []string
+	RenewBefore  time.Duration
+	Store        Store
+}
+
+// Manager obtains and renews certificates for a fixed set of hostnames.
+type Manager struct {
+	cfg      Config
+	autocert *autocert.Manager
+}
+
+func New(cfg Config) (*Manager, error) {
+	if len(cfg.Hosts) == 0 {
+		return nil, errors.New("acme requires at least one host")
+	}
+	if !cfg.AcceptTOS {
+		return nil, errors.New("acme requires accepting the CA terms of service")
+	}
+	if cfg.Store == nil {
+		return nil, errors.New("acme requires a certificate store")
+	}
+	if len(cfg.Challenges) == 0 {
+		cfg.Challenges = []string{ChallengeHTTP01, ChallengeTLSALPN01}
+	}
+	for _, c := range cfg.Challenges {
+		if c != ChallengeHTTP01 && c != ChallengeTLSALPN01 {
+			return nil, fmt.Errorf("unsupported acme challenge: %s", c)
+		}
+	}
+	// autocert offers no way to skip TLS-ALPN-01: it is always attempted
+	// first, so an order without it would burn a failed authorization
+	// before falling back to HTTP-01.
+	if !slices.Contains(cfg.Challenges, ChallengeTLSALPN01) {
+		return nil, errors.New("acme challenges must include tls-alpn-01")
+	}
+
+	client := &xacme.Client{DirectoryURL: cfg.DirectoryURL}
+	if client.DirectoryURL == "" {
+		client.DirectoryURL = autocert.DefaultACMEDirectory
+	}
+
+	// A private CA file lets the gateway talk to a local test CA (such as
+	// Pebble) whose directory is served with a self-signed certificate.
+	if cfg.CAFile != "" {
+		data, err := os.ReadFile(cfg.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read acme CA file: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(data) {
+			return nil, fmt.Errorf("no certificates found in acme CA file %s", cfg.CAFile)
+		}
+		transport := http.DefaultTransport.(*http.Transport).Clone()
+		transport.TLSClientConfig = &tls.Config{RootCAs: pool}
+		client.HTTPClient = &http.Client{Transport: transport}
+	}
+
+	m := &autocert.Manager{
+		Prompt:      autocert.AcceptTOS,
+		Cache:       cfg.Store,
+		HostPolicy:  autocert.HostWhitelist(cfg.Hosts...),
+		RenewBefore: cfg.RenewBefore,
+		Client:      client,
+		Email:       cfg.Email,
+	}
+
+	log.Info().
+		Strs("hosts", cfg.Hosts).
+		Str("directory", client.DirectoryURL).
+		Strs("challenges", cfg.Challenges).
+		Msg("ACME enabled")
+
+	return &Manager{cfg: cfg, autocert: m}, nil
+}
+
+// GetCertificate returns a certificate for the handshake, obtaining or
+// renewing it if needed. It also answers TLS-ALPN-01 challenge handshakes.
+func (m *Manager) GetCertificate(hello *tls.ClientHelloInfo) (*tls.Certificate, error) {
+	return m.autocert.GetCertificate(hello)
+}
+
+// Handles reports whether the handshake is a TLS-ALPN-01 challenge, which
+// only counts when that challenge is enabled. Other handshakes for managed
+// hostnames reach GetCertificate when no configured certificate covers them.
+func (m *Manager) Handles(hello *tls.ClientHelloInfo) bool {
+	return m.TLSALPNChallenge() && slices.Contains(hello.SupportedProtos, ALPNProto)
+}
+
+// HTTPChallenge reports whether HTTP-01 challenges are enabled.
+func (m *Manager) HTTPChallenge() bool {
+	return slices.Contains(m.cfg.Challenges, ChallengeHTTP01)
+}
+
+// TLSALPNChallenge reports whether TLS-ALPN-01 challenges are enabled.
+func (m *Manager) TLSALPNChallenge() bool {
+	return slices.Contains(m.cfg.Challenges, ChallengeTLSALPN01)
+}
+
+// HTTPHandler answers HTTP-01 challenges under /.well-known/acme-challenge/
+// and passes every other request to fallback. A nil fallback redirects to
+// HTTPS.
+func (m *Manager) HTTPHandler(fallback http.Handler) http.Handler {
+	return m.autocert.HTTPHandler(fallback)
+}
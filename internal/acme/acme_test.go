@@ -0,0 +1,411 @@
+package acme
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+const testHost = "app.example.test"
+
+// directory is a minimal RFC 8555 server for one order with one
+// authorization offering the challenge types in offer. It validates
+// challenges against the gateway like a real CA would: http-01 by fetching
+// the token from the HTTP-01 handler, tls-alpn-01 with an acme-tls/1
+// handshake.
+type directory struct {
+	t         *testing.T
+	srv       *httptest.Server
+	offer     []string
+	challenge string // base URL of the gateway's HTTP-01 handler
+	tlsAddr   string // address of the gateway's TLS listener
+
+	mu        sync.Mutex
+	attempted []string // challenge types the client accepted, in order
+	validated bool
+	issued    []byte // PEM chain
+
+	caKey  *ecdsa.PrivateKey
+	caCert *x509.Certificate
+}
+
+const testToken = "tok-1"
+
+func newDirectory(t *testing.T, offer ...string) *directory {
+	t.Helper()
+	caKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "test ca"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(24 * time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &caKey.PublicKey, caKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	caCert, _ := x509.ParseCertificate(der)
+
+	d := &directory{t: t, offer: offer, caKey: caKey, caCert: caCert}
+	d.srv = httptest.NewTLSServer(http.HandlerFunc(d.serve))
+	t.Cleanup(d.srv.Close)
+	return d
+}
+
+// caFile writes the directory's TLS certificate for Config.CAFile.
+func (d *directory) caFile() string {
+	path := filepath.Join(d.t.TempDir(), "ca.pem")
+	data := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: d.srv.Certificate().Raw})
+	if err := os.WriteFile(path, data, 0o600); err != nil {
+		d.t.Fatal(err)
+	}
+	return path
+}
+
+func (d *directory) serve(w http.ResponseWriter, r *http.Request) {
+	base := d.srv.URL
+	w.Header().Set("Replay-Nonce", fmt.Sprintf("nonce-%d", time.Now().UnixNano()))
+	w.Header().Set("Content-Type", "application/json")
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	order := func() map[string]any {
+		status := "pending"
+		if d.validated {
+			status = "ready"
+		}
+		o := map[string]any{
+			"identifiers":    []map[string]string{{"type": "dns", "value": testHost}},
+			"authorizations": []string{base + "/authz/1"},
+			"finalize":       base + "/finalize/1",
+		}
+		if d.issued != nil {
+			status = "valid"
+			o["certificate"] = base + "/cert/1"
+		}
+		o["status"] = status
+		return o
+	}
+	authz := func() map[string]any {
+		status := "pending"
+		if d.validated {
+			status = "valid"
+		}
+		var challenges []map[string]string
+		for _, typ := range d.offer {
+			challenges = append(challenges, map[string]string{"type": typ, "url": base + "/chal/" + typ, "token": testToken, "status": status})
+		}
+		return map[string]any{
+			"status":     status,
+			"identifier": map[string]string{"type": "dns", "value": testHost},
+			"challenges": challenges,
+		}
+	}
+
+	if typ, ok := strings.CutPrefix(r.URL.Path, "/chal/"); ok {
+		d.attempted = append(d.attempted, typ)
+		if err := d.validate(typ); err != nil {
+			d.t.Errorf("%s validation: %v", typ, err)
+		} else {
+			d.validated = true
+		}
+		for _, c := range authz()["challenges"].([]map[string]string) {
+			if c["type"] == typ {
+				json.NewEncoder(w).Encode(c)
+			}
+		}
+		return
+	}
+
+	switch r.URL.Path {
+	case "/dir":
+		json.NewEncoder(w).Encode(map[string]string{
+			"newNonce":   base + "/nonce",
+			"newAccount": base + "/account",
+			"newOrder":   base + "/order",
+			"revokeCert": base + "/revoke",
+			"keyChange":  base + "/key-change",
+		})
+	case "/nonce":
+		w.WriteHeader(http.StatusOK)
+	case "/account":
+		w.Header().Set("Location", base+"/account/1")
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(map[string]string{"status": "valid"})
+	case "/order":
+		w.Header().Set("Location", base+"/order/1")
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(order())
+	case "/order/1":
+		w.Header().Set("Location", base+"/order/1")
+		json.NewEncoder(w).Encode(order())
+	case "/authz/1":
+		json.NewEncoder(w).Encode(authz())
+	case "/finalize/1":
+		if err := d.issue(r); err != nil {
+			d.t.Errorf("finalize: %v", err)
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		w.Header().Set("Location", base+"/order/1")
+		json.NewEncoder(w).Encode(order())
+	case "/cert/1":
+		w.Header().Set("Content-Type", "application/pem-certificate-chain")
+		w.Write(d.issued)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+// validate checks the gateway's response to a challenge of type typ.
+func (d *directory) validate(typ string) error {
+	if typ == ChallengeTLSALPN01 {
+		return d.validateTLSALPN()
+	}
+	req, _ := http.NewRequest(http.MethodGet, d.challenge+"/.well-known/acme-challenge/"+testToken, nil)
+	req.Host = testHost
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(resp.Body)
+	if resp.StatusCode != http.StatusOK || !strings.HasPrefix(string(body), testToken+".") {
+		return fmt.Errorf("status %d, body %q", resp.StatusCode, body)
+	}
+	return nil
+}
+
+// idPeAcmeIdentifier is the RFC 8737 certificate extension carrying the
+// key authorization digest.
+var idPeAcmeIdentifier = asn1.ObjectIdentifier{1, 3, 6, 1, 5, 5, 7, 1, 31}
+
+// validateTLSALPN completes an acme-tls/1 handshake with the gateway and
+// checks the challenge certificate.
+func (d *directory) validateTLSALPN() error {
+	conn, err := tls.Dial("tcp", d.tlsAddr, &tls.Config{
+		ServerName:         testHost,
+		NextProtos:         []string{ALPNProto},
+		InsecureSkipVerify: true, // the challenge certificate is self-signed
+	})
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	cs := conn.ConnectionState()
+	if cs.NegotiatedProtocol != ALPNProto {
+		return fmt.Errorf("negotiated %q", cs.NegotiatedProtocol)
+	}
+	leaf := cs.PeerCertificates[0]
+	if err := leaf.VerifyHostname(testHost); err != nil {
+		return err
+	}
+	for _, ext := range leaf.Extensions {
+		if ext.Id.Equal(idPeAcmeIdentifier) && ext.Critical {
+			return nil
+		}
+	}
+	return errors.New("no acmeIdentifier extension")
+}
+
+// serveTLS answers handshakes on a local listener with m, like a gateway
+// listener with TLS-ALPN-01 enabled.
+func serveTLS(t *testing.T, m *Manager) string {
+	t.Helper()
+	ln, err := tls.Listen("tcp", "127.0.0.1:0", &tls.Config{
+		GetCertificate: m.GetCertificate,
+		NextProtos:     []string{"http/1.1", ALPNProto},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				conn.(*tls.Conn).Handshake()
+			}()
+		}
+	}()
+	return ln.Addr().String()
+}
+
+// issue signs the CSR carried in the JWS payload of a finalize request.
+func (d *directory) issue(r *http.Request) error {
+	var jws struct {
+		Payload string `json:"payload"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&jws); err != nil {
+		return err
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(jws.Payload)
+	if err != nil {
+		return err
+	}
+	var req struct {
+		CSR string `json:"csr"`
+	}
+	if err := json.Unmarshal(payload, &req); err != nil {
+		return err
+	}
+	der, err := base64.RawURLEncoding.DecodeString(req.CSR)
+	if err != nil {
+		return err
+	}
+	csr, err := x509.ParseCertificateRequest(der)
+	if err != nil {
+		return err
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(2),
+		Subject:      pkix.Name{CommonName: testHost},
+		DNSNames:     csr.DNSNames,
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(90 * 24 * time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}
+	leaf, err := x509.CreateCertificate(rand.Reader, tmpl, d.caCert, csr.PublicKey, d.caKey)
+	if err != nil {
+		return err
+	}
+	d.issued = append(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: leaf}),
+		pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: d.caCert.Raw})...)
+	return nil
+}
+
+func TestObtainCertificate(t *testing.T) {
+	// The directory offers only http-01, so tls-alpn-01 is skipped.
+	dir := newDirectory(t, ChallengeHTTP01)
+	m, err := New(Config{
+		Hosts:        []string{testHost},
+		DirectoryURL: dir.srv.URL + "/dir",
+		CAFile:       dir.caFile(),
+		AcceptTOS:    true,
+		Store:        NewMemoryStore(),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	gateway := httptest.NewServer(m.HTTPHandler(nil))
+	defer gateway.Close()
+	dir.challenge = gateway.URL
+
+	cert, err := m.GetCertificate(&tls.ClientHelloInfo{ServerName: testHost})
+	if err != nil {
+		t.Fatal(err)
+	}
+	leaf, err := x509.ParseCertificate(cert.Certificate[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := leaf.VerifyHostname(testHost); err != nil {
+		t.Error(err)
+	}
+	if err := leaf.CheckSignatureFrom(dir.caCert); err != nil {
+		t.Errorf("certificate not issued by the directory: %v", err)
+	}
+
+	// A second handshake is served from the store.
+	again, err := m.GetCertificate(&tls.ClientHelloInfo{ServerName: testHost})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(again.Certificate[0], leaf.Raw) {
+		t.Error("certificate was issued again instead of reused")
+	}
+
+	if _, err := m.GetCertificate(&tls.ClientHelloInfo{ServerName: "other.example.test"}); err == nil {
+		t.Error("expected an error for a host that is not managed")
+	}
+}
+
+func TestChallengeOrder(t *testing.T) {
+	dir := newDirectory(t, ChallengeHTTP01, ChallengeTLSALPN01)
+	m, err := New(Config{
+		Hosts:        []string{testHost},
+		DirectoryURL: dir.srv.URL + "/dir",
+		CAFile:       dir.caFile(),
+		AcceptTOS:    true,
+		Store:        NewMemoryStore(),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	gateway := httptest.NewServer(m.HTTPHandler(nil))
+	defer gateway.Close()
+	dir.challenge = gateway.URL
+	dir.tlsAddr = serveTLS(t, m)
+
+	if _, err := m.GetCertificate(&tls.ClientHelloInfo{ServerName: testHost}); err != nil {
+		t.Fatal(err)
+	}
+	dir.mu.Lock()
+	defer dir.mu.Unlock()
+	if !slices.Equal(dir.attempted, []string{ChallengeTLSALPN01}) {
+		t.Errorf("attempted challenges %v, want only %s", dir.attempted, ChallengeTLSALPN01)
+	}
+}
+
+func TestNewRequiresTLSALPN(t *testing.T) {
+	_, err := New(Config{Hosts: []string{testHost}, AcceptTOS: true, Challenges: []string{ChallengeHTTP01}, Store: NewMemoryStore()})
+	if err == nil {
+		t.Error("expected an error when tls-alpn-01 is not enabled")
+	}
+}
+
+func TestHandles(t *testing.T) {
+	challenge := &tls.ClientHelloInfo{ServerName: testHost, SupportedProtos: []string{ALPNProto}}
+	plain := &tls.ClientHelloInfo{ServerName: testHost, SupportedProtos: []string{"h2"}}
+
+	for _, tc := range []struct {
+		challenges []string
+		hello      *tls.ClientHelloInfo
+		want       bool
+	}{
+		{nil, challenge, true},
+		{[]string{ChallengeTLSALPN01}, challenge, true},
+		{[]string{ChallengeHTTP01, ChallengeTLSALPN01}, challenge, true},
+		{nil, plain, false},
+	} {
+		m, err := New(Config{Hosts: []string{testHost}, AcceptTOS: true, Challenges: tc.challenges, Store: NewMemoryStore()})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := m.Handles(tc.hello); got != tc.want {
+			t.Errorf("challenges %v, protos %v: Handles = %v, want %v", tc.challenges, tc.hello.SupportedProtos, got, tc.want)
+		}
+	}
+}
@@ -0,0 +1,79 @@
+package acme
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"golang.org/x/crypto/acme/autocert"
+)
+
+// Store persists account keys and issued certificates. Any autocert.Cache
+// implementation can be used, so certificates can live in a shared backend
+// when several gateway replicas serve the same hostnames.
+type Store = autocert.Cache
+
+type StoreType string
+
+const (
+	StoreDir    StoreType = "dir"
+	StoreMemory StoreType = "memory"
+)
+
+type StoreConfig struct {
+	Type StoreType
+	Path string
+}
+
+const defaultStoreDir = "acme-certs"
+
+func NewStore(cfg StoreConfig) (Store, error) {
+	switch cfg.Type {
+	case "", StoreDir:
+		path := cfg.Path
+		if path == "" {
+			path = defaultStoreDir
+		}
+		return autocert.DirCache(path), nil
+	case StoreMemory:
+		return NewMemoryStore(), nil
+	default:
+		return nil, fmt.Errorf("unknown acme store: %s", cfg.Type)
+	}
+}
+
+// MemoryStore keeps certificates for the lifetime of the process. It is
+// meant for tests against a local ACME server; in production every restart
+// would re-issue certificates and quickly hit CA rate limits.
+type MemoryStore struct {
+	mu    sync.RWMutex
+	items map[string][]byte
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{items: make(map[string][]byte)}
+}
+
+func (m *MemoryStore) Get(ctx context.Context, key string) ([]byte, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	data, ok := m.items[key]
+	if !ok {
+		return nil, autocert.ErrCacheMiss
+	}
+	return data, nil
+}
+
+func (m *MemoryStore) Put(ctx context.Context, key string, data []byte) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.items[key] = data
+	return nil
+}
+
+func (m *MemoryStore) Delete(ctx context.Context, key string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.items, key)
+	return nil
+}
@@ -0,0 +1,88 @@
+// Package authz evaluates per-route authorization policies after the user
+// has been authenticated.
+package authz
+
+import (
+	"net/http"
+
+	"github.com/rs/zerolog/log"
+
+	"github.com/shrihariharanba/go-gateway/internal/sso"
+	"github.com/shrihariharanba/go-gateway/internal/sso/providers"
+)
+
+// Decision is the outcome of a policy. Reason explains a denial and is
+// returned to the client.
+type Decision struct {
+	Allow  bool
+	Reason string
+}
+
+// Policy decides whether a request may proceed.
+type Policy interface {
+	Name() string
+	Evaluate(r *http.Request, authCtx *providers.AuthContext) (Decision, error)
+}
+
+// Observer records policy decisions, e.g. as metrics. decision is "allow",
+// "deny" or "error".
+type Observer func(route, policy, decision string)
+
+const (
+	DecisionAllow = "allow"
+	DecisionDeny  = "deny"
+	DecisionError = "error"
+)
+
+// Middleware requires every policy to allow the request. Policies are
+// evaluated in order and the first denial answers 403; evaluation errors
+// deny as well.
+func Middleware(route string, policies []Policy, observe Observer) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			authCtx := sso.FromContext(r.Context())
+			for _, p := range policies {
+				d, err := p.Evaluate(r, authCtx)
+				decision := DecisionAllow
+				switch {
+				case err != nil:
+					decision = DecisionError
+					log.Error().Err(err).
+						Str("route", route).
+						Str("policy", p.Name()).
+						Str("user", authCtx.UserID).
+						Msg("Authorization policy failed")
+				case !d.Allow:
+					decision = DecisionDeny
+					log.Warn().
+						Str("route", route).
+						Str("policy", p.Name()).
+						Str("user", authCtx.UserID).
+						Str("method", r.Method).
+						Str("path", r.URL.Path).
+						Str("reason", d.Reason).
+						Msg("Authorization denied")
+				default:
+					log.Debug().
+						Str("route", route).
+						Str("policy", p.Name()).
+						Str("user", authCtx.UserID).
+						Msg("Authorization allowed")
+				}
+				if observe != nil {
+					observe(route, p.Name(), decision)
+				}
+
+				if decision != DecisionAllow {
+					reason := d.Reason
+					if reason == "" {
+						reason = "denied by policy " + p.Name()
+					}
+					http.Error(w, "Forbidden: "+reason, http.StatusForbidden)
+					return
+				}
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
@@ -0,0 +1,98 @@
+package authz
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/google/cel-go/cel"
+
+	"github.com/shrihariharanba/go-gateway/internal/sso/providers"
+)
+
+// celEnv declares the variables CEL policies can use:
+//
+//	request.method, request.path, request.host, request.client_ip  string
+//	request.headers, request.query, request.params  map(string, string)
+//	request.time  timestamp
+//	auth.user, auth.email  string
+//	auth.roles, auth.scopes  list(string)
+//	auth.claims  map(string, dyn)
+//	auth.authenticated  bool
+var celEnv = func() *cel.Env {
+	strMap := cel.MapType(cel.StringType, cel.StringType)
+	env, err := cel.NewEnv(
+		cel.Variable("request.method", cel.StringType),
+		cel.Variable("request.path", cel.StringType),
+		cel.Variable("request.host", cel.StringType),
+		cel.Variable("request.client_ip", cel.StringType),
+		cel.Variable("request.headers", strMap),
+		cel.Variable("request.query", strMap),
+		cel.Variable("request.params", strMap),
+		cel.Variable("request.time", cel.TimestampType),
+		cel.Variable("auth.user", cel.StringType),
+		cel.Variable("auth.email", cel.StringType),
+		cel.Variable("auth.roles", cel.ListType(cel.StringType)),
+		cel.Variable("auth.scopes", cel.ListType(cel.StringType)),
+		cel.Variable("auth.claims", cel.MapType(cel.StringType, cel.DynType)),
+		cel.Variable("auth.authenticated", cel.BoolType),
+	)
+	if err != nil {
+		panic(err)
+	}
+	return env
+}()
+
+// CELPolicy is a CEL expression that must evaluate to true, for example
+// `auth.claims.tenant == request.params.tenant`.
+type CELPolicy struct {
+	name    string
+	message string
+	program cel.Program
+}
+
+// CompileCEL parses and type-checks expr. message is returned to denied
+// clients; by default the policy name is.
+func CompileCEL(name, expr, message string) (*CELPolicy, error) {
+	ast, iss := celEnv.Compile(expr)
+	if iss.Err() != nil {
+		return nil, fmt.Errorf("cel policy %s: %w", name, iss.Err())
+	}
+	if !ast.OutputType().IsExactType(cel.BoolType) {
+		return nil, fmt.Errorf("cel policy %s must return bool, not %s", name, ast.OutputType())
+	}
+	prg, err := celEnv.Program(ast)
+	if err != nil {
+		return nil, fmt.Errorf("cel policy %s: %w", name, err)
+	}
+	return &CELPolicy{name: name, message: message, program: prg}, nil
+}
+
+func (p *CELPolicy) Name() string { return p.name }
+
+func (p *CELPolicy) Evaluate(r *http.Request, authCtx *providers.AuthContext) (Decision, error) {
+	req := NewRequest(r)
+	auth := authInput(authCtx)
+	vars := map[string]any{
+		"request.method":    req.Method,
+		"request.path":      req.Path,
+		"request.host":      req.Host,
+		"request.client_ip": req.ClientIP,
+		"request.headers":   req.Headers,
+		"request.query":     req.Query,
+		"request.params":    req.Params,
+		"request.time":      req.Time,
+	}
+	for k, v := range auth {
+		vars["auth."+k] = v
+	}
+
+	out, _, err := p.program.ContextEval(r.Context(), vars)
+	if err != nil {
+		return Decision{}, fmt.Errorf("cel policy %s: %w", p.name, err)
+	}
+	allow, ok := out.Value().(bool)
+	if !ok {
+		return Decision{}, fmt.Errorf("cel policy %s returned %v", p.name, out.Value())
+	}
+	return Decision{Allow: allow, Reason: p.message}, nil
+}
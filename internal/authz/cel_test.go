@@ -0,0 +1,134 @@
+package authz
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+
+	"github.com/shrihariharanba/go-gateway/internal/sso/providers"
+)
+
+// routeRequest returns a request as chi passes it to a route with the
+// given path parameters.
+func routeRequest(method, target string, params map[string]string) *http.Request {
+	r := httptest.NewRequest(method, target, nil)
+	rctx := chi.NewRouteContext()
+	for k, v := range params {
+		rctx.URLParams.Add(k, v)
+	}
+	return r.WithContext(context.WithValue(r.Context(), chi.RouteCtxKey, rctx))
+}
+
+func TestCELTenantClaim(t *testing.T) {
+	p, err := CompileCEL("tenant", `auth.claims.tenant == request.params.tenant`, "wrong tenant")
+	if err != nil {
+		t.Fatal(err)
+	}
+	auth := &providers.AuthContext{UserID: "u1", Claims: map[string]any{"tenant": "acme"}}
+
+	for _, tc := range []struct {
+		tenant string
+		allow  bool
+	}{
+		{"acme", true},
+		{"globex", false},
+	} {
+		r := routeRequest("GET", "/tenants/"+tc.tenant+"/orders", map[string]string{"tenant": tc.tenant})
+		d, err := p.Evaluate(r, auth)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if d.Allow != tc.allow {
+			t.Errorf("tenant %s: allow = %v, want %v", tc.tenant, d.Allow, tc.allow)
+		}
+		if d.Reason != "wrong tenant" {
+			t.Errorf("reason = %q", d.Reason)
+		}
+	}
+
+	// A missing claim is an evaluation error, not an allow.
+	r := routeRequest("GET", "/tenants/acme/orders", map[string]string{"tenant": "acme"})
+	if _, err := p.Evaluate(r, &providers.AuthContext{UserID: "u2"}); err == nil {
+		t.Error("expected an error for a missing claim")
+	}
+}
+
+func TestCELMethodRoleTime(t *testing.T) {
+	p, err := CompileCEL("contractors",
+		`request.method != "POST" || !("contractor" in auth.roles) || request.time.getDayOfWeek() in [1, 2, 3, 4, 5]`, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	day := time.Now().UTC().Weekday()
+	weekday := day != time.Saturday && day != time.Sunday
+
+	for _, tc := range []struct {
+		method string
+		roles  []string
+		allow  bool
+	}{
+		{"GET", []string{"contractor"}, true},
+		{"POST", []string{"employee"}, true},
+		{"POST", []string{"contractor"}, weekday},
+	} {
+		r := routeRequest(tc.method, "/reports", nil)
+		d, err := p.Evaluate(r, &providers.AuthContext{UserID: "u1", Roles: tc.roles})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if time.Now().UTC().Weekday() != day {
+			t.Skip("day changed during the test")
+		}
+		if d.Allow != tc.allow {
+			t.Errorf("%s %v on %s: allow = %v, want %v", tc.method, tc.roles, day, d.Allow, tc.allow)
+		}
+	}
+}
+
+func TestCELAnonymous(t *testing.T) {
+	p, err := CompileCEL("public", `auth.authenticated == false || "admin" in auth.roles`, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, tc := range []struct {
+		auth  *providers.AuthContext
+		allow bool
+	}{
+		{&providers.AuthContext{UserID: "anonymous"}, true},
+		{&providers.AuthContext{}, true},
+		{&providers.AuthContext{UserID: "u1"}, false},
+		{&providers.AuthContext{UserID: "u1", Roles: []string{"admin"}}, true},
+	} {
+		d, err := p.Evaluate(routeRequest("GET", "/", nil), tc.auth)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if d.Allow != tc.allow {
+			t.Errorf("%+v: allow = %v, want %v", tc.auth, d.Allow, tc.allow)
+		}
+	}
+}
+
+func TestCompileCELRejects(t *testing.T) {
+	for _, expr := range []string{
+		`request.path`,                 // string, not bool
+		`size(auth.roles)`,             // int
+		`request.nope == "x"`,          // undeclared variable
+		`request.method == `,           // syntax error
+		`auth.roles == request.method`, // type mismatch
+	} {
+		_, err := CompileCEL("bad", expr, "")
+		if err == nil {
+			t.Errorf("%q compiled", expr)
+			continue
+		}
+		if !strings.Contains(err.Error(), "cel policy bad") {
+			t.Errorf("%q: error %q does not name the policy", expr, err)
+		}
+	}
+}
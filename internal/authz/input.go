@@ -0,0 +1,93 @@
+package authz
+
+import (
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+
+	"github.com/shrihariharanba/go-gateway/internal/clientip"
+	"github.com/shrihariharanba/go-gateway/internal/sso/providers"
+)
+
+// redactedHeaders carry credentials. Policies see that they are present
+// but not their values, which would otherwise end up in evaluation traces
+// and decision logs.
+var redactedHeaders = map[string]bool{
+	"authorization":       true,
+	"proxy-authorization": true,
+	"cookie":              true,
+}
+
+// Redacted replaces the value of credential headers.
+const Redacted = "[redacted]"
+
+// Request is the part of an HTTP request policies see. Header names are
+// lower case and repeated headers or query parameters are joined with ",".
+type Request struct {
+	Method   string
+	Path     string
+	Host     string
+	Headers  map[string]string
+	Query    map[string]string
+	Params   map[string]string // route path parameters such as {tenant}
+	ClientIP string
+	Time     time.Time
+}
+
+// NewRequest captures r for policy evaluation.
+func NewRequest(r *http.Request) *Request {
+	req := &Request{
+		Method:   r.Method,
+		Path:     r.URL.Path,
+		Host:     r.Host,
+		Headers:  make(map[string]string, len(r.Header)),
+		Query:    make(map[string]string),
+		Params:   make(map[string]string),
+		ClientIP: clientip.String(r),
+		Time:     time.Now().UTC(),
+	}
+	for name, vals := range r.Header {
+		name = strings.ToLower(name)
+		if redactedHeaders[name] {
+			req.Headers[name] = Redacted
+			continue
+		}
+		req.Headers[name] = strings.Join(vals, ",")
+	}
+	for name, vals := range r.URL.Query() {
+		req.Query[name] = strings.Join(vals, ",")
+	}
+	if rctx := chi.RouteContext(r.Context()); rctx != nil {
+		for i, key := range rctx.URLParams.Keys {
+			if key != "*" && i < len(rctx.URLParams.Values) {
+				req.Params[key] = rctx.URLParams.Values[i]
+			}
+		}
+	}
+	return req
+}
+
+// authInput is the auth context as policies see it.
+func authInput(authCtx *providers.AuthContext) map[string]any {
+	claims := authCtx.Claims
+	if claims == nil {
+		claims = map[string]any{}
+	}
+	return map[string]any{
+		"user":          authCtx.UserID,
+		"email":         authCtx.UserEmail,
+		"roles":         nonNil(authCtx.Roles),
+		"scopes":        nonNil(authCtx.Scopes),
+		"claims":        claims,
+		"authenticated": authCtx.UserID != "" && authCtx.UserID != "anonymous",
+	}
+}
+
+func nonNil(s []string) []string {
+	if s == nil {
+		return []string{}
+	}
+	return s
+}
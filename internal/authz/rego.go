@@ -0,0 +1,171 @@
+package authz
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/open-policy-agent/opa/v1/rego"
+	"github.com/open-policy-agent/opa/v1/topdown"
+	"github.com/rs/zerolog/log"
+
+	"github.com/shrihariharanba/go-gateway/internal/filewatch"
+	"github.com/shrihariharanba/go-gateway/internal/sso/providers"
+)
+
+// DefaultRegoQuery is evaluated when a Rego policy names no query.
+const DefaultRegoQuery = "data.gateway.authz"
+
+type RegoConfig struct {
+	Name   string
+	Bundle string // directory with .rego and data files, or a bundle tarball
+	Query  string // default DefaultRegoQuery
+	// Trace logs every evaluation trace at debug level. Credential
+	// headers are redacted from the input, so traces do not carry them.
+	Trace bool
+}
+
+// RegoPolicy evaluates a Rego bundle in process. The query may produce a
+// boolean, or an object with "allow" and a "reason" string or "reasons"
+// list explaining a denial. An undefined result denies.
+//
+// The input document is:
+//
+//	{"request": {"method", "path", "host", "client_ip", "time",
+//	             "headers", "query", "params"},
+//	 "auth":    {"user", "email", "roles", "scopes", "claims",
+//	             "authenticated"}}
+type RegoPolicy struct {
+	cfg      RegoConfig
+	prepared atomic.Pointer[rego.PreparedEvalQuery]
+}
+
+// LoadRego loads and compiles the bundle.
+func LoadRego(ctx context.Context, cfg RegoConfig) (*RegoPolicy, error) {
+	if cfg.Query == "" {
+		cfg.Query = DefaultRegoQuery
+	}
+	p := &RegoPolicy{cfg: cfg}
+	if err := p.Reload(ctx); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// Reload recompiles the bundle. On failure the previous version stays in
+// effect.
+func (p *RegoPolicy) Reload(ctx context.Context) error {
+	pq, err := rego.New(
+		rego.Query(p.cfg.Query),
+		rego.LoadBundle(p.cfg.Bundle),
+	).PrepareForEval(ctx)
+	if err != nil {
+		return fmt.Errorf("rego policy %s: %w", p.cfg.Name, err)
+	}
+	p.prepared.Store(&pq)
+	return nil
+}
+
+// Watch reloads the bundle whenever its directory or tarball changes,
+// until ctx is cancelled.
+func (p *RegoPolicy) Watch(ctx context.Context) error {
+	reload := func() {
+		if err := p.Reload(ctx); err != nil {
+			log.Error().Err(err).Str("bundle", p.cfg.Bundle).Msg("Failed to reload Rego bundle, keeping the previous version")
+			return
+		}
+		log.Info().Str("policy", p.cfg.Name).Str("bundle", p.cfg.Bundle).Msg("Reloaded Rego bundle")
+	}
+	if fi, err := os.Stat(p.cfg.Bundle); err == nil && !fi.IsDir() {
+		return filewatch.Watch(ctx, []string{p.cfg.Bundle}, reload)
+	}
+	return filewatch.WatchDir(ctx, p.cfg.Bundle, reload)
+}
+
+func (p *RegoPolicy) Name() string { return p.cfg.Name }
+
+func (p *RegoPolicy) Evaluate(r *http.Request, authCtx *providers.AuthContext) (Decision, error) {
+	input := Input(r, authCtx)
+	if !p.cfg.Trace {
+		return p.Eval(r.Context(), input)
+	}
+	d, trace, err := p.Explain(r.Context(), input)
+	log.Debug().Str("policy", p.cfg.Name).Strs("trace", trace).Bool("allow", d.Allow).Msg("Rego evaluation trace")
+	return d, err
+}
+
+// Eval decides on an input document, as built by Input.
+func (p *RegoPolicy) Eval(ctx context.Context, input map[string]any) (Decision, error) {
+	rs, err := p.prepared.Load().Eval(ctx, rego.EvalInput(input))
+	if err != nil {
+		return Decision{}, fmt.Errorf("rego policy %s: %w", p.cfg.Name, err)
+	}
+	return p.decision(rs)
+}
+
+// Explain is Eval with the evaluation trace, one line per step, for tests
+// and debugging.
+func (p *RegoPolicy) Explain(ctx context.Context, input map[string]any) (Decision, []string, error) {
+	tracer := topdown.NewBufferTracer()
+	rs, err := p.prepared.Load().Eval(ctx, rego.EvalInput(input), rego.EvalQueryTracer(tracer))
+
+	var buf strings.Builder
+	topdown.PrettyTraceWithLocation(&buf, *tracer)
+	trace := strings.Split(strings.TrimRight(buf.String(), "\n"), "\n")
+	if err != nil {
+		return Decision{}, trace, fmt.Errorf("rego policy %s: %w", p.cfg.Name, err)
+	}
+	d, err := p.decision(rs)
+	return d, trace, err
+}
+
+func (p *RegoPolicy) decision(rs rego.ResultSet) (Decision, error) {
+	if len(rs) == 0 || len(rs[0].Expressions) == 0 {
+		return Decision{Reason: fmt.Sprintf("%s is undefined", p.cfg.Query)}, nil
+	}
+	switch v := rs[0].Expressions[0].Value.(type) {
+	case bool:
+		return Decision{Allow: v}, nil
+	case map[string]any:
+		allow, ok := v["allow"].(bool)
+		if !ok {
+			return Decision{}, fmt.Errorf("rego policy %s: %s.allow is not a boolean", p.cfg.Name, p.cfg.Query)
+		}
+		d := Decision{Allow: allow}
+		if reason, ok := v["reason"].(string); ok {
+			d.Reason = reason
+		}
+		if reasons, ok := v["reasons"].([]any); ok {
+			var parts []string
+			for _, r := range reasons {
+				parts = append(parts, fmt.Sprint(r))
+			}
+			d.Reason = strings.Join(parts, "; ")
+		}
+		return d, nil
+	default:
+		return Decision{}, fmt.Errorf("rego policy %s: %s is %T, expected a boolean or object", p.cfg.Name, p.cfg.Query, v)
+	}
+}
+
+// Input builds the input document of a Rego policy.
+func Input(r *http.Request, authCtx *providers.AuthContext) map[string]any {
+	req := NewRequest(r)
+	return map[string]any{
+		"request": map[string]any{
+			"method":    req.Method,
+			"path":      req.Path,
+			"host":      req.Host,
+			"client_ip": req.ClientIP,
+			"time":      req.Time.Format(time.RFC3339Nano),
+			"headers":   req.Headers,
+			"query":     req.Query,
+			"params":    req.Params,
+		},
+		"auth": authInput(authCtx),
+	}
+}
@@ -0,0 +1,115 @@
+package authz
+
+import (
+	"context"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/shrihariharanba/go-gateway/internal/sso/providers"
+)
+
+const testPolicy = `package gateway
+
+default authz := {"allow": false, "reason": "not an admin"}
+
+authz := {"allow": true} if {
+	"admin" in input.auth.roles
+	input.request.method == "GET"
+}
+`
+
+func loadTestPolicy(t *testing.T, policy string) *RegoPolicy {
+	t.Helper()
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "policy.rego"), []byte(policy), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	p, err := LoadRego(context.Background(), RegoConfig{Name: "test", Bundle: dir})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return p
+}
+
+func TestRegoExplain(t *testing.T) {
+	p := loadTestPolicy(t, testPolicy)
+
+	r := httptest.NewRequest("GET", "/orders", nil)
+	r.Header.Set("Authorization", "Bearer secret-token")
+	r.Header.Set("Cookie", "gw_session=secret-cookie")
+	r.Header.Set("X-Tenant", "acme")
+
+	d, trace, err := p.Explain(context.Background(), Input(r, &providers.AuthContext{UserID: "u1", Roles: []string{"admin"}}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !d.Allow {
+		t.Errorf("decision = %+v, want allow", d)
+	}
+	if len(trace) < 2 {
+		t.Fatalf("trace = %q", trace)
+	}
+	joined := strings.Join(trace, "\n")
+	if !strings.Contains(joined, "policy.rego") {
+		t.Error("trace has no source locations")
+	}
+	for _, secret := range []string{"secret-token", "secret-cookie"} {
+		if strings.Contains(joined, secret) {
+			t.Errorf("trace contains %q", secret)
+		}
+	}
+
+	d, _, err = p.Explain(context.Background(), Input(r, &providers.AuthContext{UserID: "u2"}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d.Allow || d.Reason != "not an admin" {
+		t.Errorf("decision = %+v, want deny with reason", d)
+	}
+}
+
+func TestRegoDecisionShapes(t *testing.T) {
+	for _, tc := range []struct {
+		policy  string
+		allow   bool
+		reason  string
+		wantErr bool
+	}{
+		{"package gateway\nauthz := true", true, "", false},
+		{"package gateway\nauthz := {\"allow\": false, \"reasons\": [\"a\", \"b\"]}", false, "a; b", false},
+		{"package gateway\nother := true", false, "data.gateway.authz is undefined", false},
+		{"package gateway\nauthz := \"yes\"", false, "", true},
+		{"package gateway\nauthz := {\"allow\": \"yes\"}", false, "", true},
+	} {
+		p := loadTestPolicy(t, tc.policy)
+		d, err := p.Eval(context.Background(), map[string]any{})
+		if (err != nil) != tc.wantErr {
+			t.Errorf("%q: err = %v", tc.policy, err)
+			continue
+		}
+		if d.Allow != tc.allow || d.Reason != tc.reason {
+			t.Errorf("%q: decision = %+v", tc.policy, d)
+		}
+	}
+}
+
+func TestInputRedactsCredentials(t *testing.T) {
+	r := httptest.NewRequest("GET", "/", nil)
+	r.Header.Set("Authorization", "Bearer secret")
+	r.Header.Set("Proxy-Authorization", "Basic secret")
+	r.Header.Set("Cookie", "a=secret")
+	r.Header.Set("Accept", "text/html")
+
+	req := NewRequest(r)
+	for _, h := range []string{"authorization", "proxy-authorization", "cookie"} {
+		if req.Headers[h] != Redacted {
+			t.Errorf("%s = %q, want it redacted", h, req.Headers[h])
+		}
+	}
+	if req.Headers["accept"] != "text/html" {
+		t.Errorf("accept = %q", req.Headers["accept"])
+	}
+}
@@ -0,0 +1,206 @@
+// Package clientip resolves the address of the client behind trusted
+// proxies and load balancers.
+package clientip
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"net/netip"
+	"strings"
+)
+
+// Info describes where a request came from.
+type Info struct {
+	// Client is the resolved client address.
+	Client netip.Addr
+	// Peer is the address of the connection the request arrived on, after
+	// any PROXY protocol header has been applied.
+	Peer netip.Addr
+	// Trusted reports whether Peer is a trusted proxy, i.e. whether its
+	// forwarding headers were honoured.
+	Trusted bool
+}
+
+type ctxKey struct{}
+
+// Forwarding headers a Resolver can read the client chain from.
+const (
+	HeaderXForwardedFor = "x-forwarded-for"
+	HeaderForwarded     = "forwarded"
+)
+
+// Resolver determines the client address from the connection and, for
+// requests from trusted proxies, the one forwarding header those proxies
+// write. The other header is ignored: a proxy passes through whatever it
+// does not write itself, so the client controls it.
+type Resolver struct {
+	trusted []netip.Prefix
+	header  string
+}
+
+// ParsePrefixes parses CIDRs and bare addresses.
+func ParsePrefixes(cidrs []string) ([]netip.Prefix, error) {
+	out := make([]netip.Prefix, 0, len(cidrs))
+	for _, c := range cidrs {
+		if p, err := netip.ParsePrefix(c); err == nil {
+			out = append(out, p.Masked())
+			continue
+		}
+		a, err := netip.ParseAddr(c)
+		if err != nil {
+			return nil, fmt.Errorf("invalid address or CIDR %q", c)
+		}
+		out = append(out, netip.PrefixFrom(a, a.BitLen()))
+	}
+	return out, nil
+}
+
+// ValidHeader reports whether h names a supported forwarding header. The
+// empty string selects X-Forwarded-For.
+func ValidHeader(h string) bool {
+	switch strings.ToLower(h) {
+	case "", HeaderXForwardedFor, HeaderForwarded:
+		return true
+	}
+	return false
+}
+
+// NewResolver returns a Resolver that believes header (HeaderXForwardedFor
+// when empty) from peers in trustedCIDRs.
+func NewResolver(trustedCIDRs []string, header string) (*Resolver, error) {
+	trusted, err := ParsePrefixes(trustedCIDRs)
+	if err != nil {
+		return nil, err
+	}
+	if !ValidHeader(header) {
+		return nil, fmt.Errorf("unsupported client IP header %q", header)
+	}
+	header = strings.ToLower(header)
+	if header == "" {
+		header = HeaderXForwardedFor
+	}
+	return &Resolver{trusted: trusted, header: header}, nil
+}
+
+// Middleware stores the resolved Info on the request context.
+func (res *Resolver) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		info := res.Resolve(r)
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), ctxKey{}, info)))
+	})
+}
+
+// Resolve walks the forwarding chain from the nearest hop outwards and
+// returns the first address that is not a trusted proxy. Only the
+// configured header is read; there is no fallback to the other one.
+func (res *Resolver) Resolve(r *http.Request) Info {
+	peer := remoteAddr(r)
+	info := Info{Client: peer, Peer: peer, Trusted: res.isTrusted(peer)}
+	if !info.Trusted {
+		return info
+	}
+
+	var chain []string
+	if res.header == HeaderForwarded {
+		chain = forwardedFor(r.Header.Values("Forwarded"))
+	} else {
+		chain = xForwardedFor(r.Header.Values("X-Forwarded-For"))
+	}
+	for i := len(chain) - 1; i >= 0; i-- {
+		addr, ok := parseNode(chain[i])
+		if !ok {
+			// Unparseable entries (e.g. "unknown") end the trusted chain.
+			break
+		}
+		info.Client = addr
+		if !res.isTrusted(addr) {
+			break
+		}
+	}
+	return info
+}
+
+func (res *Resolver) isTrusted(addr netip.Addr) bool {
+	for _, p := range res.trusted {
+		if p.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+// FromRequest returns the Info stored by Middleware, or one derived from
+// RemoteAddr alone when the middleware did not run.
+func FromRequest(r *http.Request) Info {
+	if info, ok := r.Context().Value(ctxKey{}).(Info); ok {
+		return info
+	}
+	peer := remoteAddr(r)
+	return Info{Client: peer, Peer: peer}
+}
+
+// String returns the client address of r, or "" if it is unknown.
+func String(r *http.Request) string {
+	if c := FromRequest(r).Client; c.IsValid() {
+		return c.String()
+	}
+	return ""
+}
+
+func remoteAddr(r *http.Request) netip.Addr {
+	if ap, err := netip.ParseAddrPort(r.RemoteAddr); err == nil {
+		return ap.Addr().Unmap()
+	}
+	a, _ := netip.ParseAddr(r.RemoteAddr)
+	return a.Unmap()
+}
+
+func xForwardedFor(values []string) []string {
+	var out []string
+	for _, v := range values {
+		for _, part := range strings.Split(v, ",") {
+			out = append(out, strings.TrimSpace(part))
+		}
+	}
+	return out
+}
+
+// forwardedFor extracts the for= parameters of RFC 7239 Forwarded headers.
+func forwardedFor(values []string) []string {
+	var out []string
+	for _, v := range values {
+		for _, elem := range strings.Split(v, ",") {
+			for _, pair := range strings.Split(elem, ";") {
+				k, val, ok := strings.Cut(strings.TrimSpace(pair), "=")
+				if ok && strings.EqualFold(k, "for") {
+					out = append(out, strings.Trim(val, `"`))
+				}
+			}
+		}
+	}
+	return out
+}
+
+// parseNode accepts "1.2.3.4", "1.2.3.4:80", "[2001:db8::1]:80" and
+// "2001:db8::1".
+func parseNode(s string) (netip.Addr, bool) {
+	if a, err := netip.ParseAddr(strings.Trim(s, "[]")); err == nil {
+		return a.Unmap(), true
+	}
+	if host, _, err := net.SplitHostPort(s); err == nil {
+		if a, err := netip.ParseAddr(host); err == nil {
+			return a.Unmap(), true
+		}
+	}
+	return netip.Addr{}, false
+}
+
+// Node formats an address as an RFC 7239 node, quoting IPv6 addresses.
+func Node(a netip.Addr) string {
+	if a.Is6() {
+		return `"[` + a.String() + `]"`
+	}
+	return a.String()
+}
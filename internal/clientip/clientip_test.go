@@ -0,0 +1,117 @@
+package clientip
+
+import (
+	"net/http/httptest"
+	"testing"
+)
+
+func TestResolve(t *testing.T) {
+	for _, tc := range []struct {
+		name    string
+		header  string
+		peer    string
+		xff     string
+		fwd     string
+		client  string
+		trusted bool
+	}{
+		{
+			name:   "untrusted peer ignores headers",
+			peer:   "203.0.113.9:1234",
+			xff:    "198.51.100.1",
+			fwd:    "for=198.51.100.2",
+			client: "203.0.113.9",
+		},
+		{
+			name:    "trusted peer, one hop",
+			peer:    "10.0.0.1:1234",
+			xff:     "198.51.100.1",
+			client:  "198.51.100.1",
+			trusted: true,
+		},
+		{
+			name:    "several trusted hops",
+			peer:    "10.0.0.1:1234",
+			xff:     "198.51.100.1, 10.0.0.3, 10.0.0.2",
+			client:  "198.51.100.1",
+			trusted: true,
+		},
+		{
+			name:    "spoofed leftmost hop",
+			peer:    "10.0.0.1:1234",
+			xff:     "1.2.3.4, 198.51.100.1, 10.0.0.2",
+			client:  "198.51.100.1",
+			trusted: true,
+		},
+		{
+			name:    "unknown ends the chain",
+			peer:    "10.0.0.1:1234",
+			xff:     "198.51.100.1, unknown, 10.0.0.2",
+			client:  "10.0.0.2",
+			trusted: true,
+		},
+		{
+			name:    "all hops trusted",
+			peer:    "10.0.0.1:1234",
+			xff:     "10.0.0.3, 10.0.0.2",
+			client:  "10.0.0.3",
+			trusted: true,
+		},
+		{
+			name:    "x-forwarded-for ignores a client-sent Forwarded",
+			peer:    "10.0.0.1:1234",
+			xff:     "198.51.100.1",
+			fwd:     "for=10.0.0.7",
+			client:  "198.51.100.1",
+			trusted: true,
+		},
+		{
+			name:    "forwarded ignores X-Forwarded-For",
+			header:  HeaderForwarded,
+			peer:    "10.0.0.1:1234",
+			xff:     "10.0.0.7",
+			fwd:     `for=198.51.100.1, for="[2001:db8::1]:4711";proto=https`,
+			client:  "2001:db8::1",
+			trusted: true,
+		},
+		{
+			name:    "forwarded without the header keeps the peer",
+			header:  HeaderForwarded,
+			peer:    "10.0.0.1:1234",
+			xff:     "198.51.100.1",
+			client:  "10.0.0.1",
+			trusted: true,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			res, err := NewResolver([]string{"10.0.0.0/8"}, tc.header)
+			if err != nil {
+				t.Fatal(err)
+			}
+			r := httptest.NewRequest("GET", "/", nil)
+			r.RemoteAddr = tc.peer
+			if tc.xff != "" {
+				r.Header.Set("X-Forwarded-For", tc.xff)
+			}
+			if tc.fwd != "" {
+				r.Header.Set("Forwarded", tc.fwd)
+			}
+			info := res.Resolve(r)
+			if info.Client.String() != tc.client {
+				t.Errorf("client = %s, want %s", info.Client, tc.client)
+			}
+			if info.Trusted != tc.trusted {
+				t.Errorf("trusted = %v, want %v", info.Trusted, tc.trusted)
+			}
+		})
+	}
+}
+
+func TestNewResolverRejectsUnknownHeader(t *testing.T) {
+	if _, err := NewResolver(nil, "x-real-ip"); err == nil {
+		t.Error("expected an error for an unsupported header")
+	}
+	if _, err := NewResolver(nil, "Forwarded"); err != nil {
+		t.Errorf("header names are case-insensitive: %v", err)
+	}
+}
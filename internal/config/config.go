@@ -3,29 +3,302 @@ package config
 import (
 	"errors"
 	"fmt"
+	"net"
+	"net/http"
+	"net/url"
 	"os"
+	"reflect"
+	"regexp"
+	"slices"
 	"strconv"
+	"strings"
+	"time"
 
+	"github.com/shrihariharanba/go-gateway/internal/acme"
+	"github.com/shrihariharanba/go-gateway/internal/authz"
+	"github.com/shrihariharanba/go-gateway/internal/clientip"
+	discoveryProviders "github.com/shrihariharanba/go-gateway/internal/discovery/providers"
+	"github.com/shrihariharanba/go-gateway/internal/discovery/providers/kubernetes"
+	"github.com/shrihariharanba/go-gateway/internal/sso"
 	ssoProviders "github.com/shrihariharanba/go-gateway/internal/sso/providers"
+	"github.com/shrihariharanba/go-gateway/internal/sso/providers/azure"
+	"github.com/shrihariharanba/go-gateway/internal/sso/providers/oidc"
 	telemetryProviders "github.com/shrihariharanba/go-gateway/internal/telemetry/providers"
+	"github.com/shrihariharanba/go-gateway/internal/tlsutil"
 	"gopkg.in/yaml.v3"
 )
 
 // ServerConfig holds HTTP server settings.
 type ServerConfig struct {
-	Port       int  `yaml:"port"`
-	TLSEnabled bool `yaml:"tlsEnabled"`
+	Port           int                `yaml:"port"`
+	TLSEnabled     bool               `yaml:"tlsEnabled"`
+	TLS            ServerTLSConfig    `yaml:"tls"`
+	Listeners      []ListenerConfig   `yaml:"listeners"` // replaces port/tlsEnabled/tls when set
+	Admin          AdminConfig        `yaml:"admin"`
+	TrustedProxies []string           `yaml:"trustedProxies"` // CIDRs whose forwarding and PROXY headers are believed
+	ClientIPHeader string             `yaml:"clientIPHeader"` // header trustedProxies write: x-forwarded-for (default) or forwarded
+	HTTPRedirect   HTTPRedirectConfig `yaml:"httpRedirect"`
+	HSTS           HSTSConfig         `yaml:"hsts"`
+}
+
+// ListenerProtocol is the HTTP version spoken on a listener.
+type ListenerProtocol string
+
+const (
+	ProtocolHTTP1 ListenerProtocol = "http1" // HTTP/1.1, with or without TLS
+	ProtocolH2C   ListenerProtocol = "h2c"   // HTTP/1.1 and cleartext HTTP/2
+	ProtocolH2    ListenerProtocol = "h2"    // HTTP/1.1 and HTTP/2 over TLS
+	ProtocolH3    ListenerProtocol = "h3"    // HTTP/3 over QUIC (UDP)
+)
+
+// ListenerConfig is one address the gateway accepts traffic on. Every
+// listener serves the same routes; hosts restricts which virtual hosts are
+// answered on it.
+type ListenerConfig struct {
+	Name          string           `yaml:"name"`
+	Address       string           `yaml:"address"`       // e.g. ":8443" or "10.0.0.1:443"
+	Protocol      ListenerProtocol `yaml:"protocol"`      // http1 (default), h2c, h2, h3
+	TLS           *ServerTLSConfig `yaml:"tls"`           // required for h2 and h3
+	Hosts         []string         `yaml:"hosts"`         // optional; "*.example.com" wildcards allowed
+	ProxyProtocol bool             `yaml:"proxyProtocol"` // accept PROXY v1/v2 headers from trustedProxies
+}
+
+// AdminConfig moves metrics and admin endpoints to a separate listener
+// that is not exposed publicly.
+type AdminConfig struct {
+	Enabled bool   `yaml:"enabled"`
+	Address string `yaml:"address"` // default "127.0.0.1:9901"
+}
+
+// HTTPRedirectConfig adds a plain HTTP listener that permanently redirects
+// to HTTPS. /health and ACME HTTP-01 challenges are still served on it.
+type HTTPRedirectConfig struct {
+	Enabled   bool   `yaml:"enabled"`
+	Addr      string `yaml:"addr"`      // default ":80"
+	HTTPSPort int    `yaml:"httpsPort"` // port in redirect URLs, defaults to the first TLS listener
+}
+
+// HSTSConfig controls the Strict-Transport-Security header on TLS responses.
+type HSTSConfig struct {
+	Enabled           bool `yaml:"enabled"`
+	MaxAge            *int `yaml:"maxAge"` // seconds, default one year; 0 tells browsers to forget the policy
+	IncludeSubDomains bool `yaml:"includeSubDomains"`
+	Preload           bool `yaml:"preload"`
+}
+
+// CertificateConfig is a certificate/key pair on disk.
+type CertificateConfig struct {
+	CertFile string `yaml:"certFile"`
+	KeyFile  string `yaml:"keyFile"`
+}
+
+// ServerTLSConfig configures a TLS listener. The certificate is chosen by
+// SNI, and files are reloaded when they change.
+type ServerTLSConfig struct {
+	Certificates []CertificateConfig `yaml:"certificates"` // defaults to cert.pem / key.pem
+	MinVersion   string              `yaml:"minVersion"`   // "1.2", "1.3"
+	MaxVersion   string              `yaml:"maxVersion"`
+	CipherSuites []string            `yaml:"cipherSuites"` // IANA names, TLS 1.2 and below only
+	ALPN         []string            `yaml:"alpn"`         // defaults to h2, http/1.1
+	OCSPStapling bool                `yaml:"ocspStapling"`
+	ACME         ACMEConfig          `yaml:"acme"`
+}
+
+// ACMEConfig obtains and renews certificates automatically (e.g. from
+// Let's Encrypt). Configured certificates still take precedence for the
+// hostnames they cover.
+type ACMEConfig struct {
+	Enabled      bool            `yaml:"enabled"`
+	Hosts        []string        `yaml:"hosts"`
+	Email        string          `yaml:"email"`
+	DirectoryURL string          `yaml:"directoryUrl"` // defaults to Let's Encrypt production
+	CAFile       string          `yaml:"caFile"`       // trust for a private ACME server, e.g. Pebble
+	AcceptTOS    bool            `yaml:"acceptTos"`
+	Challenges   []string        `yaml:"challenges"`  // tls-alpn-01, optionally http-01 (default both)
+	HTTPAddr     string          `yaml:"httpAddr"`    // HTTP-01 listener when httpRedirect is off, default ":80"
+	RenewBefore  time.Duration   `yaml:"renewBefore"` // default 30 days
+	Store        ACMEStoreConfig `yaml:"store"`
+}
+
+// ACMEStoreConfig selects where ACME account keys and certificates are kept.
+type ACMEStoreConfig struct {
+	Type acme.StoreType `yaml:"type"` // dir (default), memory
+	Path string         `yaml:"path"` // dir: defaults to ./acme-certs
 }
 
 // SSOConfig holds generic SSO settings for all providers.
 type SSOConfig struct {
-	Enabled      bool                      `yaml:"enabled"`
-	Type         ssoProviders.ProviderType `yaml:"type"` // none, azure, google, okta
-	ClientID     string                    `yaml:"clientId"`
-	ClientSecret string                    `yaml:"clientSecret"`
-	TenantID     string                    `yaml:"tenantId"`  // Azure or Okta
-	IssuerURL    string                    `yaml:"issuerUrl"` // Okta preferred or Azure optional
-	RedirectURL  string                    `yaml:"redirectUrl"`
+	Enabled        bool                      `yaml:"enabled"`
+	Type           ssoProviders.ProviderType `yaml:"type"` // none, azure, google, okta, oidc
+	ClientID       string                    `yaml:"clientId"`
+	ClientSecret   string                    `yaml:"clientSecret"`
+	TenantID       string                    `yaml:"tenantId"`  // Azure or Okta
+	IssuerURL      string                    `yaml:"issuerUrl"` // oidc, Okta preferred or Azure authority
+	RedirectURL    string                    `yaml:"redirectUrl"`
+	AllowedTenants []string                  `yaml:"allowedTenants"` // Azure with tenantId common/organizations
+	HostedDomains  []string                  `yaml:"hostedDomains"`  // Google Workspace domains, required; ["*"] accepts any account
+	GoogleGroups   *GoogleGroupsConfig       `yaml:"googleGroups"`   // Google group lookup into roles
+
+	// Token verification, for every provider
+	Audiences  []string           `yaml:"audiences"`  // accepted aud values; default clientId
+	Algorithms []string           `yaml:"algorithms"` // e.g. RS256, ES256; default from discovery
+	ClockSkew  time.Duration      `yaml:"clockSkew"`  // leeway for exp/nbf
+	Claims     ClaimMappingConfig `yaml:"claims"`
+	// Introspection verifies opaque access tokens (Okta, oidc).
+	Introspection *IntrospectionConfig `yaml:"introspection"`
+
+	// Browser login; the callback is served at redirectUrl's path.
+	LoginPath string        `yaml:"loginPath"` // default /login
+	Session   SessionConfig `yaml:"session"`
+	Logout    LogoutConfig  `yaml:"logout"`
+}
+
+// IntrospectionConfig verifies bearer tokens that are not JWTs with the
+// issuer's RFC 7662 introspection endpoint.
+type IntrospectionConfig struct {
+	URL          string        `yaml:"url"`      // default: introspection_endpoint from discovery
+	ClientID     string        `yaml:"clientId"` // default: sso.clientId and sso.clientSecret
+	ClientSecret string        `yaml:"clientSecret"`
+	AuthMethod   string        `yaml:"authMethod"` // client_secret_basic (default) or client_secret_post
+	CacheTTL     time.Duration `yaml:"cacheTTL"`   // default 1m; never beyond the token's exp
+}
+
+// LogoutConfig configures ending browser sessions.
+type LogoutConfig struct {
+	Path        string `yaml:"path"`        // default /logout
+	RedirectURL string `yaml:"redirectUrl"` // after logout; registered with the provider when endSession is set
+	// EndSession continues to the provider's end_session_endpoint
+	// (RP-initiated logout) so the user is logged out there too.
+	EndSession bool `yaml:"endSession"`
+	// BackChannelPath receives OIDC back-channel logout requests; needs a
+	// session store.
+	BackChannelPath string `yaml:"backChannelPath"`
+}
+
+// SessionConfig configures the encrypted session cookie set after a browser
+// login.
+type SessionConfig struct {
+	Secret     string        `yaml:"secret"`     // 32+ chars; random per process when empty
+	CookieName string        `yaml:"cookieName"` // default gw_session
+	MaxAge     time.Duration `yaml:"maxAge"`     // absolute lifetime, default 8h
+
+	// Server-side sessions keep the provider tokens and refresh them.
+	Store         SessionStoreConfig `yaml:"store"`
+	IdleTimeout   time.Duration      `yaml:"idleTimeout"`   // store only, default 1h
+	RefreshBefore time.Duration      `yaml:"refreshBefore"` // store only, default 1m
+}
+
+// SessionStoreConfig selects where sessions are kept.
+type SessionStoreConfig struct {
+	Type sso.StoreType `yaml:"type"` // cookie (default), memory, bolt
+	Path string        `yaml:"path"` // bolt: defaults to ./sessions.db
+}
+
+// ClaimMappingConfig overrides how user ID, email and roles are read from
+// token claims and adds extra claims for authorization policies.
+type ClaimMappingConfig struct {
+	UserID ClaimRuleConfig            `yaml:"userId"` // default sub
+	Email  ClaimRuleConfig            `yaml:"email"`  // default email
+	Roles  ClaimRuleConfig            `yaml:"roles"`  // default depends on the provider
+	Groups ClaimRuleConfig            `yaml:"groups"` // also added to roles; azure defaults to groups while roles is unset
+	Extra  map[string]ClaimRuleConfig `yaml:"extra"`  // added to the auth context claims
+}
+
+// ClaimRuleConfig is a claim path such as "realm_access.roles",
+// `resource_access["app"].roles` or "https://example.com/roles", written
+// either as a plain string or with transforms applied in order.
+type ClaimRuleConfig struct {
+	Path       string                 `yaml:"path"`
+	Transforms []ClaimTransformConfig `yaml:"transforms"`
+}
+
+func (c *ClaimRuleConfig) UnmarshalYAML(node *yaml.Node) error {
+	if node.Kind == yaml.ScalarNode {
+		return node.Decode(&c.Path)
+	}
+	type plain ClaimRuleConfig
+	return node.Decode((*plain)(c))
+}
+
+// ClaimTransformConfig rewrites claim values; set one of stripPrefix, regex
+// or lookup.
+type ClaimTransformConfig struct {
+	StripPrefix   string            `yaml:"stripPrefix"`
+	Regex         string            `yaml:"regex"`
+	Replace       string            `yaml:"replace"` // regex replacement, e.g. "$1"; default keep the value
+	Lookup        map[string]string `yaml:"lookup"`
+	DropUnmatched bool              `yaml:"dropUnmatched"` // drop values the transform does not match
+}
+
+// Mapping compiles the claim mapping.
+func (c ClaimMappingConfig) Mapping() (ssoProviders.ClaimMapping, error) {
+	var m ssoProviders.ClaimMapping
+	var err error
+	if m.UserID, err = c.UserID.rule("userId"); err != nil {
+		return m, err
+	}
+	if m.Email, err = c.Email.rule("email"); err != nil {
+		return m, err
+	}
+	if m.Roles, err = c.Roles.rule("roles"); err != nil {
+		return m, err
+	}
+	if m.Groups, err = c.Groups.rule("groups"); err != nil {
+		return m, err
+	}
+	if len(c.Extra) > 0 {
+		m.Extra = make(map[string]ssoProviders.ClaimRule, len(c.Extra))
+		for name, rc := range c.Extra {
+			if rc.Path == "" {
+				return m, fmt.Errorf("sso.claims.extra.%s needs a path", name)
+			}
+			if m.Extra[name], err = rc.rule("extra." + name); err != nil {
+				return m, err
+			}
+		}
+	}
+	return m, m.Validate()
+}
+
+func (c ClaimRuleConfig) rule(name string) (ssoProviders.ClaimRule, error) {
+	r := ssoProviders.ClaimRule{Path: c.Path}
+	for i, tc := range c.Transforms {
+		set := 0
+		for _, ok := range []bool{tc.StripPrefix != "", tc.Regex != "", tc.Lookup != nil} {
+			if ok {
+				set++
+			}
+		}
+		if set != 1 {
+			return r, fmt.Errorf("sso.claims.%s.transforms[%d] needs exactly one of stripPrefix, regex or lookup", name, i)
+		}
+		if tc.Replace != "" && tc.Regex == "" {
+			return r, fmt.Errorf("sso.claims.%s.transforms[%d] replace needs regex", name, i)
+		}
+		t := ssoProviders.ClaimTransform{
+			StripPrefix:   tc.StripPrefix,
+			Replace:       tc.Replace,
+			Lookup:        tc.Lookup,
+			DropUnmatched: tc.DropUnmatched,
+		}
+		if tc.Regex != "" {
+			re, err := regexp.Compile(tc.Regex)
+			if err != nil {
+				return r, fmt.Errorf("sso.claims.%s.transforms[%d] regex: %w", name, i, err)
+			}
+			t.Pattern = re
+		}
+		r.Transforms = append(r.Transforms, t)
+	}
+	return r, nil
+}
+
+// GoogleGroupsConfig resolves Google Workspace group membership through the
+// Admin SDK Directory API with a domain-wide delegated service account.
+type GoogleGroupsConfig struct {
+	CredentialsFile string        `yaml:"credentialsFile"` // service account key (JSON)
+	AdminEmail      string        `yaml:"adminEmail"`      // admin to impersonate
+	CacheTTL        time.Duration `yaml:"cacheTTL"`        // default 5m
 }
 
 // TelemetryConfig holds generic telemetry settings.
@@ -38,20 +311,188 @@ type TelemetryConfig struct {
 	Service  string                          `yaml:"service"`  // optional service name
 }
 
+// DiscoveryConfig resolves a route's upstream targets from a service registry
+// instead of a static URL.
+type DiscoveryConfig struct {
+	Type       discoveryProviders.ProviderType `yaml:"type"`       // dns, file, consul, kubernetes
+	Service    string                          `yaml:"service"`    // DNS name, registry service name or service.namespace:port
+	Scheme     string                          `yaml:"scheme"`     // http (default) or https
+	Port       int                             `yaml:"port"`       // DNS A/AAAA only
+	RecordType string                          `yaml:"recordType"` // DNS: A, AAAA, SRV (default A+AAAA)
+	Resolver   string                          `yaml:"resolver"`   // DNS: host:port, defaults to resolv.conf
+	Path       string                          `yaml:"path"`       // File: endpoints file
+	Endpoint   string                          `yaml:"endpoint"`   // Consul: agent address
+	Token      string                          `yaml:"token"`      // Consul: ACL token
+	Datacenter string                          `yaml:"datacenter"` // Consul
+	Tag        string                          `yaml:"tag"`        // Consul
+	Kubeconfig string                          `yaml:"kubeconfig"` // Kubernetes: defaults to in-cluster config
+	MinRefresh time.Duration                   `yaml:"minRefresh"` // DNS: lower bound on TTL refresh
+	MaxRefresh time.Duration                   `yaml:"maxRefresh"` // DNS: upper bound on TTL refresh
+}
+
+// UpstreamTLSConfig configures TLS from the gateway to a route's upstream.
+// Certificate and CA files are reloaded when they change on disk.
+type UpstreamTLSConfig struct {
+	CAFile             string   `yaml:"caFile"`             // PEM bundle; system roots when empty
+	CertFile           string   `yaml:"certFile"`           // client certificate for mTLS
+	KeyFile            string   `yaml:"keyFile"`            // client key for mTLS
+	ServerName         string   `yaml:"serverName"`         // SNI and verification name override
+	MinVersion         string   `yaml:"minVersion"`         // "1.2", "1.3"
+	CipherSuites       []string `yaml:"cipherSuites"`       // IANA names, TLS 1.2 and below only
+	InsecureSkipVerify bool     `yaml:"insecureSkipVerify"` // development only
+}
+
 // RouteConfig defines a route and upstream target.
 type RouteConfig struct {
-	Path       string   `yaml:"path"`
-	Upstream   string   `yaml:"upstream"`
-	Scopes     []string `yaml:"scopes"`
-	AuthPolicy string   `yaml:"authPolicy"` // "required" / "optional" / "none"
+	Path             string               `yaml:"path"`
+	Hosts            []string             `yaml:"hosts"`            // optional; "*.example.com" wildcards allowed
+	Upstream         string               `yaml:"upstream"`         // http(s)://host:port or unix:///path/to.sock
+	UpstreamProtocol string               `yaml:"upstreamProtocol"` // "http1" (default) or "h2c"
+	Discovery        *DiscoveryConfig     `yaml:"discovery"`        // replaces upstream when set
+	TLS              *UpstreamTLSConfig   `yaml:"tls"`              // upstream TLS settings
+	HealthCheck      *HealthCheckConfig   `yaml:"healthCheck"`
+	Proxy            *OutboundProxyConfig `yaml:"proxy"`        // reach the upstream through an outbound proxy
+	Scopes           []string             `yaml:"scopes"`       // token scopes or roles, for methods not in methodScopes
+	MethodScopes     map[string][]string  `yaml:"methodScopes"` // per HTTP method, e.g. GET: [read], POST: [write]
+	ScopeMode        string               `yaml:"scopeMode"`    // "any" (default) or "all" of the scopes
+	AuthPolicy       string               `yaml:"authPolicy"`   // "required" / "optional" / "none"
+	Authz            *AuthzConfig         `yaml:"authz"`        // authorization policies, all must allow
+	ExtAuthz         *ExtAuthzConfig      `yaml:"extAuthz"`     // external authorization service, asked last
+}
+
+// ExtAuthzConfig asks an external authorization service about every request
+// of a route before it is proxied.
+type ExtAuthzConfig struct {
+	URL          string        `yaml:"url"`          // http(s):// for JSON, grpc:// or grpcs:// for Envoy ext_authz
+	Timeout      time.Duration `yaml:"timeout"`      // default 1s
+	Headers      []string      `yaml:"headers"`      // request headers sent, default all
+	IncludeBody  bool          `yaml:"includeBody"`  // send the request body
+	MaxBodyBytes int64         `yaml:"maxBodyBytes"` // default 8192
+	FailOpen     bool          `yaml:"failOpen"`     // allow when the service fails; default refuse
+	CacheTTL     time.Duration `yaml:"cacheTTL"`     // cache decisions; 0 disables
+}
+
+// AuthzConfig holds the authorization policies of a route, evaluated after
+// authentication and scopes.
+type AuthzConfig struct {
+	CEL  []CELPolicyConfig  `yaml:"cel"`
+	Rego []RegoPolicyConfig `yaml:"rego"`
+}
+
+// CELPolicyConfig is a CEL expression over request.* and auth.* that must
+// evaluate to true.
+type CELPolicyConfig struct {
+	Name       string `yaml:"name"` // reported in logs and metrics
+	Expression string `yaml:"expression"`
+	Message    string `yaml:"message"` // returned on denial
+}
+
+// RegoPolicyConfig evaluates a Rego bundle loaded from disk. The bundle is
+// reloaded when its directory changes.
+type RegoPolicyConfig struct {
+	Name   string `yaml:"name"`   // reported in logs and metrics
+	Bundle string `yaml:"bundle"` // directory of .rego and data.json files, or a bundle tarball
+	Query  string `yaml:"query"`  // default data.gateway.authz; boolean or {allow, reason}
+	Trace  bool   `yaml:"trace"`  // log evaluation traces at debug level
+}
+
+// OutboundProxyConfig reaches an upstream through an HTTP CONNECT or SOCKS5
+// proxy.
+type OutboundProxyConfig struct {
+	URL      string   `yaml:"url"`      // http://, https:// or socks5://host:port
+	Username string   `yaml:"username"` // overrides credentials in url
+	Password string   `yaml:"password"`
+	NoProxy  []string `yaml:"noProxy"` // NO_PROXY-style: hosts, .domains, CIDRs, host:port
+}
+
+// HealthCheckConfig actively probes upstream targets and takes failing ones
+// out of rotation.
+type HealthCheckConfig struct {
+	Interval           time.Duration `yaml:"interval"`           // default 10s
+	Timeout            time.Duration `yaml:"timeout"`            // default 2s
+	Path               string        `yaml:"path"`               // HTTP routes: GET expecting 2xx/3xx; TCP connect when empty
+	UnhealthyThreshold int           `yaml:"unhealthyThreshold"` // default 3
+	HealthyThreshold   int           `yaml:"healthyThreshold"`   // default 2
+}
+
+// StreamProtocol is the transport proxied by a stream route.
+type StreamProtocol string
+
+const (
+	StreamTCP StreamProtocol = "tcp"
+	StreamUDP StreamProtocol = "udp"
+)
+
+// StreamRouteConfig proxies raw TCP connections or UDP sessions (L4) to an
+// upstream pool. Several TCP routes may share a listen address when they
+// select TLS connections by SNI; the gateway does not terminate TLS. Such a
+// listener waits for each ClientHello, so its default route must be marked
+// tls: server-first protocols like SMTP or SSH would stall there.
+type StreamRouteConfig struct {
+	Name        string             `yaml:"name"`        // used in logs and metrics, defaults to listen
+	Listen      string             `yaml:"listen"`      // e.g. ":5432"
+	Protocol    StreamProtocol     `yaml:"protocol"`    // tcp (default), udp
+	SNI         []string           `yaml:"sni"`         // tcp: TLS passthrough by SNI; "*.example.com" allowed
+	TLS         bool               `yaml:"tls"`         // tcp: clients speak TLS; implied by sni
+	Upstreams   []string           `yaml:"upstreams"`   // host:port
+	Discovery   *DiscoveryConfig   `yaml:"discovery"`   // replaces upstreams when set
+	HealthCheck *HealthCheckConfig `yaml:"healthCheck"` // tcp only
+	IdleTimeout time.Duration      `yaml:"idleTimeout"` // default 5m for tcp, 1m for udp sessions
+}
+
+// IngressConfig enables the Kubernetes ingress controller mode, which adds
+// routes from Gateway API HTTPRoutes (and optionally Ingresses) to the
+// statically configured ones.
+type IngressConfig struct {
+	Enabled          bool   `yaml:"enabled"`
+	Kubeconfig       string `yaml:"kubeconfig"`       // defaults to in-cluster config
+	Namespace        string `yaml:"namespace"`        // empty watches all namespaces
+	ControllerName   string `yaml:"controllerName"`   // GatewayClass spec.controllerName to claim
+	WatchIngress     bool   `yaml:"watchIngress"`     // also translate networking.k8s.io/v1 Ingress
+	IngressClassName string `yaml:"ingressClassName"` // IngressClass handled when watchIngress=true
+	PublishAddress   string `yaml:"publishAddress"`   // IP or hostname written to Ingress status
+}
+
+// EgressConfig runs a forward proxy for outbound calls from internal
+// services. Destinations are checked against the allow and deny rules, and
+// every attempt is logged with the caller's identity.
+type EgressConfig struct {
+	Enabled     bool              `yaml:"enabled"`
+	Address     string            `yaml:"address"` // default ":3128"
+	Allow       EgressRulesConfig `yaml:"allow"`   // empty allows every public destination that is not denied; internal ranges need allow.cidrs
+	Deny        EgressRulesConfig `yaml:"deny"`
+	Auth        EgressAuthConfig  `yaml:"auth"`
+	DialTimeout time.Duration     `yaml:"dialTimeout"` // default 10s
+}
+
+// EgressRulesConfig matches destinations by name, resolved address or port.
+type EgressRulesConfig struct {
+	Hosts []string `yaml:"hosts"` // "api.example.com", "*.example.com"
+	CIDRs []string `yaml:"cidrs"`
+	Ports []int    `yaml:"ports"`
+}
+
+// EgressAuthConfig authenticates callers with Proxy-Authorization. Without
+// either option callers are identified by their address only.
+type EgressAuthConfig struct {
+	SSO   bool               `yaml:"sso"`   // Bearer tokens checked by the sso provider
+	Users []EgressUserConfig `yaml:"users"` // Basic credentials
+}
+
+type EgressUserConfig struct {
+	Username string `yaml:"username"`
+	Password string `yaml:"password"` // plain text or bcrypt hash
 }
 
 // Config is the root configuration struct.
 type Config struct {
-	Server    ServerConfig      `yaml:"server"`
-	SSO       SSOConfig         `yaml:"sso"`
-	Telemetry []TelemetryConfig `yaml:"telemetry"`
-	Routes    []RouteConfig     `yaml:"routes"`
+	Server       ServerConfig        `yaml:"server"`
+	SSO          SSOConfig           `yaml:"sso"`
+	Telemetry    []TelemetryConfig   `yaml:"telemetry"`
+	Routes       []RouteConfig       `yaml:"routes"`
+	StreamRoutes []StreamRouteConfig `yaml:"streamRoutes"`
+	Ingress      IngressConfig       `yaml:"ingress"`
+	Egress       EgressConfig        `yaml:"egress"`
 }
 
 // Load reads YAML config from a file path and applies env overrides.
@@ -77,8 +518,40 @@ func Load(path string) (*Config, error) {
 
 // Validate ensures required fields are set consistently.
 func (c *Config) Validate() error {
-	if c.Server.Port == 0 {
-		return errors.New("server.port must be set")
+	if len(c.Server.Listeners) == 0 {
+		if c.Server.Port == 0 {
+			return errors.New("server.port must be set")
+		}
+		if c.Server.TLSEnabled {
+			if err := c.Server.TLS.validate("server.tls"); err != nil {
+				return err
+			}
+		}
+	}
+	if err := c.validateListeners(); err != nil {
+		return err
+	}
+	if _, err := clientip.ParsePrefixes(c.Server.TrustedProxies); err != nil {
+		return fmt.Errorf("server.trustedProxies: %w", err)
+	}
+	if !clientip.ValidHeader(c.Server.ClientIPHeader) {
+		return fmt.Errorf("server.clientIPHeader must be %s or %s", clientip.HeaderXForwardedFor, clientip.HeaderForwarded)
+	}
+	if c.Server.HTTPRedirect.Enabled && !c.Server.HasTLS() {
+		return errors.New("server.httpRedirect requires a TLS listener")
+	}
+	if h := c.Server.HSTS; h.Enabled {
+		if h.MaxAge != nil && *h.MaxAge < 0 {
+			return errors.New("server.hsts.maxAge cannot be negative")
+		}
+		// Preload lists only accept a max-age of at least one year together
+		// with includeSubDomains.
+		if h.Preload && (!h.IncludeSubDomains || (h.MaxAge != nil && *h.MaxAge < 31536000)) {
+			return errors.New("server.hsts.preload requires includeSubDomains and maxAge of at least 31536000")
+		}
+	}
+	if len(c.Server.Listeners) == 0 && c.Server.TLS.ACME.Enabled && !c.Server.TLSEnabled {
+		return errors.New("server.tls.acme requires server.tlsEnabled=true")
 	}
 
 	// SSO validation
@@ -96,15 +569,56 @@ func (c *Config) Validate() error {
 			return errors.New("sso.redirectUrl is required when sso.enabled=true")
 		}
 		// Provider-specific checks
+		if c.SSO.Type != ssoProviders.ProviderGoogle && (len(c.SSO.HostedDomains) > 0 || c.SSO.GoogleGroups != nil) {
+			return errors.New("sso.hostedDomains and sso.googleGroups are only supported for Google")
+		}
 		switch c.SSO.Type {
 		case ssoProviders.ProviderAzure:
 			if c.SSO.TenantID == "" {
 				return errors.New("sso.tenantId is required for Azure")
 			}
+			multiTenant := azure.IsMultiTenant(c.SSO.TenantID)
+			if multiTenant && len(c.SSO.AllowedTenants) == 0 {
+				return fmt.Errorf("sso.allowedTenants is required with tenantId %q", c.SSO.TenantID)
+			}
+			if !multiTenant && len(c.SSO.AllowedTenants) > 0 {
+				return errors.New("sso.allowedTenants requires tenantId common or organizations")
+			}
+		case ssoProviders.ProviderGoogle:
+			if len(c.SSO.HostedDomains) == 0 {
+				return errors.New("sso.hostedDomains is required for Google; use [\"*\"] to accept any Google account")
+			}
+			if len(c.SSO.HostedDomains) > 1 && slices.Contains(c.SSO.HostedDomains, "*") {
+				return errors.New("sso.hostedDomains \"*\" cannot be combined with other domains")
+			}
+			if g := c.SSO.GoogleGroups; g != nil && (g.CredentialsFile == "" || g.AdminEmail == "") {
+				return errors.New("sso.googleGroups requires credentialsFile and adminEmail")
+			}
 		case ssoProviders.ProviderOkta:
 			if c.SSO.IssuerURL == "" && c.SSO.TenantID == "" {
 				return errors.New("sso.issuerUrl or sso.tenantId is required for Okta")
 			}
+		case ssoProviders.ProviderOIDC:
+			if c.SSO.IssuerURL == "" {
+				return errors.New("sso.issuerUrl is required for oidc")
+			}
+		default:
+			return fmt.Errorf("unknown sso.type: %s", c.SSO.Type)
+		}
+		if err := oidc.ValidateAlgorithms(c.SSO.Algorithms); err != nil {
+			return fmt.Errorf("sso.algorithms: %w", err)
+		}
+		if _, err := c.SSO.Claims.Mapping(); err != nil {
+			return err
+		}
+		if c.SSO.ClockSkew < 0 {
+			return errors.New("sso.clockSkew cannot be negative")
+		}
+		if err := c.SSO.validateIntrospection(); err != nil {
+			return err
+		}
+		if err := c.SSO.validateLogin(); err != nil {
+			return err
 		}
 	}
 
@@ -130,14 +644,482 @@ func (c *Config) Validate() error {
 
 	// Route validation
 	for _, r := range c.Routes {
-		if r.Path == "" {
-			return errors.New("each route must have a path")
+		if err := r.Validate(); err != nil {
+			return err
+		}
+	}
+
+	if err := c.validateStreamRoutes(); err != nil {
+		return err
+	}
+
+	if err := c.validateEgress(); err != nil {
+		return err
+	}
+
+	// Ingress controller validation
+	if c.Ingress.Enabled && c.Ingress.ControllerName == "" {
+		return errors.New("ingress.controllerName is required when ingress.enabled=true")
+	}
+
+	return nil
+}
+
+// validateListeners checks server.listeners and server.admin.
+func (c *Config) validateListeners() error {
+	var acmeCfg *ACMEConfig
+	for i, l := range c.Server.Listeners {
+		name := fmt.Sprintf("server.listeners[%d]", i)
+		if l.Address == "" {
+			return fmt.Errorf("%s.address is required", name)
+		}
+		switch l.Protocol {
+		case "", ProtocolHTTP1:
+		case ProtocolH2C:
+			if l.TLS != nil {
+				return fmt.Errorf("%s: h2c is cleartext and cannot have tls", name)
+			}
+		case ProtocolH2, ProtocolH3:
+			if l.TLS == nil {
+				return fmt.Errorf("%s: %s requires tls", name, l.Protocol)
+			}
+		default:
+			return fmt.Errorf("%s has unknown protocol: %s", name, l.Protocol)
+		}
+		if l.ProxyProtocol && l.Protocol == ProtocolH3 {
+			return fmt.Errorf("%s: proxyProtocol is not supported on h3 listeners", name)
+		}
+		// Without a policy any peer could claim any source address.
+		if l.ProxyProtocol && len(c.Server.TrustedProxies) == 0 {
+			return fmt.Errorf("%s: proxyProtocol requires server.trustedProxies", name)
+		}
+		if l.TLS == nil {
+			continue
+		}
+		if err := l.TLS.validate(name + ".tls"); err != nil {
+			return err
+		}
+		if l.Protocol != ProtocolH2 && l.Protocol != ProtocolH3 && slices.Contains(l.TLS.ALPN, "h2") {
+			return fmt.Errorf("%s.tls.alpn cannot offer h2 on an http1 listener", name)
+		}
+		// All listeners share one ACME account and certificate cache.
+		if a := l.TLS.ACME; a.Enabled {
+			if acmeCfg != nil && !reflect.DeepEqual(*acmeCfg, a) {
+				return fmt.Errorf("%s.tls.acme must match the acme settings of other listeners", name)
+			}
+			acmeCfg = &a
 		}
-		if r.Upstream == "" {
-			return fmt.Errorf("route '%s' must have an upstream", r.Path)
+	}
+	return nil
+}
+
+// HasTLS reports whether any listener terminates TLS.
+func (s ServerConfig) HasTLS() bool {
+	if len(s.Listeners) == 0 {
+		return s.TLSEnabled
+	}
+	for _, l := range s.Listeners {
+		if l.TLS != nil {
+			return true
+		}
+	}
+	return false
+}
+
+func (t ServerTLSConfig) validate(name string) error {
+	for _, cert := range t.Certificates {
+		if cert.CertFile == "" || cert.KeyFile == "" {
+			return fmt.Errorf("%s.certificates entries require certFile and keyFile", name)
+		}
+	}
+	if _, err := tlsutil.ParseVersion(t.MinVersion); err != nil {
+		return fmt.Errorf("%s.minVersion: %w", name, err)
+	}
+	if _, err := tlsutil.ParseVersion(t.MaxVersion); err != nil {
+		return fmt.Errorf("%s.maxVersion: %w", name, err)
+	}
+	if _, err := tlsutil.ParseCipherSuites(t.CipherSuites); err != nil {
+		return fmt.Errorf("%s.cipherSuites: %w", name, err)
+	}
+	if a := t.ACME; a.Enabled {
+		if len(a.Hosts) == 0 {
+			return fmt.Errorf("%s.acme.hosts is required when acme is enabled", name)
+		}
+		if !a.AcceptTOS {
+			return fmt.Errorf("%s.acme.acceptTos must be true to use acme", name)
+		}
+		for _, ch := range a.Challenges {
+			if ch != acme.ChallengeHTTP01 && ch != acme.ChallengeTLSALPN01 {
+				return fmt.Errorf("%s.acme.challenges: unsupported challenge %s", name, ch)
+			}
+		}
+		if len(a.Challenges) > 0 && !slices.Contains(a.Challenges, acme.ChallengeTLSALPN01) {
+			return fmt.Errorf("%s.acme.challenges must include %s, which is always tried first", name, acme.ChallengeTLSALPN01)
+		}
+		switch a.Store.Type {
+		case "", acme.StoreDir, acme.StoreMemory:
+		default:
+			return fmt.Errorf("%s.acme.store.type: unknown store %s", name, a.Store.Type)
+		}
+	}
+	return nil
+}
+
+func (a *AuthzConfig) validate() error {
+	if a == nil {
+		return nil
+	}
+	names := make(map[string]bool)
+	for _, p := range a.CEL {
+		if p.Name == "" {
+			return errors.New("every cel policy needs a name")
+		}
+		if names[p.Name] {
+			return fmt.Errorf("duplicate policy name %s", p.Name)
+		}
+		names[p.Name] = true
+		if _, err := authz.CompileCEL(p.Name, p.Expression, p.Message); err != nil {
+			return err
+		}
+	}
+	for _, p := range a.Rego {
+		if p.Name == "" {
+			return errors.New("every rego policy needs a name")
+		}
+		if names[p.Name] {
+			return fmt.Errorf("duplicate policy name %s", p.Name)
+		}
+		names[p.Name] = true
+		if p.Bundle == "" {
+			return fmt.Errorf("rego policy %s needs a bundle", p.Name)
+		}
+	}
+	return nil
+}
+
+var httpMethods = []string{
+	http.MethodGet, http.MethodHead, http.MethodPost, http.MethodPut, http.MethodPatch,
+	http.MethodDelete, http.MethodConnect, http.MethodOptions, http.MethodTrace,
+}
+
+// upstreamIsIP reports whether the route's targets are dialled by IP
+// address, as discovered ones always are.
+func (r RouteConfig) upstreamIsIP() bool {
+	if r.Discovery != nil {
+		return true
+	}
+	u, err := url.Parse(r.Upstream)
+	return err == nil && net.ParseIP(u.Hostname()) != nil
+}
+
+// Validate checks a single route. It is also used for routes that are not
+// loaded from the config file, such as those produced by the ingress
+// controller.
+func (r RouteConfig) Validate() error {
+	if r.Path == "" {
+		return errors.New("each route must have a path")
+	}
+	if r.Upstream == "" && r.Discovery == nil {
+		return fmt.Errorf("route '%s' must have an upstream or discovery", r.Path)
+	}
+	switch r.AuthPolicy {
+	case "", "none", "optional", "required":
+	default:
+		return fmt.Errorf("route '%s' has unknown authPolicy: %s", r.Path, r.AuthPolicy)
+	}
+	switch r.ScopeMode {
+	case "", "any", "all":
+	default:
+		return fmt.Errorf("route '%s' has unknown scopeMode: %s", r.Path, r.ScopeMode)
+	}
+	for m := range r.MethodScopes {
+		if !slices.Contains(httpMethods, strings.ToUpper(m)) {
+			return fmt.Errorf("route '%s' methodScopes has unknown method: %s", r.Path, m)
+		}
+	}
+	if (len(r.Scopes) > 0 || len(r.MethodScopes) > 0) && r.AuthPolicy == "none" {
+		return fmt.Errorf("route '%s' scopes cannot be enforced with authPolicy none", r.Path)
+	}
+	if err := r.Authz.validate(); err != nil {
+		return fmt.Errorf("route '%s' authz: %w", r.Path, err)
+	}
+	if r.ExtAuthz != nil {
+		u, err := url.Parse(r.ExtAuthz.URL)
+		if err != nil || u.Host == "" {
+			return fmt.Errorf("route '%s' extAuthz.url is invalid: %s", r.Path, r.ExtAuthz.URL)
+		}
+		switch u.Scheme {
+		case "http", "https", "grpc", "grpcs":
+		default:
+			return fmt.Errorf("route '%s' extAuthz.url has unsupported scheme: %s", r.Path, u.Scheme)
+		}
+		if r.ExtAuthz.Timeout < 0 || r.ExtAuthz.CacheTTL < 0 || r.ExtAuthz.MaxBodyBytes < 0 {
+			return fmt.Errorf("route '%s' extAuthz timeout, cacheTTL and maxBodyBytes cannot be negative", r.Path)
+		}
+	}
+	if r.TLS != nil {
+		if (r.TLS.CertFile == "") != (r.TLS.KeyFile == "") {
+			return fmt.Errorf("route '%s' tls.certFile and tls.keyFile must be set together", r.Path)
+		}
+		if _, err := tlsutil.ParseVersion(r.TLS.MinVersion); err != nil {
+			return fmt.Errorf("route '%s' tls.minVersion: %w", r.Path, err)
+		}
+		if _, err := tlsutil.ParseCipherSuites(r.TLS.CipherSuites); err != nil {
+			return fmt.Errorf("route '%s' tls.cipherSuites: %w", r.Path, err)
+		}
+		// Discovered targets are IP addresses, which are never sent as SNI,
+		// so a custom CA needs the name to verify certificates against.
+		if r.TLS.CAFile != "" && !r.TLS.InsecureSkipVerify && r.TLS.ServerName == "" && r.upstreamIsIP() {
+			return fmt.Errorf("route '%s' tls.serverName is required with tls.caFile for IP and discovered upstreams", r.Path)
+		}
+	}
+	upstreamScheme := ""
+	if r.Upstream != "" {
+		u, err := url.Parse(r.Upstream)
+		if err != nil {
+			return fmt.Errorf("route '%s' has invalid upstream: %w", r.Path, err)
+		}
+		if u.Scheme == "unix" && u.Path == "" {
+			return fmt.Errorf("route '%s' unix upstream needs a socket path, e.g. unix:///run/app.sock", r.Path)
+		}
+		upstreamScheme = u.Scheme
+	} else if r.Discovery != nil {
+		upstreamScheme = r.Discovery.Scheme
+	}
+	switch r.UpstreamProtocol {
+	case "", "http1":
+	case "h2c":
+		if r.TLS != nil || upstreamScheme == "https" {
+			return fmt.Errorf("route '%s' upstreamProtocol h2c cannot be used with an https upstream", r.Path)
+		}
+	default:
+		return fmt.Errorf("route '%s' has unknown upstreamProtocol: %s", r.Path, r.UpstreamProtocol)
+	}
+	if p := r.Proxy; p != nil {
+		if upstreamScheme == "unix" {
+			return fmt.Errorf("route '%s' cannot use proxy with a unix upstream", r.Path)
+		}
+		u, err := url.Parse(p.URL)
+		if err != nil || u.Host == "" {
+			return fmt.Errorf("route '%s' proxy.url must be an absolute URL", r.Path)
+		}
+		switch u.Scheme {
+		case "http", "https", "socks5", "socks5h":
+		default:
+			return fmt.Errorf("route '%s' proxy.url has unsupported scheme: %s", r.Path, u.Scheme)
+		}
+		// TCP connect probes cannot go through the proxy.
+		if r.HealthCheck != nil && r.HealthCheck.Path == "" {
+			return fmt.Errorf("route '%s' healthCheck.path is required with proxy", r.Path)
+		}
+	}
+	if r.Discovery != nil {
+		if r.Upstream != "" {
+			return fmt.Errorf("route '%s' cannot set both upstream and discovery", r.Path)
+		}
+		if err := r.Discovery.validate(); err != nil {
+			return fmt.Errorf("route '%s' %w", r.Path, err)
 		}
 	}
+	return nil
+}
+
+func (d *DiscoveryConfig) validate() error {
+	if d.Service == "" {
+		return errors.New("discovery.service is required")
+	}
+	switch d.Type {
+	case discoveryProviders.ProviderDNS:
+		if !strings.EqualFold(d.RecordType, "SRV") && d.Port == 0 {
+			return errors.New("discovery.port is required for dns A/AAAA lookups")
+		}
+	case discoveryProviders.ProviderFile:
+		if d.Path == "" {
+			return errors.New("discovery.path is required for file discovery")
+		}
+	case discoveryProviders.ProviderConsul:
+	case discoveryProviders.ProviderK8s:
+		if _, _, _, err := kubernetes.ParseService(d.Service); err != nil {
+			return err
+		}
+	default:
+		return fmt.Errorf("unknown discovery type: %s", d.Type)
+	}
+	return nil
+}
 
+func (s SSOConfig) validateIntrospection() error {
+	in := s.Introspection
+	if in == nil {
+		return nil
+	}
+	if s.Type != ssoProviders.ProviderOkta && s.Type != ssoProviders.ProviderOIDC {
+		return errors.New("sso.introspection is only supported for okta and oidc")
+	}
+	if in.URL != "" {
+		if u, err := url.Parse(in.URL); err != nil || !u.IsAbs() {
+			return errors.New("sso.introspection.url must be an absolute URL")
+		}
+	}
+	if (in.ClientID == "") != (in.ClientSecret == "") {
+		return errors.New("sso.introspection.clientId and clientSecret must be set together")
+	}
+	switch in.AuthMethod {
+	case "", ssoProviders.ClientSecretBasic, ssoProviders.ClientSecretPost:
+	default:
+		return fmt.Errorf("sso.introspection.authMethod must be %s or %s", ssoProviders.ClientSecretBasic, ssoProviders.ClientSecretPost)
+	}
+	if in.CacheTTL < 0 {
+		return errors.New("sso.introspection.cacheTTL cannot be negative")
+	}
+	return nil
+}
+
+func (s SSOConfig) validateLogin() error {
+	redirect, err := url.Parse(s.RedirectURL)
+	if err != nil || !redirect.IsAbs() || redirect.Path == "" {
+		return errors.New("sso.redirectUrl must be an absolute URL with a callback path")
+	}
+	loginPath := s.LoginPath
+	if loginPath == "" {
+		loginPath = "/login"
+	}
+	if !strings.HasPrefix(loginPath, "/") {
+		return errors.New("sso.loginPath must start with /")
+	}
+	logoutPath := s.Logout.Path
+	if logoutPath == "" {
+		logoutPath = "/logout"
+	}
+	paths := []string{loginPath, redirect.Path, logoutPath}
+	if bc := s.Logout.BackChannelPath; bc != "" {
+		paths = append(paths, bc)
+		if s.Session.Store.Type == "" || s.Session.Store.Type == sso.StoreCookie {
+			return errors.New("sso.logout.backChannelPath requires sso.session.store")
+		}
+	}
+	for i, p := range paths {
+		if !strings.HasPrefix(p, "/") {
+			return fmt.Errorf("sso path %q must start with /", p)
+		}
+		if slices.Contains(paths[:i], p) {
+			return fmt.Errorf("sso login, callback and logout paths must differ: %s", p)
+		}
+	}
+	if s.Logout.RedirectURL != "" {
+		if u, err := url.Parse(s.Logout.RedirectURL); err != nil || !u.IsAbs() {
+			return errors.New("sso.logout.redirectUrl must be an absolute URL")
+		}
+	}
+	if s.Session.Secret != "" && len(s.Session.Secret) < 32 {
+		return errors.New("sso.session.secret must be at least 32 characters")
+	}
+	if s.Session.MaxAge < 0 {
+		return errors.New("sso.session.maxAge cannot be negative")
+	}
+	switch s.Session.Store.Type {
+	case "", sso.StoreCookie, sso.StoreMemory, sso.StoreBolt:
+	default:
+		return fmt.Errorf("unknown sso.session.store.type: %s", s.Session.Store.Type)
+	}
+	if s.Session.IdleTimeout < 0 || s.Session.RefreshBefore < 0 {
+		return errors.New("sso.session.idleTimeout and refreshBefore cannot be negative")
+	}
+	return nil
+}
+
+// validateStreamRoutes checks streamRoutes. TCP routes sharing a listen
+// address are told apart by SNI, so at most one of them may omit it.
+func (c *Config) validateStreamRoutes() error {
+	defaults := make(map[string]bool)
+	udp := make(map[string]bool)
+	sni := make(map[string]bool)
+	for _, r := range c.StreamRoutes {
+		if len(r.SNI) > 0 {
+			sni[r.Listen] = true
+		}
+	}
+
+	for i, r := range c.StreamRoutes {
+		name := r.Name
+		if name == "" {
+			name = fmt.Sprintf("streamRoutes[%d]", i)
+		}
+		if r.Listen == "" {
+			return fmt.Errorf("stream route '%s' must have a listen address", name)
+		}
+		if len(r.Upstreams) == 0 && r.Discovery == nil {
+			return fmt.Errorf("stream route '%s' must have upstreams or discovery", name)
+		}
+		if len(r.Upstreams) > 0 && r.Discovery != nil {
+			return fmt.Errorf("stream route '%s' cannot set both upstreams and discovery", name)
+		}
+		for _, u := range r.Upstreams {
+			if _, _, err := net.SplitHostPort(u); err != nil {
+				return fmt.Errorf("stream route '%s' upstream %q must be host:port", name, u)
+			}
+		}
+		if r.Discovery != nil {
+			if err := r.Discovery.validate(); err != nil {
+				return fmt.Errorf("stream route '%s' %w", name, err)
+			}
+		}
+		if r.HealthCheck != nil && r.HealthCheck.Path != "" {
+			return fmt.Errorf("stream route '%s' healthCheck.path is only supported on HTTP routes", name)
+		}
+
+		switch r.Protocol {
+		case "", StreamTCP:
+			if len(r.SNI) == 0 {
+				if defaults[r.Listen] {
+					return fmt.Errorf("stream route '%s': only one route without sni may listen on %s", name, r.Listen)
+				}
+				defaults[r.Listen] = true
+				if sni[r.Listen] && !r.TLS {
+					return fmt.Errorf("stream route '%s': %s routes by sni, so its default route must set tls: true; give other protocols their own listen address", name, r.Listen)
+				}
+			}
+		case StreamUDP:
+			if len(r.SNI) > 0 || r.TLS {
+				return fmt.Errorf("stream route '%s': sni and tls require protocol tcp", name)
+			}
+			if r.HealthCheck != nil {
+				return fmt.Errorf("stream route '%s': healthCheck is not supported for udp", name)
+			}
+			if udp[r.Listen] {
+				return fmt.Errorf("stream route '%s': another udp route listens on %s", name, r.Listen)
+			}
+			udp[r.Listen] = true
+		default:
+			return fmt.Errorf("stream route '%s' has unknown protocol: %s", name, r.Protocol)
+		}
+	}
+	return nil
+}
+
+func (c *Config) validateEgress() error {
+	e := c.Egress
+	if !e.Enabled {
+		return nil
+	}
+	for _, rules := range []EgressRulesConfig{e.Allow, e.Deny} {
+		if _, err := clientip.ParsePrefixes(rules.CIDRs); err != nil {
+			return fmt.Errorf("egress cidrs: %w", err)
+		}
+		for _, port := range rules.Ports {
+			if port < 1 || port > 65535 {
+				return fmt.Errorf("egress ports: invalid port %d", port)
+			}
+		}
+	}
+	if e.Auth.SSO && !c.SSO.Enabled {
+		return errors.New("egress.auth.sso requires sso.enabled=true")
+	}
+	for _, u := range e.Auth.Users {
+		if u.Username == "" || u.Password == "" {
+			return errors.New("egress.auth.users entries require username and password")
+		}
+	}
 	return nil
 }
 
@@ -175,6 +1157,9 @@ func applyEnvOverrides(cfg *Config) {
 	if val := os.Getenv("SSO_ISSUER_URL"); val != "" {
 		cfg.SSO.IssuerURL = val
 	}
+	if val := os.Getenv("SSO_SESSION_SECRET"); val != "" {
+		cfg.SSO.Session.Secret = val
+	}
 
 	// Telemetry overrides
 	for i := range cfg.Telemetry {
@@ -0,0 +1,157 @@
+package discovery
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"slices"
+	"sync"
+	"sync/atomic"
+
+	"github.com/rs/zerolog/log"
+	"github.com/shrihariharanba/go-gateway/internal/discovery/providers"
+	"github.com/shrihariharanba/go-gateway/internal/discovery/providers/consul"
+	"github.com/shrihariharanba/go-gateway/internal/discovery/providers/dns"
+	"github.com/shrihariharanba/go-gateway/internal/discovery/providers/file"
+	"github.com/shrihariharanba/go-gateway/internal/discovery/providers/kubernetes"
+)
+
+func NewProvider(cfg providers.Config) (providers.DiscoveryProvider, error) {
+	switch cfg.Type {
+	case providers.ProviderDNS:
+		return dns.New(cfg)
+	case providers.ProviderFile:
+		return file.New(cfg)
+	case providers.ProviderConsul:
+		return consul.New(cfg)
+	case providers.ProviderK8s:
+		return kubernetes.New(cfg)
+	default:
+		return nil, fmt.Errorf("unknown discovery provider: %s", cfg.Type)
+	}
+}
+
+// Service keeps the live target set of a discovered upstream and satisfies
+// proxy.Upstream.
+type Service struct {
+	provider providers.DiscoveryProvider
+	scheme   string
+	targets  atomic.Pointer[[]*url.URL]
+
+	mu      sync.Mutex // serializes updates from Start and the watch
+	current []providers.Target
+}
+
+func NewService(provider providers.DiscoveryProvider, scheme string) *Service {
+	if scheme == "" {
+		scheme = "http"
+	}
+	s := &Service{provider: provider, scheme: scheme}
+	s.targets.Store(&[]*url.URL{})
+	return s
+}
+
+// Start performs an initial resolution and keeps the target set up to date in
+// the background until ctx is cancelled. A failed initial resolution is
+// logged rather than returned so the gateway can start while a registry is
+// temporarily unavailable.
+func (s *Service) Start(ctx context.Context) {
+	targets, err := s.provider.Resolve(ctx)
+	if err != nil {
+		log.Warn().Err(err).Str("provider", s.provider.Name()).Msg("Initial service discovery failed")
+	} else {
+		s.update(targets)
+	}
+
+	go func() {
+		if err := s.provider.Watch(ctx, s.update); err != nil {
+			log.Error().Err(err).Str("provider", s.provider.Name()).Msg("Service discovery watch stopped")
+		}
+	}()
+}
+
+// Targets returns the current upstream URLs.
+func (s *Service) Targets() []*url.URL {
+	return *s.targets.Load()
+}
+
+func (s *Service) update(targets []providers.Target) {
+	sorted := slices.Clone(targets)
+	slices.SortFunc(sorted, func(a, b providers.Target) int {
+		if a.Address < b.Address {
+			return -1
+		}
+		if a.Address > b.Address {
+			return 1
+		}
+		return a.Weight - b.Weight
+	})
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if slices.Equal(sorted, s.current) {
+		return
+	}
+	s.current = sorted
+
+	urls := weighted(sorted, s.scheme)
+	s.targets.Store(&urls)
+
+	log.Info().
+		Str("provider", s.provider.Name()).
+		Int("targets", len(sorted)).
+		Msg("Upstream targets updated")
+}
+
+// maxWeight bounds the share of one target in the expanded target list.
+const maxWeight = 100
+
+// weighted turns targets into the list the round-robin balancer walks: each
+// target appears in proportion to its weight, spread out by smooth weighted
+// round robin. Weights are scaled down to at most maxWeight and reduced by
+// their common divisor, so equal weights give one entry per target.
+func weighted(targets []providers.Target, scheme string) []*url.URL {
+	top := 0
+	for _, t := range targets {
+		top = max(top, t.Weight)
+	}
+	weights := make([]int, len(targets))
+	g := 0
+	for i, t := range targets {
+		w := max(t.Weight, 1)
+		if top > maxWeight {
+			w = max(w*maxWeight/top, 1)
+		}
+		weights[i] = w
+		g = gcd(g, w)
+	}
+
+	urls := make([]*url.URL, len(targets))
+	total := 0
+	for i, t := range targets {
+		urls[i] = &url.URL{Scheme: scheme, Host: t.Address}
+		weights[i] /= g
+		total += weights[i]
+	}
+
+	out := make([]*url.URL, 0, total)
+	current := make([]int, len(targets))
+	for range total {
+		best := 0
+		for i, w := range weights {
+			current[i] += w
+			if current[i] > current[best] {
+				best = i
+			}
+		}
+		current[best] -= total
+		out = append(out, urls[best])
+	}
+	return out
+}
+
+func gcd(a, b int) int {
+	for b != 0 {
+		a, b = b, a%b
+	}
+	return a
+}
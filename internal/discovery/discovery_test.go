@@ -0,0 +1,61 @@
+package discovery
+
+import (
+	"testing"
+
+	"github.com/shrihariharanba/go-gateway/internal/discovery/providers"
+)
+
+func TestWeighted(t *testing.T) {
+	tests := []struct {
+		name    string
+		targets []providers.Target
+		want    map[string]int
+	}{
+		{
+			name:    "equal weights",
+			targets: []providers.Target{{Address: "a:1", Weight: 5}, {Address: "b:1", Weight: 5}},
+			want:    map[string]int{"a:1": 1, "b:1": 1},
+		},
+		{
+			name:    "proportional",
+			targets: []providers.Target{{Address: "a:1", Weight: 30}, {Address: "b:1", Weight: 10}},
+			want:    map[string]int{"a:1": 3, "b:1": 1},
+		},
+		{
+			name:    "scaled down",
+			targets: []providers.Target{{Address: "a:1", Weight: 60000}, {Address: "b:1", Weight: 1}},
+			want:    map[string]int{"a:1": 100, "b:1": 1},
+		},
+		{
+			name:    "zero weight counts as one",
+			targets: []providers.Target{{Address: "a:1"}, {Address: "b:1", Weight: 2}},
+			want:    map[string]int{"a:1": 1, "b:1": 2},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			counts := make(map[string]int)
+			for _, u := range weighted(tt.targets, "http") {
+				counts[u.Host]++
+			}
+			if len(counts) != len(tt.want) {
+				t.Fatalf("counts = %v, want %v", counts, tt.want)
+			}
+			for host, n := range tt.want {
+				if counts[host] != n {
+					t.Errorf("counts = %v, want %v", counts, tt.want)
+				}
+			}
+		})
+	}
+}
+
+func TestWeightedInterleaves(t *testing.T) {
+	urls := weighted([]providers.Target{{Address: "a:1", Weight: 2}, {Address: "b:1", Weight: 2}, {Address: "c:1", Weight: 1}}, "http")
+	for i := 1; i < len(urls); i++ {
+		if urls[i] == urls[i-1] {
+			t.Errorf("target %s repeated back to back in %v", urls[i].Host, urls)
+		}
+	}
+}
@@ -0,0 +1,171 @@
+package consul
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/rs/zerolog/log"
+	"github.com/shrihariharanba/go-gateway/internal/discovery/providers"
+)
+
+const (
+	defaultEndpoint = "http://127.0.0.1:8500"
+	blockingWait    = 5 * time.Minute
+	retryDelay      = 5 * time.Second
+)
+
+// serviceEntry is the subset of a /v1/health/service response entry used to
+// build targets.
+type serviceEntry struct {
+	Node struct {
+		Address string `json:"Address"`
+	} `json:"Node"`
+	Service struct {
+		Address string `json:"Address"`
+		Port    int    `json:"Port"`
+		Weights struct {
+			Passing int `json:"Passing"`
+		} `json:"Weights"`
+	} `json:"Service"`
+}
+
+type ConsulProvider struct {
+	cfg      providers.Config
+	endpoint string
+	client   *http.Client
+}
+
+func New(cfg providers.Config) (providers.DiscoveryProvider, error) {
+	if cfg.Service == "" {
+		return nil, errors.New("consul discovery requires a service name")
+	}
+
+	endpoint := cfg.Endpoint
+	if endpoint == "" {
+		endpoint = defaultEndpoint
+	}
+	if !strings.Contains(endpoint, "://") {
+		endpoint = "http://" + endpoint
+	}
+
+	return &ConsulProvider{
+		cfg:      cfg,
+		endpoint: strings.TrimRight(endpoint, "/"),
+		client:   &http.Client{Timeout: blockingWait + 30*time.Second},
+	}, nil
+}
+
+func (c *ConsulProvider) Resolve(ctx context.Context) ([]providers.Target, error) {
+	targets, _, err := c.fetch(ctx, 0)
+	return targets, err
+}
+
+// Watch long-polls the catalog with Consul blocking queries so changes are
+// picked up as soon as the agent observes them.
+func (c *ConsulProvider) Watch(ctx context.Context, update func([]providers.Target)) error {
+	var index uint64
+	for {
+		targets, next, err := c.fetch(ctx, index)
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			log.Warn().Err(err).Str("service", c.cfg.Service).Msg("Consul discovery query failed")
+			select {
+			case <-ctx.Done():
+				return nil
+			case <-time.After(retryDelay):
+			}
+			continue
+		}
+
+		// Without an index the server cannot block, so fall back to polling.
+		if next == 0 {
+			update(targets)
+			select {
+			case <-ctx.Done():
+				return nil
+			case <-time.After(retryDelay):
+			}
+			continue
+		}
+
+		// Consul requires resetting the index when it goes backwards
+		// (e.g. after a snapshot restore).
+		if next < index {
+			next = 0
+		}
+		if next != index {
+			update(targets)
+		}
+		index = next
+	}
+}
+
+func (c *ConsulProvider) Name() string { return "consul" }
+
+func (c *ConsulProvider) fetch(ctx context.Context, index uint64) ([]providers.Target, uint64, error) {
+	q := url.Values{}
+	q.Set("passing", "true")
+	if c.cfg.Datacenter != "" {
+		q.Set("dc", c.cfg.Datacenter)
+	}
+	if c.cfg.Tag != "" {
+		q.Set("tag", c.cfg.Tag)
+	}
+	if index > 0 {
+		q.Set("index", strconv.FormatUint(index, 10))
+		q.Set("wait", blockingWait.String())
+	}
+
+	u := fmt.Sprintf("%s/v1/health/service/%s?%s", c.endpoint, url.PathEscape(c.cfg.Service), q.Encode())
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		return nil, 0, err
+	}
+	if c.cfg.Token != "" {
+		req.Header.Set("X-Consul-Token", c.cfg.Token)
+	}
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return nil, 0, fmt.Errorf("consul request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, 0, fmt.Errorf("consul returned status %d", resp.StatusCode)
+	}
+
+	var entries []serviceEntry
+	if err := json.NewDecoder(resp.Body).Decode(&entries); err != nil {
+		return nil, 0, fmt.Errorf("failed to decode consul response: %w", err)
+	}
+
+	next, _ := strconv.ParseUint(resp.Header.Get("X-Consul-Index"), 10, 64)
+
+	targets := make([]providers.Target, 0, len(entries))
+	for _, e := range entries {
+		host := e.Service.Address
+		if host == "" {
+			host = e.Node.Address
+		}
+		weight := e.Service.Weights.Passing
+		if weight == 0 {
+			weight = 1
+		}
+		targets = append(targets, providers.Target{
+			Address: net.JoinHostPort(host, strconv.Itoa(e.Service.Port)),
+			Weight:  weight,
+		})
+	}
+	return targets, next, nil
+}
@@ -0,0 +1,109 @@
+package consul
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/shrihariharanba/go-gateway/internal/discovery/providers"
+)
+
+func TestResolve(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/health/service/api" {
+			t.Errorf("path = %s", r.URL.Path)
+		}
+		q := r.URL.Query()
+		if q.Get("passing") != "true" || q.Get("dc") != "dc2" || q.Get("tag") != "v1" {
+			t.Errorf("query = %s", r.URL.RawQuery)
+		}
+		if got := r.Header.Get("X-Consul-Token"); got != "secret" {
+			t.Errorf("token = %q", got)
+		}
+		w.Header().Set("X-Consul-Index", "7")
+		w.Write([]byte(`[
+			{"Node": {"Address": "10.0.0.1"}, "Service": {"Port": 8080, "Weights": {"Passing": 3}}},
+			{"Node": {"Address": "10.0.0.2"}, "Service": {"Address": "10.1.0.2", "Port": 9090}}
+		]`))
+	}))
+	defer srv.Close()
+
+	p, err := New(providers.Config{Service: "api", Endpoint: srv.URL, Token: "secret", Datacenter: "dc2", Tag: "v1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := p.Resolve(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []providers.Target{
+		{Address: "10.0.0.1:8080", Weight: 3},
+		{Address: "10.1.0.2:9090", Weight: 1},
+	}
+	if !slices.Equal(got, want) {
+		t.Errorf("targets = %v, want %v", got, want)
+	}
+}
+
+func TestResolveError(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "no leader", http.StatusInternalServerError)
+	}))
+	defer srv.Close()
+
+	p, _ := New(providers.Config{Service: "api", Endpoint: srv.URL})
+	if _, err := p.Resolve(context.Background()); err == nil {
+		t.Fatal("expected an error for status 500")
+	}
+}
+
+// TestWatchBlocking checks that Watch passes the last index back and only
+// reports a changed index.
+func TestWatchBlocking(t *testing.T) {
+	var mu sync.Mutex
+	var indexes []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		indexes = append(indexes, r.URL.Query().Get("index"))
+		n := len(indexes)
+		mu.Unlock()
+
+		switch n {
+		case 1, 2:
+			w.Header().Set("X-Consul-Index", "5")
+			w.Write([]byte(`[{"Node": {"Address": "10.0.0.1"}, "Service": {"Port": 80}}]`))
+		default:
+			w.Header().Set("X-Consul-Index", "6")
+			w.Write([]byte(`[{"Node": {"Address": "10.0.0.2"}, "Service": {"Port": 80}}]`))
+		}
+	}))
+	defer srv.Close()
+
+	p, _ := New(providers.Config{Service: "api", Endpoint: srv.URL})
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	var updates [][]providers.Target
+	p.Watch(ctx, func(targets []providers.Target) {
+		updates = append(updates, targets)
+		if len(updates) == 2 {
+			cancel()
+		}
+	})
+
+	if len(updates) != 2 {
+		t.Fatalf("got %d updates, want 2", len(updates))
+	}
+	if updates[0][0].Address != "10.0.0.1:80" || updates[1][0].Address != "10.0.0.2:80" {
+		t.Errorf("updates = %v", updates)
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if !slices.Equal(indexes[:3], []string{"", "5", "5"}) {
+		t.Errorf("indexes = %v", indexes)
+	}
+}
@@ -0,0 +1,272 @@
+package dns
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/miekg/dns"
+	"github.com/rs/zerolog/log"
+	"github.com/shrihariharanba/go-gateway/internal/discovery/providers"
+)
+
+const (
+	defaultMinRefresh = 5 * time.Second
+	defaultMaxRefresh = 5 * time.Minute
+	resolvConf        = "/etc/resolv.conf"
+)
+
+type DNSProvider struct {
+	cfg     providers.Config
+	client  *dns.Client
+	servers []string
+}
+
+func New(cfg providers.Config) (providers.DiscoveryProvider, error) {
+	if cfg.Service == "" {
+		return nil, errors.New("dns discovery requires a service name")
+	}
+
+	switch strings.ToUpper(cfg.RecordType) {
+	case "", "A", "AAAA":
+		if cfg.Port == 0 {
+			return nil, fmt.Errorf("dns discovery for %s requires a port for A/AAAA records", cfg.Service)
+		}
+	case "SRV":
+	default:
+		return nil, fmt.Errorf("unsupported dns record type: %s", cfg.RecordType)
+	}
+
+	if cfg.MinRefresh <= 0 {
+		cfg.MinRefresh = defaultMinRefresh
+	}
+	if cfg.MaxRefresh <= 0 {
+		cfg.MaxRefresh = defaultMaxRefresh
+	}
+
+	var servers []string
+	if cfg.Resolver != "" {
+		servers = []string{cfg.Resolver}
+	} else {
+		conf, err := dns.ClientConfigFromFile(resolvConf)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s: %w", resolvConf, err)
+		}
+		for _, s := range conf.Servers {
+			servers = append(servers, net.JoinHostPort(s, conf.Port))
+		}
+	}
+	if len(servers) == 0 {
+		return nil, errors.New("dns discovery found no resolvers")
+	}
+
+	return &DNSProvider{
+		cfg:     cfg,
+		client:  &dns.Client{Timeout: 5 * time.Second},
+		servers: servers,
+	}, nil
+}
+
+func (d *DNSProvider) Resolve(ctx context.Context) ([]providers.Target, error) {
+	targets, _, err := d.lookup(ctx)
+	return targets, err
+}
+
+// Watch re-resolves the service once the shortest record TTL expires, clamped
+// to the configured refresh bounds.
+func (d *DNSProvider) Watch(ctx context.Context, update func([]providers.Target)) error {
+	for {
+		targets, ttl, err := d.lookup(ctx)
+		wait := d.clamp(ttl)
+		if err != nil {
+			log.Warn().Err(err).Str("service", d.cfg.Service).Msg("DNS discovery lookup failed")
+			wait = d.cfg.MinRefresh
+		} else {
+			update(targets)
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(wait):
+		}
+	}
+}
+
+func (d *DNSProvider) Name() string { return "dns" }
+
+func (d *DNSProvider) clamp(ttl time.Duration) time.Duration {
+	if ttl < d.cfg.MinRefresh {
+		return d.cfg.MinRefresh
+	}
+	if ttl > d.cfg.MaxRefresh {
+		return d.cfg.MaxRefresh
+	}
+	return ttl
+}
+
+// lookup resolves the service and returns its targets with the lowest TTL
+// seen across all records involved.
+func (d *DNSProvider) lookup(ctx context.Context) ([]providers.Target, time.Duration, error) {
+	switch strings.ToUpper(d.cfg.RecordType) {
+	case "SRV":
+		return d.lookupSRV(ctx)
+	case "A":
+		return d.lookupHost(ctx, d.cfg.Service, d.cfg.Port, 1, dns.TypeA)
+	case "AAAA":
+		return d.lookupHost(ctx, d.cfg.Service, d.cfg.Port, 1, dns.TypeAAAA)
+	default:
+		return d.lookupHost(ctx, d.cfg.Service, d.cfg.Port, 1, dns.TypeA, dns.TypeAAAA)
+	}
+}
+
+// lookupSRV resolves the records of the lowest priority that has any
+// address; records of higher priority values are backups (RFC 2782) and
+// take no traffic while it does. Weights are kept for the balancer.
+func (d *DNSProvider) lookupSRV(ctx context.Context) ([]providers.Target, time.Duration, error) {
+	msg, err := d.query(ctx, d.cfg.Service, dns.TypeSRV)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	ttl := time.Duration(0)
+	byPriority := make(map[uint16][]*dns.SRV)
+	var priorities []uint16
+	for _, rr := range msg.Answer {
+		srv, ok := rr.(*dns.SRV)
+		if !ok {
+			continue
+		}
+		ttl = minTTL(ttl, rr.Header().Ttl)
+		if _, ok := byPriority[srv.Priority]; !ok {
+			priorities = append(priorities, srv.Priority)
+		}
+		byPriority[srv.Priority] = append(byPriority[srv.Priority], srv)
+	}
+	slices.Sort(priorities)
+
+	for _, prio := range priorities {
+		var targets []providers.Target
+		for _, srv := range byPriority[prio] {
+			resolved, hostTTL, err := d.resolveSRV(ctx, msg, srv)
+			if err != nil {
+				return nil, 0, err
+			}
+			ttl = minDuration(ttl, hostTTL)
+			targets = append(targets, resolved...)
+		}
+		if len(targets) > 0 {
+			return targets, ttl, nil
+		}
+	}
+	return nil, ttl, nil
+}
+
+// resolveSRV returns the addresses of an SRV target, preferring glue
+// records from the additional section over extra queries.
+func (d *DNSProvider) resolveSRV(ctx context.Context, msg *dns.Msg, srv *dns.SRV) ([]providers.Target, time.Duration, error) {
+	weight := srvWeight(srv.Weight)
+	ttl := time.Duration(0)
+	var glued []providers.Target
+	for _, extra := range msg.Extra {
+		if !strings.EqualFold(extra.Header().Name, srv.Target) {
+			continue
+		}
+		if ip := recordIP(extra); ip != nil {
+			ttl = minTTL(ttl, extra.Header().Ttl)
+			glued = append(glued, providers.Target{
+				Address: net.JoinHostPort(ip.String(), strconv.Itoa(int(srv.Port))),
+				Weight:  weight,
+			})
+		}
+	}
+	if len(glued) > 0 {
+		return glued, ttl, nil
+	}
+	return d.lookupHost(ctx, srv.Target, int(srv.Port), weight, dns.TypeA, dns.TypeAAAA)
+}
+
+// srvWeight maps an SRV weight to a balancer weight. Weight 0 records get
+// the smallest share rather than none, as RFC 2782 asks.
+func srvWeight(w uint16) int {
+	if w == 0 {
+		return 1
+	}
+	return int(w)
+}
+
+func (d *DNSProvider) lookupHost(ctx context.Context, host string, port, weight int, qtypes ...uint16) ([]providers.Target, time.Duration, error) {
+	ttl := time.Duration(0)
+	var targets []providers.Target
+	for _, qtype := range qtypes {
+		msg, err := d.query(ctx, host, qtype)
+		if err != nil {
+			return nil, 0, err
+		}
+		for _, rr := range msg.Answer {
+			ip := recordIP(rr)
+			if ip == nil {
+				continue
+			}
+			ttl = minTTL(ttl, rr.Header().Ttl)
+			targets = append(targets, providers.Target{
+				Address: net.JoinHostPort(ip.String(), strconv.Itoa(port)),
+				Weight:  weight,
+			})
+		}
+	}
+	return targets, ttl, nil
+}
+
+// query sends the question to each configured resolver in turn, retrying over
+// TCP when a UDP answer is truncated.
+func (d *DNSProvider) query(ctx context.Context, name string, qtype uint16) (*dns.Msg, error) {
+	m := new(dns.Msg)
+	m.SetQuestion(dns.Fqdn(name), qtype)
+	m.RecursionDesired = true
+
+	var lastErr error
+	for _, server := range d.servers {
+		resp, _, err := d.client.ExchangeContext(ctx, m, server)
+		if err == nil && resp.Truncated {
+			tcp := &dns.Client{Net: "tcp", Timeout: d.client.Timeout}
+			resp, _, err = tcp.ExchangeContext(ctx, m, server)
+		}
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		if resp.Rcode != dns.RcodeSuccess && resp.Rcode != dns.RcodeNameError {
+			lastErr = fmt.Errorf("dns query %s %s failed: %s", name, dns.TypeToString[qtype], dns.RcodeToString[resp.Rcode])
+			continue
+		}
+		return resp, nil
+	}
+	return nil, fmt.Errorf("dns query %s %s failed: %w", name, dns.TypeToString[qtype], lastErr)
+}
+
+func recordIP(rr dns.RR) net.IP {
+	switch r := rr.(type) {
+	case *dns.A:
+		return r.A
+	case *dns.AAAA:
+		return r.AAAA
+	}
+	return nil
+}
+
+func minTTL(current time.Duration, ttl uint32) time.Duration {
+	return minDuration(current, time.Duration(ttl)*time.Second)
+}
+
+func minDuration(current, next time.Duration) time.Duration {
+	if current == 0 || (next > 0 && next < current) {
+		return next
+	}
+	return current
+}
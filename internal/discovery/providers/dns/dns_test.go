@@ -0,0 +1,98 @@
+package dns
+
+import (
+	"context"
+	"net"
+	"slices"
+	"testing"
+
+	"github.com/miekg/dns"
+
+	"github.com/shrihariharanba/go-gateway/internal/discovery/providers"
+)
+
+// serve answers SRV queries for _api._tcp.example. from records and A
+// queries from hosts, on a local UDP port.
+func serve(t *testing.T, records []dns.RR, hosts map[string]string) string {
+	t.Helper()
+	pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := &dns.Server{PacketConn: pc, Handler: dns.HandlerFunc(func(w dns.ResponseWriter, req *dns.Msg) {
+		m := new(dns.Msg)
+		m.SetReply(req)
+		q := req.Question[0]
+		switch q.Qtype {
+		case dns.TypeSRV:
+			m.Answer = records
+		case dns.TypeA:
+			if ip, ok := hosts[q.Name]; ok {
+				m.Answer = []dns.RR{&dns.A{
+					Hdr: dns.RR_Header{Name: q.Name, Rrtype: dns.TypeA, Class: dns.ClassINET, Ttl: 30},
+					A:   net.ParseIP(ip),
+				}}
+			}
+		}
+		w.WriteMsg(m)
+	})}
+	go srv.ActivateAndServe()
+	t.Cleanup(func() { srv.Shutdown() })
+	return pc.LocalAddr().String()
+}
+
+func srvRecord(prio, weight uint16, target string) dns.RR {
+	return &dns.SRV{
+		Hdr:      dns.RR_Header{Name: "_api._tcp.example.", Rrtype: dns.TypeSRV, Class: dns.ClassINET, Ttl: 60},
+		Priority: prio,
+		Weight:   weight,
+		Port:     8080,
+		Target:   target,
+	}
+}
+
+func TestSRVLowestPriority(t *testing.T) {
+	addr := serve(t, []dns.RR{
+		srvRecord(20, 10, "backup.example."),
+		srvRecord(10, 30, "a.example."),
+		srvRecord(10, 0, "b.example."),
+	}, map[string]string{
+		"a.example.":      "10.0.0.1",
+		"b.example.":      "10.0.0.2",
+		"backup.example.": "10.0.0.9",
+	})
+
+	p, err := New(providers.Config{Service: "_api._tcp.example", RecordType: "SRV", Resolver: addr})
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := p.Resolve(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []providers.Target{
+		{Address: "10.0.0.1:8080", Weight: 30},
+		{Address: "10.0.0.2:8080", Weight: 1},
+	}
+	if !slices.Equal(got, want) {
+		t.Errorf("targets = %v, want %v", got, want)
+	}
+}
+
+func TestSRVFallsBackWhenPriorityHasNoAddress(t *testing.T) {
+	addr := serve(t, []dns.RR{
+		srvRecord(10, 5, "gone.example."),
+		srvRecord(20, 5, "backup.example."),
+	}, map[string]string{
+		"backup.example.": "10.0.0.9",
+	})
+
+	p, _ := New(providers.Config{Service: "_api._tcp.example", RecordType: "SRV", Resolver: addr})
+	got, err := p.Resolve(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 1 || got[0].Address != "10.0.0.9:8080" {
+		t.Errorf("targets = %v, want the backup", got)
+	}
+}
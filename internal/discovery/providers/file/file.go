@@ -0,0 +1,86 @@
+package file
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/rs/zerolog/log"
+	"github.com/shrihariharanba/go-gateway/internal/discovery/providers"
+	"github.com/shrihariharanba/go-gateway/internal/filewatch"
+	"gopkg.in/yaml.v3"
+)
+
+// endpointsFile is the on-disk format. JSON files are accepted as well since
+// JSON is valid YAML:
+//
+//	services:
+//	  billing:
+//	    - address: 10.0.0.1:8080
+//	      weight: 2
+type endpointsFile struct {
+	Services map[string][]struct {
+		Address string `yaml:"address"`
+		Weight  int    `yaml:"weight"`
+	} `yaml:"services"`
+}
+
+type FileProvider struct {
+	cfg providers.Config
+}
+
+func New(cfg providers.Config) (providers.DiscoveryProvider, error) {
+	if cfg.Path == "" {
+		return nil, errors.New("file discovery requires a path")
+	}
+	if cfg.Service == "" {
+		return nil, errors.New("file discovery requires a service name")
+	}
+	return &FileProvider{cfg: cfg}, nil
+}
+
+func (f *FileProvider) Resolve(ctx context.Context) ([]providers.Target, error) {
+	data, err := os.ReadFile(f.cfg.Path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read endpoints file: %w", err)
+	}
+
+	var doc endpointsFile
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return nil, fmt.Errorf("failed to parse endpoints file: %w", err)
+	}
+
+	entries, ok := doc.Services[f.cfg.Service]
+	if !ok {
+		return nil, fmt.Errorf("service %s not found in %s", f.cfg.Service, f.cfg.Path)
+	}
+
+	targets := make([]providers.Target, 0, len(entries))
+	for _, e := range entries {
+		if e.Address == "" {
+			continue
+		}
+		weight := e.Weight
+		if weight == 0 {
+			weight = 1
+		}
+		targets = append(targets, providers.Target{Address: e.Address, Weight: weight})
+	}
+	return targets, nil
+}
+
+// Watch re-reads the endpoints file whenever it changes on disk. A file that
+// fails to parse keeps the previous target set in place.
+func (f *FileProvider) Watch(ctx context.Context, update func([]providers.Target)) error {
+	return filewatch.Watch(ctx, []string{f.cfg.Path}, func() {
+		targets, err := f.Resolve(ctx)
+		if err != nil {
+			log.Warn().Err(err).Str("service", f.cfg.Service).Msg("File discovery reload failed")
+			return
+		}
+		update(targets)
+	})
+}
+
+func (f *FileProvider) Name() string { return "file" }
@@ -0,0 +1,280 @@
+package kubernetes
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/rs/zerolog/log"
+	"github.com/shrihariharanba/go-gateway/internal/discovery/providers"
+	corev1 "k8s.io/api/core/v1"
+	discoveryv1 "k8s.io/api/discovery/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/client-go/informers"
+	"k8s.io/client-go/kubernetes"
+	corelisters "k8s.io/client-go/listers/core/v1"
+	discoverylisters "k8s.io/client-go/listers/discovery/v1"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/client-go/tools/clientcmd"
+)
+
+// KubernetesProvider tracks the ready endpoints of a Service through its
+// EndpointSlices, so traffic goes straight to pod IPs instead of through
+// kube-proxy.
+type KubernetesProvider struct {
+	cfg       providers.Config
+	client    kubernetes.Interface
+	name      string
+	namespace string
+	port      string
+}
+
+// New connects using cfg.Kubeconfig, or the in-cluster service account when
+// no kubeconfig is given.
+func New(cfg providers.Config) (providers.DiscoveryProvider, error) {
+	var (
+		restCfg *rest.Config
+		err     error
+	)
+	if cfg.Kubeconfig != "" {
+		restCfg, err = clientcmd.BuildConfigFromFlags("", cfg.Kubeconfig)
+	} else {
+		restCfg, err = rest.InClusterConfig()
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to load kubernetes client config: %w", err)
+	}
+
+	client, err := kubernetes.NewForConfig(restCfg)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create kubernetes client: %w", err)
+	}
+	return NewWithClient(cfg, client)
+}
+
+// NewWithClient uses an existing clientset, such as a fake one in tests.
+func NewWithClient(cfg providers.Config, client kubernetes.Interface) (providers.DiscoveryProvider, error) {
+	name, namespace, port, err := ParseService(cfg.Service)
+	if err != nil {
+		return nil, err
+	}
+	return &KubernetesProvider{
+		cfg:       cfg,
+		client:    client,
+		name:      name,
+		namespace: namespace,
+		port:      port,
+	}, nil
+}
+
+// ParseService splits a "service.namespace:port" reference. The port may be a
+// Service port number or name.
+func ParseService(ref string) (name, namespace, port string, err error) {
+	host, port, err := net.SplitHostPort(ref)
+	if err != nil || port == "" {
+		return "", "", "", fmt.Errorf("kubernetes service %q must be service.namespace:port", ref)
+	}
+	name, namespace, ok := strings.Cut(host, ".")
+	if !ok || name == "" || namespace == "" || strings.Contains(namespace, ".") {
+		return "", "", "", fmt.Errorf("kubernetes service %q must be service.namespace:port", ref)
+	}
+	return name, namespace, port, nil
+}
+
+func (k *KubernetesProvider) Resolve(ctx context.Context) ([]providers.Target, error) {
+	slices, err := k.client.DiscoveryV1().EndpointSlices(k.namespace).List(ctx, metav1.ListOptions{
+		LabelSelector: k.selector().String(),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list endpointslices for %s/%s: %w", k.namespace, k.name, err)
+	}
+
+	svc, err := k.client.CoreV1().Services(k.namespace).Get(ctx, k.name, metav1.GetOptions{})
+	if err != nil && !apierrors.IsNotFound(err) {
+		return nil, fmt.Errorf("failed to get service %s/%s: %w", k.namespace, k.name, err)
+	}
+	if err != nil {
+		svc = nil
+	}
+
+	list := make([]*discoveryv1.EndpointSlice, 0, len(slices.Items))
+	for i := range slices.Items {
+		list = append(list, &slices.Items[i])
+	}
+	return k.targets(svc, list), nil
+}
+
+// Watch keeps shared informers on the Service and its EndpointSlices and
+// recomputes the target set on every change.
+func (k *KubernetesProvider) Watch(ctx context.Context, update func([]providers.Target)) error {
+	sliceFactory := informers.NewSharedInformerFactoryWithOptions(k.client, 0,
+		informers.WithNamespace(k.namespace),
+		informers.WithTweakListOptions(func(o *metav1.ListOptions) {
+			o.LabelSelector = k.selector().String()
+		}),
+	)
+	serviceFactory := informers.NewSharedInformerFactoryWithOptions(k.client, 0,
+		informers.WithNamespace(k.namespace),
+		informers.WithTweakListOptions(func(o *metav1.ListOptions) {
+			o.FieldSelector = fields.OneTermEqualSelector("metadata.name", k.name).String()
+		}),
+	)
+
+	sliceInformer := sliceFactory.Discovery().V1().EndpointSlices()
+	serviceInformer := serviceFactory.Core().V1().Services()
+
+	// Both informers deliver on their own goroutines. Computing and
+	// publishing under one lock means the last update always reflects the
+	// latest cache state; nothing is published until both caches synced.
+	var (
+		mu     sync.Mutex
+		synced bool
+	)
+	recompute := func(initial bool) {
+		mu.Lock()
+		defer mu.Unlock()
+		if initial {
+			synced = true
+		}
+		if synced {
+			update(k.fromListers(serviceInformer.Lister(), sliceInformer.Lister()))
+		}
+	}
+	handler := cache.ResourceEventHandlerFuncs{
+		AddFunc:    func(any) { recompute(false) },
+		UpdateFunc: func(any, any) { recompute(false) },
+		DeleteFunc: func(any) { recompute(false) },
+	}
+	if _, err := sliceInformer.Informer().AddEventHandler(handler); err != nil {
+		return err
+	}
+	if _, err := serviceInformer.Informer().AddEventHandler(handler); err != nil {
+		return err
+	}
+
+	sliceFactory.Start(ctx.Done())
+	serviceFactory.Start(ctx.Done())
+	defer sliceFactory.Shutdown()
+	defer serviceFactory.Shutdown()
+
+	for typ, synced := range sliceFactory.WaitForCacheSync(ctx.Done()) {
+		if !synced {
+			return fmt.Errorf("failed to sync %v informer", typ)
+		}
+	}
+	for typ, synced := range serviceFactory.WaitForCacheSync(ctx.Done()) {
+		if !synced {
+			return fmt.Errorf("failed to sync %v informer", typ)
+		}
+	}
+	recompute(true)
+
+	<-ctx.Done()
+	return nil
+}
+
+func (k *KubernetesProvider) Name() string { return "kubernetes" }
+
+func (k *KubernetesProvider) selector() labels.Selector {
+	return labels.SelectorFromSet(labels.Set{discoveryv1.LabelServiceName: k.name})
+}
+
+func (k *KubernetesProvider) fromListers(services corelisters.ServiceLister, slices discoverylisters.EndpointSliceLister) []providers.Target {
+	svc, err := services.Services(k.namespace).Get(k.name)
+	if err != nil {
+		svc = nil
+	}
+	list, err := slices.EndpointSlices(k.namespace).List(k.selector())
+	if err != nil {
+		log.Warn().Err(err).Str("service", k.cfg.Service).Msg("Failed to list cached endpointslices")
+		return nil
+	}
+	return k.targets(svc, list)
+}
+
+// targets collects the ready endpoint addresses serving the requested port.
+// Endpoints that appear in more than one slice during a rollout are counted
+// once.
+func (k *KubernetesProvider) targets(svc *corev1.Service, slices []*discoveryv1.EndpointSlice) []providers.Target {
+	portName, portNumber := k.resolvePort(svc)
+
+	seen := make(map[string]struct{})
+	var targets []providers.Target
+	for _, slice := range slices {
+		if slice.AddressType == discoveryv1.AddressTypeFQDN {
+			continue
+		}
+
+		var targetPort int32
+		for _, p := range slice.Ports {
+			name := ""
+			if p.Name != nil {
+				name = *p.Name
+			}
+			if p.Port == nil {
+				continue
+			}
+			if (portName != nil && name == *portName) || (portName == nil && *p.Port == portNumber) {
+				targetPort = *p.Port
+				break
+			}
+		}
+		if targetPort == 0 {
+			continue
+		}
+
+		for _, ep := range slice.Endpoints {
+			if !isReady(ep) {
+				continue
+			}
+			for _, addr := range ep.Addresses {
+				address := net.JoinHostPort(addr, strconv.Itoa(int(targetPort)))
+				if _, dup := seen[address]; dup {
+					continue
+				}
+				seen[address] = struct{}{}
+				targets = append(targets, providers.Target{Address: address, Weight: 1})
+			}
+		}
+	}
+	return targets
+}
+
+// resolvePort maps the configured port to the port name used in
+// EndpointSlices. Slice ports carry the Service port name but the target
+// (container) port number, so a numeric Service port has to be translated
+// through the Service spec. Without the Service, the number is matched
+// against slice ports directly.
+func (k *KubernetesProvider) resolvePort(svc *corev1.Service) (*string, int32) {
+	number, err := strconv.Atoi(k.port)
+	if err != nil {
+		name := k.port
+		return &name, 0
+	}
+	if svc != nil {
+		for _, p := range svc.Spec.Ports {
+			if int(p.Port) == number {
+				name := p.Name
+				return &name, 0
+			}
+		}
+	}
+	return nil, int32(number)
+}
+
+func isReady(ep discoveryv1.Endpoint) bool {
+	if ep.Conditions.Ready != nil && !*ep.Conditions.Ready {
+		return false
+	}
+	if ep.Conditions.Terminating != nil && *ep.Conditions.Terminating {
+		return false
+	}
+	return true
+}
@@ -0,0 +1,155 @@
+package kubernetes
+
+import (
+	"context"
+	"slices"
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	discoveryv1 "k8s.io/api/discovery/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"k8s.io/client-go/kubernetes/fake"
+
+	"github.com/shrihariharanba/go-gateway/internal/discovery/providers"
+)
+
+func ptr[T any](v T) *T { return &v }
+
+func service() *corev1.Service {
+	return &corev1.Service{
+		ObjectMeta: metav1.ObjectMeta{Name: "api", Namespace: "prod"},
+		Spec: corev1.ServiceSpec{Ports: []corev1.ServicePort{
+			{Name: "http", Port: 80, TargetPort: intstr.FromInt(8080)},
+			{Name: "metrics", Port: 9090},
+		}},
+	}
+}
+
+func slice(name string, endpoints ...discoveryv1.Endpoint) *discoveryv1.EndpointSlice {
+	return &discoveryv1.EndpointSlice{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: "prod",
+			Labels:    map[string]string{discoveryv1.LabelServiceName: "api"},
+		},
+		AddressType: discoveryv1.AddressTypeIPv4,
+		Ports: []discoveryv1.EndpointPort{
+			{Name: ptr("http"), Port: ptr(int32(8080))},
+			{Name: ptr("metrics"), Port: ptr(int32(9090))},
+		},
+		Endpoints: endpoints,
+	}
+}
+
+func endpoint(addr string, ready, terminating bool) discoveryv1.Endpoint {
+	return discoveryv1.Endpoint{
+		Addresses:  []string{addr},
+		Conditions: discoveryv1.EndpointConditions{Ready: &ready, Terminating: &terminating},
+	}
+}
+
+func TestParseService(t *testing.T) {
+	name, ns, port, err := ParseService("api.prod:http")
+	if err != nil || name != "api" || ns != "prod" || port != "http" {
+		t.Errorf("ParseService = %q %q %q %v", name, ns, port, err)
+	}
+	for _, ref := range []string{"api:80", "api.prod", "api.prod.svc:80", ".prod:80"} {
+		if _, _, _, err := ParseService(ref); err == nil {
+			t.Errorf("ParseService(%q) should fail", ref)
+		}
+	}
+}
+
+func TestResolve(t *testing.T) {
+	client := fake.NewSimpleClientset(
+		service(),
+		slice("api-1",
+			endpoint("10.0.0.1", true, false),
+			endpoint("10.0.0.2", false, false), // not ready
+			endpoint("10.0.0.3", true, true),   // terminating
+		),
+		// A rollout can list the same endpoint in two slices.
+		slice("api-2", endpoint("10.0.0.1", true, false), endpoint("10.0.0.4", true, false)),
+	)
+
+	tests := []struct {
+		port string
+		want []string
+	}{
+		{"80", []string{"10.0.0.1:8080", "10.0.0.4:8080"}},   // Service port number
+		{"http", []string{"10.0.0.1:8080", "10.0.0.4:8080"}}, // port name
+		{"metrics", []string{"10.0.0.1:9090", "10.0.0.4:9090"}},
+		{"7000", nil}, // no such port
+	}
+	for _, tt := range tests {
+		p, err := NewWithClient(providers.Config{Service: "api.prod:" + tt.port}, client)
+		if err != nil {
+			t.Fatal(err)
+		}
+		targets, err := p.Resolve(context.Background())
+		if err != nil {
+			t.Fatal(err)
+		}
+		var got []string
+		for _, tg := range targets {
+			got = append(got, tg.Address)
+		}
+		slices.Sort(got)
+		if !slices.Equal(got, tt.want) {
+			t.Errorf("port %s: targets = %v, want %v", tt.port, got, tt.want)
+		}
+	}
+}
+
+func TestWatch(t *testing.T) {
+	client := fake.NewSimpleClientset(service(), slice("api-1", endpoint("10.0.0.1", true, false)))
+	p, _ := NewWithClient(providers.Config{Service: "api.prod:80"}, client)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	updates := make(chan []providers.Target, 16)
+	done := make(chan error, 1)
+	go func() {
+		done <- p.Watch(ctx, func(targets []providers.Target) { updates <- targets })
+	}()
+
+	waitFor := func(want ...string) {
+		t.Helper()
+		for {
+			select {
+			case targets := <-updates:
+				var got []string
+				for _, tg := range targets {
+					got = append(got, tg.Address)
+				}
+				slices.Sort(got)
+				if slices.Equal(got, want) {
+					return
+				}
+			case <-ctx.Done():
+				t.Fatalf("timed out waiting for %v", want)
+			}
+		}
+	}
+	waitFor("10.0.0.1:8080")
+
+	_, err := client.DiscoveryV1().EndpointSlices("prod").Create(ctx,
+		slice("api-2", endpoint("10.0.0.2", true, false)), metav1.CreateOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	waitFor("10.0.0.1:8080", "10.0.0.2:8080")
+
+	if err := client.DiscoveryV1().EndpointSlices("prod").Delete(ctx, "api-1", metav1.DeleteOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	waitFor("10.0.0.2:8080")
+
+	cancel()
+	if err := <-done; err != nil {
+		t.Errorf("Watch returned %v", err)
+	}
+}
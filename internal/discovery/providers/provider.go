@@ -0,0 +1,46 @@
+package providers
+
+import (
+	"context"
+	"time"
+)
+
+// Target is a single resolved upstream endpoint.
+type Target struct {
+	Address string // host:port
+	Weight  int
+}
+
+type DiscoveryProvider interface {
+	// Resolve returns the current target set of the service.
+	Resolve(ctx context.Context) ([]Target, error)
+	// Watch calls update every time the target set may have changed and
+	// blocks until ctx is cancelled.
+	Watch(ctx context.Context, update func([]Target)) error
+	Name() string
+}
+
+type ProviderType string
+
+const (
+	ProviderDNS    ProviderType = "dns"
+	ProviderFile   ProviderType = "file"
+	ProviderConsul ProviderType = "consul"
+	ProviderK8s    ProviderType = "kubernetes"
+)
+
+type Config struct {
+	Type       ProviderType
+	Service    string
+	Port       int
+	RecordType string
+	Resolver   string
+	Path       string
+	Endpoint   string
+	Token      string
+	Datacenter string
+	Tag        string
+	Kubeconfig string
+	MinRefresh time.Duration
+	MaxRefresh time.Duration
+}
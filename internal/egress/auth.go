@@ -0,0 +1,79 @@
+package egress
+
+import (
+	"crypto/subtle"
+	"encoding/base64"
+	"net/http"
+	"strings"
+
+	"golang.org/x/crypto/bcrypt"
+
+	"github.com/shrihariharanba/go-gateway/internal/sso/providers"
+)
+
+// AuthConfig selects how callers identify themselves in the
+// Proxy-Authorization header. With neither SSO nor Users set, callers are
+// not authenticated and are identified by their address.
+type AuthConfig struct {
+	SSO   providers.SSOProvider // "Bearer <token>"
+	Users map[string]string     // "Basic"; passwords in plain text or as bcrypt hashes
+	Realm string
+}
+
+func (a AuthConfig) enabled() bool { return a.SSO != nil || len(a.Users) > 0 }
+
+// authenticate returns the caller's identity, or "" when the credentials
+// are missing or invalid.
+func (a AuthConfig) authenticate(r *http.Request) string {
+	scheme, cred, _ := strings.Cut(r.Header.Get("Proxy-Authorization"), " ")
+	cred = strings.TrimSpace(cred)
+
+	switch {
+	case strings.EqualFold(scheme, "Basic") && len(a.Users) > 0:
+		raw, err := base64.StdEncoding.DecodeString(cred)
+		if err != nil {
+			return ""
+		}
+		user, pass, ok := strings.Cut(string(raw), ":")
+		if !ok {
+			return ""
+		}
+		want, ok := a.Users[user]
+		if !ok || !checkPassword(want, pass) {
+			return ""
+		}
+		return user
+
+	case strings.EqualFold(scheme, "Bearer") && a.SSO != nil:
+		authCtx, err := a.SSO.Authenticate(r.Context(), cred)
+		if err != nil || authCtx == nil {
+			return ""
+		}
+		if authCtx.UserEmail != "" {
+			return authCtx.UserEmail
+		}
+		return authCtx.UserID
+	}
+	return ""
+}
+
+// challenge lists the schemes the proxy accepts.
+func (a AuthConfig) challenge(w http.ResponseWriter) {
+	realm := a.Realm
+	if realm == "" {
+		realm = "go-gateway"
+	}
+	if len(a.Users) > 0 {
+		w.Header().Add("Proxy-Authenticate", `Basic realm="`+realm+`"`)
+	}
+	if a.SSO != nil {
+		w.Header().Add("Proxy-Authenticate", `Bearer realm="`+realm+`"`)
+	}
+}
+
+func checkPassword(want, got string) bool {
+	if strings.HasPrefix(want, "$2") {
+		return bcrypt.CompareHashAndPassword([]byte(want), []byte(got)) == nil
+	}
+	return subtle.ConstantTimeCompare([]byte(want), []byte(got)) == 1
+}
@@ -0,0 +1,250 @@
+// Package egress implements a forward proxy for outbound traffic. Plain
+// HTTP requests in absolute form and CONNECT tunnels are checked against a
+// destination policy, and every attempt is written to the audit log with
+// the caller's identity.
+package egress
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httputil"
+	"net/netip"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog/log"
+
+	"github.com/shrihariharanba/go-gateway/internal/clientip"
+)
+
+const (
+	defaultDialTimeout = 10 * time.Second
+	// viaToken marks requests that already passed this proxy.
+	viaToken = "1.1 go-gateway-egress"
+)
+
+type Config struct {
+	Allow       Rules
+	Deny        Rules
+	Auth        AuthConfig
+	DialTimeout time.Duration
+}
+
+var forwardingHeaders = []string{"Forwarded", "X-Forwarded-For", "X-Forwarded-Host", "X-Forwarded-Proto"}
+
+// Proxy is the egress proxy handler.
+type Proxy struct {
+	policy  *Policy
+	auth    AuthConfig
+	dialer  net.Dialer
+	forward *httputil.ReverseProxy
+}
+
+func New(cfg Config) (*Proxy, error) {
+	policy, err := NewPolicy(cfg.Allow, cfg.Deny)
+	if err != nil {
+		return nil, err
+	}
+	if cfg.DialTimeout <= 0 {
+		cfg.DialTimeout = defaultDialTimeout
+	}
+
+	p := &Proxy{
+		policy: policy,
+		auth:   cfg.Auth,
+		dialer: net.Dialer{Timeout: cfg.DialTimeout},
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = nil
+	transport.DialContext = p.dial
+	p.forward = &httputil.ReverseProxy{
+		Transport: transport,
+		Rewrite: func(pr *httputil.ProxyRequest) {
+			pr.Out.URL = pr.In.URL
+			pr.Out.Host = ""
+			pr.Out.Header.Add("Via", viaToken)
+			// Rewrite drops forwarding headers; a forward proxy passes the
+			// caller's through untouched.
+			for _, h := range forwardingHeaders {
+				if v := pr.In.Header.Values(h); len(v) > 0 {
+					pr.Out.Header[h] = v
+				}
+			}
+		},
+		ErrorHandler: func(w http.ResponseWriter, r *http.Request, err error) {
+			if sw, ok := w.(*statusWriter); ok {
+				sw.err = err
+			}
+			http.Error(w, err.Error(), errorStatus(err))
+		},
+	}
+	return p, nil
+}
+
+func (p *Proxy) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	caller := clientip.String(r)
+	if p.auth.enabled() {
+		user := p.auth.authenticate(r)
+		if user == "" {
+			p.audit(r, caller, r.Host, http.StatusProxyAuthRequired, 0, 0, time.Now(), nil)
+			p.auth.challenge(w)
+			http.Error(w, "Proxy Authentication Required", http.StatusProxyAuthRequired)
+			return
+		}
+		caller = user
+	}
+
+	// A destination that points back at the proxy would loop forever.
+	for _, via := range r.Header.Values("Via") {
+		if strings.Contains(via, viaToken) {
+			p.audit(r, caller, r.Host, http.StatusLoopDetected, 0, 0, time.Now(), nil)
+			http.Error(w, "Loop Detected", http.StatusLoopDetected)
+			return
+		}
+	}
+
+	if r.Method == http.MethodConnect {
+		p.tunnel(w, r, caller)
+		return
+	}
+	if !r.URL.IsAbs() || r.URL.Host == "" {
+		http.Error(w, "Bad Request: egress proxy requires an absolute URL", http.StatusBadRequest)
+		return
+	}
+
+	start := time.Now()
+	sw := &statusWriter{ResponseWriter: w, status: http.StatusOK}
+	p.forward.ServeHTTP(sw, r)
+	p.audit(r, caller, r.URL.Host, sw.status, 0, 0, start, sw.err)
+}
+
+// tunnel serves CONNECT by splicing the client connection to the
+// destination after the policy allowed it.
+func (p *Proxy) tunnel(w http.ResponseWriter, r *http.Request, caller string) {
+	start := time.Now()
+	dest := r.Host
+
+	upstream, err := p.dial(r.Context(), "tcp", dest)
+	if err != nil {
+		status := errorStatus(err)
+		p.audit(r, caller, dest, status, 0, 0, start, err)
+		http.Error(w, err.Error(), status)
+		return
+	}
+	defer upstream.Close()
+
+	hj, ok := w.(http.Hijacker)
+	if !ok {
+		http.Error(w, "CONNECT not supported on this listener", http.StatusInternalServerError)
+		return
+	}
+	client, buf, err := hj.Hijack()
+	if err != nil {
+		return
+	}
+	defer client.Close()
+
+	if _, err := client.Write([]byte("HTTP/1.1 200 Connection Established\r\n\r\n")); err != nil {
+		return
+	}
+
+	var bytesIn, bytesOut int64
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		bytesOut = splice(client, upstream)
+	}()
+	// Bytes the client sent along with the CONNECT request are still in buf.
+	bytesIn = splice(upstream, buf)
+	wg.Wait()
+
+	p.audit(r, caller, dest, http.StatusOK, bytesIn, bytesOut, start, nil)
+}
+
+// dial connects to one of the addresses the policy allowed for addr.
+func (p *Proxy) dial(ctx context.Context, network, addr string) (net.Conn, error) {
+	host, portStr, err := net.SplitHostPort(addr)
+	if err != nil {
+		return nil, err
+	}
+	port, err := strconv.Atoi(portStr)
+	if err != nil {
+		return nil, err
+	}
+
+	addrs, err := p.policy.Resolve(ctx, host, port)
+	if err != nil {
+		return nil, err
+	}
+
+	var lastErr error
+	for _, a := range addrs {
+		conn, err := p.dialer.DialContext(ctx, network, netip.AddrPortFrom(a, uint16(port)).String())
+		if err == nil {
+			return conn, nil
+		}
+		lastErr = err
+	}
+	return nil, lastErr
+}
+
+func (p *Proxy) audit(r *http.Request, caller, dest string, status int, bytesIn, bytesOut int64, start time.Time, err error) {
+	ev := log.Info()
+	if status >= 400 {
+		ev = log.Warn().Err(err)
+	}
+	ev.Str("caller", caller).
+		Str("client", clientip.String(r)).
+		Str("method", r.Method).
+		Str("destination", dest).
+		Int("status", status).
+		Int64("bytesIn", bytesIn).
+		Int64("bytesOut", bytesOut).
+		Dur("duration", time.Since(start)).
+		Msg("Egress request")
+}
+
+func errorStatus(err error) int {
+	var denied *DeniedError
+	if errors.As(err, &denied) {
+		return http.StatusForbidden
+	}
+	var ne net.Error
+	if errors.As(err, &ne) && ne.Timeout() {
+		return http.StatusGatewayTimeout
+	}
+	return http.StatusBadGateway
+}
+
+// splice copies src to dst and half-closes dst when src is done.
+func splice(dst net.Conn, src io.Reader) int64 {
+	n, _ := io.Copy(dst, src)
+	if cw, ok := dst.(interface{ CloseWrite() error }); ok {
+		cw.CloseWrite()
+	} else {
+		dst.Close()
+	}
+	return n
+}
+
+type statusWriter struct {
+	http.ResponseWriter
+	status int
+	err    error
+}
+
+func (w *statusWriter) WriteHeader(code int) {
+	w.status = code
+	w.ResponseWriter.WriteHeader(code)
+}
+
+// Unwrap lets http.ResponseController reach the underlying writer, so
+// streamed responses are still flushed.
+func (w *statusWriter) Unwrap() http.ResponseWriter { return w.ResponseWriter }
@@ -0,0 +1,145 @@
+package egress
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/netip"
+	"slices"
+
+	"github.com/shrihariharanba/go-gateway/internal/clientip"
+	"github.com/shrihariharanba/go-gateway/internal/hostmatch"
+)
+
+// Rules is one side (allow or deny) of the destination policy.
+type Rules struct {
+	Hosts []string // exact names or "*.example.com"
+	CIDRs []string // destination address ranges
+	Ports []int
+}
+
+// DeniedError is returned when the policy rejects a destination.
+type DeniedError struct {
+	Destination string
+	Reason      string
+}
+
+func (e *DeniedError) Error() string {
+	return fmt.Sprintf("egress to %s denied: %s", e.Destination, e.Reason)
+}
+
+// Policy decides which destinations may be reached. Deny rules win over
+// allow rules. Without host or CIDR allow rules every public address is
+// allowed, and without allowed ports every port is. Internal addresses
+// (loopback, private, link-local, shared and multicast ranges) are only
+// reached when an allow CIDR covers them, so the proxy never exposes the
+// gateway's own listeners or cloud metadata endpoints by accident.
+type Policy struct {
+	allowHosts []string
+	allowNets  []netip.Prefix
+	allowPorts []int
+	denyHosts  []string
+	denyNets   []netip.Prefix
+	denyPorts  []int
+	resolver   lookuper
+}
+
+// lookuper is the part of *net.Resolver the policy uses.
+type lookuper interface {
+	LookupNetIP(ctx context.Context, network, host string) ([]netip.Addr, error)
+}
+
+func NewPolicy(allow, deny Rules) (*Policy, error) {
+	allowNets, err := clientip.ParsePrefixes(allow.CIDRs)
+	if err != nil {
+		return nil, fmt.Errorf("allow cidrs: %w", err)
+	}
+	denyNets, err := clientip.ParsePrefixes(deny.CIDRs)
+	if err != nil {
+		return nil, fmt.Errorf("deny cidrs: %w", err)
+	}
+	return &Policy{
+		allowHosts: allow.Hosts,
+		allowNets:  allowNets,
+		allowPorts: allow.Ports,
+		denyHosts:  deny.Hosts,
+		denyNets:   denyNets,
+		denyPorts:  deny.Ports,
+		resolver:   net.DefaultResolver,
+	}, nil
+}
+
+// Resolve checks host:port against the policy and returns the addresses
+// that may be dialled. Address rules are applied to the resolved IPs, and
+// callers must dial those IPs rather than the name so that a second DNS
+// answer cannot bypass the check.
+func (p *Policy) Resolve(ctx context.Context, host string, port int) ([]netip.Addr, error) {
+	dest := net.JoinHostPort(host, fmt.Sprint(port))
+	deny := func(reason string) error { return &DeniedError{Destination: dest, Reason: reason} }
+
+	if slices.Contains(p.denyPorts, port) {
+		return nil, deny("port is denied")
+	}
+	if len(p.allowPorts) > 0 && !slices.Contains(p.allowPorts, port) {
+		return nil, deny("port is not allowed")
+	}
+	if hostmatch.Match(p.denyHosts, host) {
+		return nil, deny("host is denied")
+	}
+
+	var addrs []netip.Addr
+	if a, err := netip.ParseAddr(host); err == nil {
+		addrs = []netip.Addr{a.Unmap()}
+	} else {
+		ips, err := p.resolver.LookupNetIP(ctx, "ip", host)
+		if err != nil {
+			return nil, err
+		}
+		for _, a := range ips {
+			addrs = append(addrs, a.Unmap())
+		}
+	}
+
+	for _, a := range addrs {
+		if containsAddr(p.denyNets, a) {
+			return nil, deny(a.String() + " is in a denied range")
+		}
+		if isInternal(a) && !containsAddr(p.allowNets, a) {
+			return nil, deny(a.String() + " is an internal address")
+		}
+	}
+
+	if len(p.allowHosts) == 0 && len(p.allowNets) == 0 {
+		return addrs, nil
+	}
+	if hostmatch.Match(p.allowHosts, host) {
+		return addrs, nil
+	}
+	if len(p.allowNets) > 0 {
+		for _, a := range addrs {
+			if !containsAddr(p.allowNets, a) {
+				return nil, deny(a.String() + " is not in an allowed range")
+			}
+		}
+		return addrs, nil
+	}
+	return nil, deny("host is not allowed")
+}
+
+// sharedNet is the carrier-grade NAT range, which some clouds use for
+// metadata services.
+var sharedNet = netip.MustParsePrefix("100.64.0.0/10")
+
+func isInternal(a netip.Addr) bool {
+	return a.IsLoopback() || a.IsPrivate() || a.IsLinkLocalUnicast() ||
+		a.IsUnspecified() || a.IsMulticast() || sharedNet.Contains(a)
+}
+
+func containsAddr(nets []netip.Prefix, a netip.Addr) bool {
+	for _, n := range nets {
+		if n.Contains(a) {
+			return true
+		}
+	}
+	return false
+}
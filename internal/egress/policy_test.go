@@ -0,0 +1,159 @@
+package egress
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/netip"
+	"strconv"
+	"sync"
+	"testing"
+)
+
+// fakeDNS answers lookups from a fixed table and counts them.
+type fakeDNS struct {
+	mu      sync.Mutex
+	answers map[string][]string
+	lookups int
+}
+
+func (f *fakeDNS) LookupNetIP(_ context.Context, _, host string) ([]netip.Addr, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.lookups++
+	ips, ok := f.answers[host]
+	if !ok {
+		return nil, &net.DNSError{Err: "no such host", Name: host, IsNotFound: true}
+	}
+	var out []netip.Addr
+	for _, ip := range ips {
+		out = append(out, netip.MustParseAddr(ip))
+	}
+	return out, nil
+}
+
+func testPolicy(t *testing.T, allow, deny Rules, dns *fakeDNS) *Policy {
+	t.Helper()
+	p, err := NewPolicy(allow, deny)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p.resolver = dns
+	return p
+}
+
+func TestPolicyDeniesInternalAddresses(t *testing.T) {
+	dns := &fakeDNS{answers: map[string][]string{
+		"loopback.test": {"127.0.0.1"},
+		"metadata.test": {"169.254.169.254"},
+		"private.test":  {"10.1.2.3"},
+		"cgnat.test":    {"100.100.100.200"},
+		"mixed.test":    {"93.184.216.34", "192.168.1.1"},
+		"ula.test":      {"fd00::1"},
+		"public.test":   {"93.184.216.34"},
+	}}
+	p := testPolicy(t, Rules{}, Rules{}, dns)
+
+	for host, allowed := range map[string]bool{
+		"loopback.test":          false,
+		"metadata.test":          false,
+		"private.test":           false,
+		"cgnat.test":             false,
+		"mixed.test":             false,
+		"ula.test":               false,
+		"public.test":            true,
+		"127.0.0.1":              false,
+		"0.0.0.0":                false,
+		"::1":                    false,
+		"::ffff:127.0.0.1":       false,
+		"::ffff:169.254.169.254": false,
+		"::ffff:10.0.0.1":        false,
+		"::ffff:93.184.216.34":   true,
+		"93.184.216.34":          true,
+		"::ffff:192.168.0.1":     false,
+	} {
+		addrs, err := p.Resolve(context.Background(), host, 443)
+		if allowed {
+			if err != nil {
+				t.Errorf("%s: %v", host, err)
+			}
+			for _, a := range addrs {
+				if a.Is4In6() {
+					t.Errorf("%s: address %s was not unmapped", host, a)
+				}
+			}
+			continue
+		}
+		var denied *DeniedError
+		if !errors.As(err, &denied) {
+			t.Errorf("%s: err = %v, want a denial", host, err)
+		}
+	}
+}
+
+func TestPolicyAllowCIDROverridesInternalDeny(t *testing.T) {
+	dns := &fakeDNS{answers: map[string][]string{
+		"db.internal":    {"10.1.2.3"},
+		"other.internal": {"10.9.9.9"},
+	}}
+	p := testPolicy(t, Rules{CIDRs: []string{"10.1.0.0/16"}}, Rules{CIDRs: []string{"10.1.2.4/32"}}, dns)
+
+	if _, err := p.Resolve(context.Background(), "db.internal", 5432); err != nil {
+		t.Errorf("allowed range: %v", err)
+	}
+	if _, err := p.Resolve(context.Background(), "::ffff:10.1.2.3", 5432); err != nil {
+		t.Errorf("mapped address in allowed range: %v", err)
+	}
+	if _, err := p.Resolve(context.Background(), "other.internal", 5432); err == nil {
+		t.Error("internal address outside the allow range was allowed")
+	}
+	if _, err := p.Resolve(context.Background(), "10.1.2.4", 5432); err == nil {
+		t.Error("deny CIDR did not win over the allow CIDR")
+	}
+}
+
+func TestDialUsesVettedAddress(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			conn.Close()
+		}
+	}()
+	port := ln.Addr().(*net.TCPAddr).Port
+
+	// The name is unknown to the system resolver, so the dial only
+	// succeeds if it goes to the address the policy checked.
+	dns := &fakeDNS{answers: map[string][]string{"app.test": {"127.0.0.1"}}}
+	p, err := New(Config{Allow: Rules{CIDRs: []string{"127.0.0.1/32"}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	p.policy.resolver = dns
+
+	conn, err := p.dial(context.Background(), "tcp", net.JoinHostPort("app.test", strconv.Itoa(port)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	conn.Close()
+	if dns.lookups != 1 {
+		t.Errorf("lookups = %d, want 1", dns.lookups)
+	}
+
+	// Without the allow CIDR the same answer is refused before dialling.
+	p, err = New(Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	p.policy.resolver = dns
+	if _, err := p.dial(context.Background(), "tcp", net.JoinHostPort("app.test", strconv.Itoa(port))); err == nil {
+		t.Error("dial to a loopback answer was allowed")
+	}
+}
@@ -0,0 +1,324 @@
+// Package extauthz asks an external authorization service whether a
+// request may be proxied, over HTTP (JSON) or gRPC (Envoy's ext_authz
+// Authorization service).
+package extauthz
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/rs/zerolog/log"
+
+	"github.com/shrihariharanba/go-gateway/internal/authz"
+	"github.com/shrihariharanba/go-gateway/internal/clientip"
+	"github.com/shrihariharanba/go-gateway/internal/sso"
+	"github.com/shrihariharanba/go-gateway/internal/sso/providers"
+	"github.com/shrihariharanba/go-gateway/internal/ttlcache"
+)
+
+const (
+	defaultTimeout      = time.Second
+	defaultMaxBodyBytes = 8 << 10
+	// cacheSize bounds the decision cache; the least recently used
+	// decisions are dropped first.
+	cacheSize = 10000
+)
+
+// volatileHeaders differ on every request, so they are sent to the service
+// but left out of the cache key; otherwise no decision would ever be reused.
+var volatileHeaders = map[string]bool{
+	"x-request-id":          true,
+	"x-correlation-id":      true,
+	"traceparent":           true,
+	"tracestate":            true,
+	"baggage":               true,
+	"b3":                    true,
+	"x-b3-traceid":          true,
+	"x-b3-spanid":           true,
+	"x-b3-parentspanid":     true,
+	"x-b3-sampled":          true,
+	"x-amzn-trace-id":       true,
+	"x-cloud-trace-context": true,
+	"sentry-trace":          true,
+}
+
+// Config is the ext_authz option of a route.
+type Config struct {
+	// URL is http(s)://host/path for the JSON protocol or grpc://host:port
+	// (grpcs:// for TLS) for Envoy ext_authz.
+	URL     string
+	Timeout time.Duration // per check, default 1s
+	// Headers limits the request headers sent; all are sent when empty.
+	Headers      []string
+	IncludeBody  bool
+	MaxBodyBytes int64 // body bytes sent, default 8 KiB
+	// FailOpen lets requests through when the service cannot be reached
+	// or answers with an error; by default they are refused.
+	FailOpen bool
+	CacheTTL time.Duration // 0 disables caching; at most 10000 decisions are kept
+}
+
+// CheckRequest is what the service is asked about.
+type CheckRequest struct {
+	Method      string            `json:"method"`
+	Scheme      string            `json:"scheme"`
+	Host        string            `json:"host"`
+	Path        string            `json:"path"`
+	Query       string            `json:"query,omitempty"`
+	Protocol    string            `json:"protocol"`
+	Headers     map[string]string `json:"headers"`
+	ClientIP    string            `json:"clientIp"`
+	Body        []byte            `json:"body,omitempty"`
+	PartialBody bool              `json:"partialBody,omitempty"` // body cut at MaxBodyBytes
+	Size        int64             `json:"size"`                  // Content-Length, -1 when unknown
+	Auth        AuthInfo          `json:"auth"`
+}
+
+// AuthInfo is the authenticated user, as established by the gateway.
+type AuthInfo struct {
+	User   string   `json:"user"`
+	Email  string   `json:"email,omitempty"`
+	Roles  []string `json:"roles,omitempty"`
+	Scopes []string `json:"scopes,omitempty"`
+}
+
+// Response is the service's decision. Headers are added to the upstream
+// request when allowed; Status, ResponseHeaders and Body make up the reply
+// to the client when denied.
+type Response struct {
+	Allow           bool              `json:"allow"`
+	Headers         map[string]string `json:"headers,omitempty"`
+	RemoveHeaders   []string          `json:"removeHeaders,omitempty"`
+	Status          int               `json:"status,omitempty"` // default 403
+	ResponseHeaders map[string]string `json:"responseHeaders,omitempty"`
+	Body            string            `json:"body,omitempty"`
+}
+
+// Checker talks to an authorization service.
+type Checker interface {
+	Check(ctx context.Context, req *CheckRequest) (*Response, error)
+}
+
+// Client checks requests against a service, caching decisions.
+type Client struct {
+	cfg     Config
+	checker Checker
+	cache   *ttlcache.Cache[*Response]
+}
+
+// New connects to the service at cfg.URL.
+func New(cfg Config) (*Client, error) {
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = defaultTimeout
+	}
+	if cfg.MaxBodyBytes <= 0 {
+		cfg.MaxBodyBytes = defaultMaxBodyBytes
+	}
+	for i, h := range cfg.Headers {
+		cfg.Headers[i] = strings.ToLower(h)
+	}
+
+	u, err := url.Parse(cfg.URL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid ext_authz url: %w", err)
+	}
+	var checker Checker
+	switch u.Scheme {
+	case "http", "https":
+		checker = newHTTPChecker(cfg.URL)
+	case "grpc", "grpcs":
+		checker, err = newGRPCChecker(u.Host, u.Scheme == "grpcs")
+	default:
+		err = fmt.Errorf("unsupported ext_authz url scheme %q", u.Scheme)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	c := &Client{cfg: cfg, checker: checker}
+	if cfg.CacheTTL > 0 {
+		c.cache = ttlcache.New[*Response](cacheSize)
+	}
+	return c, nil
+}
+
+// Close releases the connection to the service.
+func (c *Client) Close() error {
+	if closer, ok := c.checker.(io.Closer); ok {
+		return closer.Close()
+	}
+	return nil
+}
+
+// Middleware consults the service before passing requests on. Like
+// sso.AuthMiddleware it runs per route; it expects the auth context to be
+// set already.
+func (c *Client) Middleware(route string, observe authz.Observer) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			authCtx := sso.FromContext(r.Context())
+			req, err := c.newCheckRequest(r, authCtx)
+			if err != nil {
+				http.Error(w, "Bad Request", http.StatusBadRequest)
+				return
+			}
+
+			resp, err := c.check(r.Context(), req)
+			decision := authz.DecisionAllow
+			switch {
+			case err != nil:
+				decision = authz.DecisionError
+			case !resp.Allow:
+				decision = authz.DecisionDeny
+			}
+			if observe != nil {
+				observe(route, "ext_authz", decision)
+			}
+
+			if err != nil {
+				if c.cfg.FailOpen {
+					log.Warn().Err(err).Str("route", route).Msg("Authorization service failed, allowing request")
+					next.ServeHTTP(w, r)
+					return
+				}
+				log.Error().Err(err).Str("route", route).Msg("Authorization service failed, refusing request")
+				http.Error(w, "Forbidden: authorization unavailable", http.StatusForbidden)
+				return
+			}
+
+			if !resp.Allow {
+				log.Warn().
+					Str("route", route).
+					Str("user", authCtx.UserID).
+					Str("method", r.Method).
+					Str("path", r.URL.Path).
+					Int("status", resp.Status).
+					Msg("Authorization service denied request")
+				for k, v := range resp.ResponseHeaders {
+					w.Header().Set(k, v)
+				}
+				status := resp.Status
+				if status < 400 || status > 599 {
+					status = http.StatusForbidden
+				}
+				body := resp.Body
+				if body == "" {
+					body = http.StatusText(status)
+				}
+				http.Error(w, body, status)
+				return
+			}
+
+			for _, k := range resp.RemoveHeaders {
+				r.Header.Del(k)
+			}
+			for k, v := range resp.Headers {
+				r.Header.Set(k, v)
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+func (c *Client) check(ctx context.Context, req *CheckRequest) (*Response, error) {
+	var key string
+	if c.cache != nil {
+		key = req.cacheKey()
+		if resp, ok := c.cache.Get(key); ok {
+			return resp, nil
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, c.cfg.Timeout)
+	defer cancel()
+	resp, err := c.checker.Check(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	if c.cache != nil {
+		c.cache.Set(key, resp, time.Now().Add(c.cfg.CacheTTL))
+	}
+	return resp, nil
+}
+
+// newCheckRequest describes r. When the body is included, the part read is
+// put back in front of the rest so that the upstream still sees all of it.
+func (c *Client) newCheckRequest(r *http.Request, authCtx *providers.AuthContext) (*CheckRequest, error) {
+	scheme := "http"
+	if r.TLS != nil {
+		scheme = "https"
+	}
+	req := &CheckRequest{
+		Method:   r.Method,
+		Scheme:   scheme,
+		Host:     r.Host,
+		Path:     r.URL.Path,
+		Query:    r.URL.RawQuery,
+		Protocol: r.Proto,
+		Headers:  make(map[string]string),
+		ClientIP: clientip.String(r),
+		Size:     r.ContentLength,
+		Auth: AuthInfo{
+			User:   authCtx.UserID,
+			Email:  authCtx.UserEmail,
+			Roles:  authCtx.Roles,
+			Scopes: authCtx.Scopes,
+		},
+	}
+	for name, vals := range r.Header {
+		name = strings.ToLower(name)
+		if len(c.cfg.Headers) == 0 || slices.Contains(c.cfg.Headers, name) {
+			req.Headers[name] = strings.Join(vals, ",")
+		}
+	}
+
+	if c.cfg.IncludeBody && r.Body != nil && r.Body != http.NoBody {
+		body, err := io.ReadAll(io.LimitReader(r.Body, c.cfg.MaxBodyBytes+1))
+		if err != nil {
+			return nil, err
+		}
+		r.Body = readCloser{io.MultiReader(bytes.NewReader(body), r.Body), r.Body}
+		if int64(len(body)) > c.cfg.MaxBodyBytes {
+			body = body[:c.cfg.MaxBodyBytes]
+			req.PartialBody = true
+		}
+		req.Body = body
+	}
+	return req, nil
+}
+
+type readCloser struct {
+	io.Reader
+	io.Closer
+}
+
+// cacheKey covers everything the service is told, apart from headers that
+// only identify the request.
+func (req *CheckRequest) cacheKey() string {
+	h := sha256.New()
+	fmt.Fprintf(h, "%s\x00%s\x00%s\x00%s\x00%s\x00%s\x00%s\x00%d\x00%t\x00%s\x00%s\x00",
+		req.Method, req.Scheme, req.Host, req.Path, req.Query, req.Protocol, req.ClientIP,
+		req.Size, req.PartialBody, req.Auth.User, req.Auth.Email)
+	fmt.Fprintf(h, "%q\x00%q\x00", req.Auth.Roles, req.Auth.Scopes)
+	names := make([]string, 0, len(req.Headers))
+	for name := range req.Headers {
+		if !volatileHeaders[name] {
+			names = append(names, name)
+		}
+	}
+	slices.Sort(names)
+	for _, name := range names {
+		fmt.Fprintf(h, "%s:%s\x00", name, req.Headers[name])
+	}
+	h.Write(req.Body)
+	return hex.EncodeToString(h.Sum(nil))
+}
@@ -0,0 +1,70 @@
+package extauthz
+
+import (
+	"context"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/shrihariharanba/go-gateway/internal/sso/providers"
+	"github.com/shrihariharanba/go-gateway/internal/ttlcache"
+)
+
+type countingChecker struct{ calls int }
+
+func (c *countingChecker) Check(ctx context.Context, req *CheckRequest) (*Response, error) {
+	c.calls++
+	return &Response{Allow: true}, nil
+}
+
+func TestCacheIgnoresVolatileHeaders(t *testing.T) {
+	checker := &countingChecker{}
+	c := &Client{
+		cfg:     Config{Timeout: time.Second, CacheTTL: time.Minute},
+		checker: checker,
+		cache:   ttlcache.New[*Response](cacheSize),
+	}
+	authCtx := &providers.AuthContext{UserID: "u1"}
+
+	check := func(requestID, tenant string) {
+		r := httptest.NewRequest("GET", "/orders", nil)
+		r.Header.Set("X-Request-Id", requestID)
+		r.Header.Set("Traceparent", "00-"+requestID+"-01")
+		r.Header.Set("X-Tenant", tenant)
+		req, err := c.newCheckRequest(r, authCtx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if req.Headers["x-request-id"] != requestID {
+			t.Errorf("x-request-id not sent to the service")
+		}
+		if _, err := c.check(context.Background(), req); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	check("r1", "acme")
+	check("r2", "acme")
+	if checker.calls != 1 {
+		t.Errorf("service called %d times for requests differing only in request id", checker.calls)
+	}
+	check("r3", "globex")
+	if checker.calls != 2 {
+		t.Errorf("service called %d times, want a new check for another tenant", checker.calls)
+	}
+}
+
+func TestCacheKeyCoversRequestShape(t *testing.T) {
+	base := CheckRequest{Method: "POST", Path: "/upload", Protocol: "HTTP/1.1", Size: 10, Body: []byte("0123456789")}
+	for name, change := range map[string]func(*CheckRequest){
+		"protocol":    func(r *CheckRequest) { r.Protocol = "HTTP/2.0" },
+		"size":        func(r *CheckRequest) { r.Size = 4096 },
+		"partialBody": func(r *CheckRequest) { r.PartialBody = true },
+	} {
+		req := base
+		change(&req)
+		if req.cacheKey() == base.cacheKey() {
+			t.Errorf("%s is not part of the cache key", name)
+		}
+	}
+}
@@ -0,0 +1,112 @@
+package extauthz
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"strings"
+
+	corev3 "github.com/envoyproxy/go-control-plane/envoy/config/core/v3"
+	authv3 "github.com/envoyproxy/go-control-plane/envoy/service/auth/v3"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
+)
+
+// grpcChecker calls Envoy's envoy.service.auth.v3.Authorization/Check, so
+// any ext_authz server built for Envoy can be used. The user is passed as
+// context extensions "user", "email", "roles" and "scopes".
+type grpcChecker struct {
+	conn   *grpc.ClientConn
+	client authv3.AuthorizationClient
+}
+
+func newGRPCChecker(addr string, useTLS bool) (*grpcChecker, error) {
+	creds := insecure.NewCredentials()
+	if useTLS {
+		creds = credentials.NewTLS(&tls.Config{MinVersion: tls.VersionTLS12})
+	}
+	conn, err := grpc.NewClient(addr, grpc.WithTransportCredentials(creds))
+	if err != nil {
+		return nil, fmt.Errorf("ext_authz grpc client: %w", err)
+	}
+	return &grpcChecker{conn: conn, client: authv3.NewAuthorizationClient(conn)}, nil
+}
+
+func (c *grpcChecker) Close() error { return c.conn.Close() }
+
+func (c *grpcChecker) Check(ctx context.Context, req *CheckRequest) (*Response, error) {
+	httpReq := &authv3.AttributeContext_HttpRequest{
+		Method:   req.Method,
+		Headers:  req.Headers,
+		Path:     req.Path,
+		Host:     req.Host,
+		Scheme:   req.Scheme,
+		Query:    req.Query,
+		Size:     req.Size,
+		Protocol: req.Protocol,
+		RawBody:  req.Body,
+	}
+	if req.Query != "" {
+		httpReq.Path += "?" + req.Query
+	}
+	resp, err := c.client.Check(ctx, &authv3.CheckRequest{
+		Attributes: &authv3.AttributeContext{
+			Source: &authv3.AttributeContext_Peer{
+				Address: &corev3.Address{Address: &corev3.Address_SocketAddress{
+					SocketAddress: &corev3.SocketAddress{Address: req.ClientIP},
+				}},
+			},
+			Request: &authv3.AttributeContext_Request{Http: httpReq},
+			ContextExtensions: map[string]string{
+				"user":   req.Auth.User,
+				"email":  req.Auth.Email,
+				"roles":  strings.Join(req.Auth.Roles, ","),
+				"scopes": strings.Join(req.Auth.Scopes, ","),
+			},
+		},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("ext_authz check failed: %w", err)
+	}
+
+	// Only these codes are decisions. Anything else, such as UNAVAILABLE
+	// or DEADLINE_EXCEEDED, means the service could not decide; it is not
+	// cached and failOpen applies.
+	out := &Response{}
+	switch code := codes.Code(resp.GetStatus().GetCode()); code {
+	case codes.OK:
+		out.Allow = true
+	case codes.PermissionDenied, codes.Unauthenticated:
+	default:
+		return nil, fmt.Errorf("ext_authz check failed: service returned %s: %s", code, resp.GetStatus().GetMessage())
+	}
+	if ok := resp.GetOkResponse(); ok != nil {
+		out.Headers = headerMap(ok.GetHeaders())
+		out.RemoveHeaders = ok.GetHeadersToRemove()
+	}
+	if denied := resp.GetDeniedResponse(); denied != nil {
+		out.Status = int(denied.GetStatus().GetCode())
+		out.ResponseHeaders = headerMap(denied.GetHeaders())
+		out.Body = denied.GetBody()
+	}
+	return out, nil
+}
+
+func headerMap(opts []*corev3.HeaderValueOption) map[string]string {
+	if len(opts) == 0 {
+		return nil
+	}
+	m := make(map[string]string, len(opts))
+	for _, o := range opts {
+		if h := o.GetHeader(); h != nil {
+			v := h.GetValue()
+			if v == "" {
+				v = string(h.GetRawValue())
+			}
+			m[h.GetKey()] = v
+		}
+	}
+	return m
+}
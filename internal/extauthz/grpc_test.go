@@ -0,0 +1,63 @@
+package extauthz
+
+import (
+	"context"
+	"net"
+	"testing"
+
+	authv3 "github.com/envoyproxy/go-control-plane/envoy/service/auth/v3"
+	rpcstatus "google.golang.org/genproto/googleapis/rpc/status"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+)
+
+// statusServer answers every check with a fixed status code.
+type statusServer struct {
+	authv3.UnimplementedAuthorizationServer
+	code codes.Code
+}
+
+func (s *statusServer) Check(context.Context, *authv3.CheckRequest) (*authv3.CheckResponse, error) {
+	return &authv3.CheckResponse{Status: &rpcstatus.Status{Code: int32(s.code), Message: "from test"}}, nil
+}
+
+func TestGRPCStatusMapping(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := grpc.NewServer()
+	fake := &statusServer{}
+	authv3.RegisterAuthorizationServer(srv, fake)
+	go srv.Serve(ln)
+	defer srv.Stop()
+
+	checker, err := newGRPCChecker(ln.Addr().String(), false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer checker.Close()
+
+	for _, tc := range []struct {
+		code    codes.Code
+		allow   bool
+		wantErr bool
+	}{
+		{codes.OK, true, false},
+		{codes.PermissionDenied, false, false},
+		{codes.Unauthenticated, false, false},
+		{codes.Unavailable, false, true},
+		{codes.DeadlineExceeded, false, true},
+		{codes.Internal, false, true},
+	} {
+		fake.code = tc.code
+		resp, err := checker.Check(context.Background(), &CheckRequest{Method: "GET", Path: "/"})
+		if (err != nil) != tc.wantErr {
+			t.Errorf("%s: err = %v", tc.code, err)
+			continue
+		}
+		if err == nil && resp.Allow != tc.allow {
+			t.Errorf("%s: allow = %v, want %v", tc.code, resp.Allow, tc.allow)
+		}
+	}
+}
@@ -0,0 +1,51 @@
+package extauthz
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+)
+
+// httpChecker POSTs the CheckRequest as JSON and expects a JSON Response
+// with status 200. Any other status is a failure of the service, not a
+// denial.
+type httpChecker struct {
+	url    string
+	client *http.Client
+}
+
+func newHTTPChecker(url string) *httpChecker {
+	return &httpChecker{url: url, client: &http.Client{}}
+}
+
+func (c *httpChecker) Check(ctx context.Context, req *CheckRequest) (*Response, error) {
+	body, err := json.Marshal(req)
+	if err != nil {
+		return nil, err
+	}
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, c.url, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+	httpReq.Header.Set("Accept", "application/json")
+
+	resp, err := c.client.Do(httpReq)
+	if err != nil {
+		return nil, fmt.Errorf("ext_authz request failed: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		io.Copy(io.Discard, io.LimitReader(resp.Body, 4<<10))
+		return nil, fmt.Errorf("ext_authz service returned %s", resp.Status)
+	}
+
+	var out Response
+	if err := json.NewDecoder(io.LimitReader(resp.Body, 1<<20)).Decode(&out); err != nil {
+		return nil, fmt.Errorf("invalid ext_authz response: %w", err)
+	}
+	return &out, nil
+}
@@ -0,0 +1,127 @@
+package filewatch
+
+import (
+	"context"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/rs/zerolog/log"
+)
+
+// debounce collapses bursts of events (editors, atomic renames, Kubernetes
+// secret symlink swaps) into a single callback.
+const debounce = 200 * time.Millisecond
+
+// Watch invokes onChange whenever one of the given files is created, written,
+// renamed or removed. Parent directories are watched rather than the files
+// themselves so that rotation by rename keeps working. Watch blocks until ctx
+// is cancelled.
+func Watch(ctx context.Context, paths []string, onChange func()) error {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return fmt.Errorf("failed to create file watcher: %w", err)
+	}
+	defer watcher.Close()
+
+	files := make(map[string]struct{}, len(paths))
+	dirs := make(map[string]struct{})
+	for _, p := range paths {
+		abs, err := filepath.Abs(p)
+		if err != nil {
+			return fmt.Errorf("failed to resolve %s: %w", p, err)
+		}
+		files[abs] = struct{}{}
+		dirs[filepath.Dir(abs)] = struct{}{}
+	}
+	for dir := range dirs {
+		if err := watcher.Add(dir); err != nil {
+			return fmt.Errorf("failed to watch %s: %w", dir, err)
+		}
+	}
+
+	timer := time.NewTimer(debounce)
+	timer.Stop()
+	defer timer.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case ev, ok := <-watcher.Events:
+			if !ok {
+				return nil
+			}
+			if _, watched := files[ev.Name]; watched || isSymlinkSwap(ev.Name) {
+				timer.Reset(debounce)
+			}
+		case err, ok := <-watcher.Errors:
+			if !ok {
+				return nil
+			}
+			log.Warn().Err(err).Msg("File watcher error")
+		case <-timer.C:
+			onChange()
+		}
+	}
+}
+
+// WatchDir invokes onChange whenever anything below dir changes, including
+// files in subdirectories created later. WatchDir blocks until ctx is
+// cancelled.
+func WatchDir(ctx context.Context, dir string, onChange func()) error {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return fmt.Errorf("failed to create file watcher: %w", err)
+	}
+	defer watcher.Close()
+
+	err = filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || !d.IsDir() {
+			return err
+		}
+		return watcher.Add(path)
+	})
+	if err != nil {
+		return fmt.Errorf("failed to watch %s: %w", dir, err)
+	}
+
+	timer := time.NewTimer(debounce)
+	timer.Stop()
+	defer timer.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case ev, ok := <-watcher.Events:
+			if !ok {
+				return nil
+			}
+			if ev.Has(fsnotify.Create) {
+				if fi, err := os.Stat(ev.Name); err == nil && fi.IsDir() {
+					if err := watcher.Add(ev.Name); err != nil {
+						log.Warn().Err(err).Str("dir", ev.Name).Msg("Failed to watch new directory")
+					}
+				}
+			}
+			timer.Reset(debounce)
+		case err, ok := <-watcher.Errors:
+			if !ok {
+				return nil
+			}
+			log.Warn().Err(err).Msg("File watcher error")
+		case <-timer.C:
+			onChange()
+		}
+	}
+}
+
+// isSymlinkSwap reports whether the event belongs to the "..data" symlink
+// Kubernetes uses to atomically update mounted secrets and config maps.
+func isSymlinkSwap(name string) bool {
+	return filepath.Base(name) == "..data"
+}
@@ -0,0 +1,24 @@
+// Package hostmatch matches hostnames against exact and wildcard patterns.
+package hostmatch
+
+import "strings"
+
+// Match reports whether host matches one of the patterns, ignoring case.
+// "*.example.com" matches any subdomain of example.com but not example.com
+// itself. host must not carry a port.
+func Match(patterns []string, host string) bool {
+	host = strings.TrimSuffix(strings.ToLower(host), ".")
+	for _, p := range patterns {
+		p = strings.ToLower(p)
+		if suffix, ok := strings.CutPrefix(p, "*"); ok {
+			if strings.HasSuffix(host, suffix) && len(host) > len(suffix) {
+				return true
+			}
+			continue
+		}
+		if host == p {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,484 @@
+package ingress
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"slices"
+	"sort"
+	"time"
+
+	"github.com/rs/zerolog/log"
+	networkingv1 "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/client-go/informers"
+	"k8s.io/client-go/kubernetes"
+	networkinglisters "k8s.io/client-go/listers/networking/v1"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/client-go/tools/clientcmd"
+	gatewayv1 "sigs.k8s.io/gateway-api/apis/v1"
+	gatewayclient "sigs.k8s.io/gateway-api/pkg/client/clientset/versioned"
+	gatewayinformers "sigs.k8s.io/gateway-api/pkg/client/informers/externalversions"
+	gatewaylisters "sigs.k8s.io/gateway-api/pkg/client/listers/apis/v1"
+
+	"github.com/shrihariharanba/go-gateway/internal/config"
+)
+
+const (
+	// legacyIngressClass is the pre-IngressClass annotation still used by
+	// many manifests.
+	legacyIngressClass = "kubernetes.io/ingress.class"
+
+	syncDebounce = 500 * time.Millisecond
+	retryDelay   = 10 * time.Second
+)
+
+type Config struct {
+	Kubeconfig       string
+	Namespace        string
+	ControllerName   string
+	WatchIngress     bool
+	IngressClassName string
+	PublishAddress   string
+}
+
+// ApplyFunc receives the complete set of routes derived from the cluster
+// every time it changes.
+type ApplyFunc func(routes []config.RouteConfig) error
+
+// Controller watches Gateway API resources (and optionally Ingresses),
+// translates them into routes and reports status back to the cluster.
+type Controller struct {
+	cfg     Config
+	kube    kubernetes.Interface
+	gateway gatewayclient.Interface
+	apply   ApplyFunc
+
+	trigger chan struct{}
+	applied []config.RouteConfig
+
+	classes   gatewaylisters.GatewayClassLister
+	gateways  gatewaylisters.GatewayLister
+	routes    gatewaylisters.HTTPRouteLister
+	ingresses networkinglisters.IngressLister
+}
+
+// New connects using cfg.Kubeconfig, or the in-cluster service account when
+// no kubeconfig is given.
+func New(cfg Config, apply ApplyFunc) (*Controller, error) {
+	var (
+		restCfg *rest.Config
+		err     error
+	)
+	if cfg.Kubeconfig != "" {
+		restCfg, err = clientcmd.BuildConfigFromFlags("", cfg.Kubeconfig)
+	} else {
+		restCfg, err = rest.InClusterConfig()
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to load kubernetes client config: %w", err)
+	}
+
+	kube, err := kubernetes.NewForConfig(restCfg)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create kubernetes client: %w", err)
+	}
+	gw, err := gatewayclient.NewForConfig(restCfg)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create gateway api client: %w", err)
+	}
+	return NewWithClients(cfg, kube, gw, apply)
+}
+
+// NewWithClients uses existing clientsets, such as fake ones in tests.
+func NewWithClients(cfg Config, kube kubernetes.Interface, gw gatewayclient.Interface, apply ApplyFunc) (*Controller, error) {
+	if cfg.ControllerName == "" {
+		return nil, errors.New("ingress controller requires a controller name")
+	}
+	return &Controller{
+		cfg:     cfg,
+		kube:    kube,
+		gateway: gw,
+		apply:   apply,
+		trigger: make(chan struct{}, 1),
+	}, nil
+}
+
+// Run starts the informers and reconciles until ctx is cancelled. All
+// resource events collapse into a single full resync, which keeps status and
+// routes consistent with each other.
+func (c *Controller) Run(ctx context.Context) error {
+	if err := c.startInformers(ctx); err != nil {
+		return err
+	}
+
+	log.Info().Str("controller", c.cfg.ControllerName).Msg("Ingress controller started")
+	c.enqueue()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-c.trigger:
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(syncDebounce):
+		}
+
+		if err := c.Sync(ctx); err != nil {
+			log.Error().Err(err).Msg("Ingress controller sync failed")
+			time.AfterFunc(retryDelay, c.enqueue)
+		}
+	}
+}
+
+// startInformers starts the informers, which stop with ctx, and waits for
+// their caches to fill.
+func (c *Controller) startInformers(ctx context.Context) error {
+	gwFactory := gatewayinformers.NewSharedInformerFactoryWithOptions(c.gateway, 0,
+		gatewayinformers.WithNamespace(c.cfg.Namespace))
+	kubeFactory := informers.NewSharedInformerFactoryWithOptions(c.kube, 0,
+		informers.WithNamespace(c.cfg.Namespace))
+
+	handler := cache.ResourceEventHandlerFuncs{
+		AddFunc:    func(any) { c.enqueue() },
+		UpdateFunc: func(any, any) { c.enqueue() },
+		DeleteFunc: func(any) { c.enqueue() },
+	}
+
+	v1 := gwFactory.Gateway().V1()
+	for _, inf := range []cache.SharedIndexInformer{
+		v1.GatewayClasses().Informer(),
+		v1.Gateways().Informer(),
+		v1.HTTPRoutes().Informer(),
+	} {
+		if _, err := inf.AddEventHandler(handler); err != nil {
+			return err
+		}
+	}
+	c.classes = v1.GatewayClasses().Lister()
+	c.gateways = v1.Gateways().Lister()
+	c.routes = v1.HTTPRoutes().Lister()
+
+	if c.cfg.WatchIngress {
+		inf := kubeFactory.Networking().V1().Ingresses()
+		if _, err := inf.Informer().AddEventHandler(handler); err != nil {
+			return err
+		}
+		c.ingresses = inf.Lister()
+	}
+
+	gwFactory.Start(ctx.Done())
+	kubeFactory.Start(ctx.Done())
+	go func() {
+		<-ctx.Done()
+		gwFactory.Shutdown()
+		kubeFactory.Shutdown()
+	}()
+
+	for typ, ok := range gwFactory.WaitForCacheSync(ctx.Done()) {
+		if !ok {
+			return fmt.Errorf("failed to sync %v informer", typ)
+		}
+	}
+	for typ, ok := range kubeFactory.WaitForCacheSync(ctx.Done()) {
+		if !ok {
+			return fmt.Errorf("failed to sync %v informer", typ)
+		}
+	}
+	return nil
+}
+
+func (c *Controller) enqueue() {
+	select {
+	case c.trigger <- struct{}{}:
+	default:
+	}
+}
+
+// Sync performs one full reconciliation from the informer caches.
+func (c *Controller) Sync(ctx context.Context) error {
+	classes, err := c.classes.List(labels.Everything())
+	if err != nil {
+		return err
+	}
+	ownedClasses := make(map[string]bool)
+	for _, gc := range classes {
+		if string(gc.Spec.ControllerName) == c.cfg.ControllerName {
+			ownedClasses[gc.Name] = true
+			c.updateGatewayClassStatus(ctx, gc)
+		}
+	}
+
+	gateways, err := c.gateways.List(labels.Everything())
+	if err != nil {
+		return err
+	}
+	ownedGateways := make(map[string]*gatewayv1.Gateway)
+	for _, gw := range gateways {
+		if ownedClasses[string(gw.Spec.GatewayClassName)] {
+			ownedGateways[gw.Namespace+"/"+gw.Name] = gw
+		}
+	}
+
+	httpRoutes, err := c.routes.List(labels.Everything())
+	if err != nil {
+		return err
+	}
+	sort.Slice(httpRoutes, func(i, j int) bool {
+		return httpRoutes[i].CreationTimestamp.Before(&httpRoutes[j].CreationTimestamp)
+	})
+
+	// Status is only written once the routes are live, so Accepted and
+	// Programmed never claim more than the gateway serves.
+	var statusUpdates []func()
+
+	var routes []config.RouteConfig
+	attached := make(map[string]map[gatewayv1.SectionName]int)
+	var namespaces map[string]map[string]string
+	if selectsNamespaces(ownedGateways) {
+		namespaces = c.namespaceLabels(ctx)
+	}
+	for _, hr := range httpRoutes {
+		parents := bindParents(hr, ownedGateways, namespaces)
+		if len(parents) == 0 {
+			continue
+		}
+
+		var err error
+		if !slices.ContainsFunc(parents, parentBinding.accepted) {
+			err = errNotAllowed
+		} else if translated, terr := c.translateHTTPRoute(hr); terr != nil {
+			err = terr
+			log.Warn().Err(err).Str("httproute", hr.Namespace+"/"+hr.Name).Msg("HTTPRoute rejected")
+		} else {
+			routes = append(routes, translated...)
+			for _, p := range parents {
+				key := p.gateway.Namespace + "/" + p.gateway.Name
+				if attached[key] == nil {
+					attached[key] = make(map[gatewayv1.SectionName]int)
+				}
+				for _, l := range p.listeners {
+					attached[key][l]++
+				}
+			}
+		}
+		statusUpdates = append(statusUpdates, func() { c.updateHTTPRouteStatus(ctx, hr, parents, err) })
+	}
+
+	for key, gw := range ownedGateways {
+		statusUpdates = append(statusUpdates, func() { c.updateGatewayStatus(ctx, gw, attached[key]) })
+	}
+
+	if c.ingresses != nil {
+		ingresses, err := c.ingresses.List(labels.Everything())
+		if err != nil {
+			return err
+		}
+		for _, ing := range ingresses {
+			if !c.ownsIngress(ing) {
+				continue
+			}
+			translated, err := TranslateIngress(ing, c.cfg.Kubeconfig)
+			if err == nil {
+				err = validate(translated)
+			}
+			if err != nil {
+				log.Warn().Err(err).Str("ingress", ing.Namespace+"/"+ing.Name).Msg("Ingress rejected")
+				continue
+			}
+			routes = append(routes, translated...)
+			statusUpdates = append(statusUpdates, func() { c.updateIngressStatus(ctx, ing) })
+		}
+	}
+
+	// Host-specific routes must be tried before catch-alls on the same path.
+	sort.SliceStable(routes, func(i, j int) bool {
+		return len(routes[i].Hosts) > 0 && len(routes[j].Hosts) == 0
+	})
+
+	if !reflect.DeepEqual(routes, c.applied) {
+		if err := c.apply(routes); err != nil {
+			return fmt.Errorf("failed to apply routes: %w", err)
+		}
+		c.applied = routes
+		log.Info().Int("routes", len(routes)).Msg("Ingress routes applied")
+	}
+	for _, update := range statusUpdates {
+		update()
+	}
+	return nil
+}
+
+func (c *Controller) translateHTTPRoute(hr *gatewayv1.HTTPRoute) ([]config.RouteConfig, error) {
+	routes, err := TranslateHTTPRoute(hr, c.cfg.Kubeconfig)
+	if err != nil {
+		return nil, err
+	}
+	if err := validate(routes); err != nil {
+		return nil, unsupported("%s", err)
+	}
+	return routes, nil
+}
+
+func (c *Controller) ownsIngress(ing *networkingv1.Ingress) bool {
+	if ing.Spec.IngressClassName != nil {
+		return *ing.Spec.IngressClassName == c.cfg.IngressClassName
+	}
+	return ing.Annotations[legacyIngressClass] == c.cfg.IngressClassName
+}
+
+// errNotAllowed rejects a route none of whose parents accept it; the
+// reasons are reported per parent.
+var errNotAllowed = errors.New("not allowed by any parent gateway listener")
+
+// parentBinding is a parentRef of an HTTPRoute that points at one of our
+// Gateways, with the listeners the route attaches to. A binding without
+// listeners was refused for reason.
+type parentBinding struct {
+	ref       gatewayv1.ParentReference
+	gateway   *gatewayv1.Gateway
+	listeners []gatewayv1.SectionName
+	reason    gatewayv1.RouteConditionReason
+	message   string
+}
+
+func (b parentBinding) accepted() bool { return len(b.listeners) > 0 }
+
+// bindParents matches the parentRefs of a route that point at one of our
+// Gateways against the Gateway's listeners, honouring sectionName, port and
+// each listener's allowedRoutes (namespaces and kinds).
+func bindParents(hr *gatewayv1.HTTPRoute, owned map[string]*gatewayv1.Gateway, namespaces map[string]map[string]string) []parentBinding {
+	var parents []parentBinding
+	for _, ref := range hr.Spec.ParentRefs {
+		if ref.Group != nil && *ref.Group != gatewayv1.GroupName {
+			continue
+		}
+		if ref.Kind != nil && *ref.Kind != "Gateway" {
+			continue
+		}
+		gw, ok := owned[parentKey(hr.Namespace, ref)]
+		if !ok {
+			continue
+		}
+
+		b := parentBinding{ref: ref, gateway: gw}
+		matched := false
+		for _, l := range gw.Spec.Listeners {
+			if ref.SectionName != nil && *ref.SectionName != l.Name {
+				continue
+			}
+			if ref.Port != nil && *ref.Port != l.Port {
+				continue
+			}
+			matched = true
+			if allowsHTTPRoute(l) && allowsNamespace(l, gw.Namespace, hr.Namespace, namespaces) {
+				b.listeners = append(b.listeners, l.Name)
+			}
+		}
+		switch {
+		case !matched:
+			b.reason = gatewayv1.RouteReasonNoMatchingParent
+			b.message = "no listener matches the parentRef sectionName and port"
+		case !b.accepted():
+			b.reason = gatewayv1.RouteReasonNotAllowedByListeners
+			b.message = "the listeners' allowedRoutes do not admit this route"
+		}
+		parents = append(parents, b)
+	}
+	return parents
+}
+
+// allowsHTTPRoute checks allowedRoutes.kinds, which defaults to HTTPRoute
+// on HTTP and HTTPS listeners only.
+func allowsHTTPRoute(l gatewayv1.Listener) bool {
+	if l.AllowedRoutes != nil && len(l.AllowedRoutes.Kinds) > 0 {
+		return slices.ContainsFunc(l.AllowedRoutes.Kinds, func(k gatewayv1.RouteGroupKind) bool {
+			return k.Kind == "HTTPRoute" && (k.Group == nil || *k.Group == gatewayv1.GroupName)
+		})
+	}
+	return l.Protocol == gatewayv1.HTTPProtocolType || l.Protocol == gatewayv1.HTTPSProtocolType
+}
+
+// allowsNamespace checks allowedRoutes.namespaces, which defaults to the
+// Gateway's own namespace.
+func allowsNamespace(l gatewayv1.Listener, gatewayNamespace, routeNamespace string, namespaces map[string]map[string]string) bool {
+	from := gatewayv1.NamespacesFromSame
+	var selector *metav1.LabelSelector
+	if l.AllowedRoutes != nil && l.AllowedRoutes.Namespaces != nil {
+		if l.AllowedRoutes.Namespaces.From != nil {
+			from = *l.AllowedRoutes.Namespaces.From
+		}
+		selector = l.AllowedRoutes.Namespaces.Selector
+	}
+
+	switch from {
+	case gatewayv1.NamespacesFromAll:
+		return true
+	case gatewayv1.NamespacesFromSame:
+		return routeNamespace == gatewayNamespace
+	case gatewayv1.NamespacesFromSelector:
+		if selector == nil {
+			return false
+		}
+		sel, err := metav1.LabelSelectorAsSelector(selector)
+		if err != nil {
+			return false
+		}
+		nsLabels, ok := namespaces[routeNamespace]
+		return ok && sel.Matches(labels.Set(nsLabels))
+	default:
+		return false
+	}
+}
+
+func selectsNamespaces(gateways map[string]*gatewayv1.Gateway) bool {
+	for _, gw := range gateways {
+		for _, l := range gw.Spec.Listeners {
+			if ar := l.AllowedRoutes; ar != nil && ar.Namespaces != nil && ar.Namespaces.From != nil &&
+				*ar.Namespaces.From == gatewayv1.NamespacesFromSelector {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// namespaceLabels returns the labels of every namespace, for listeners that
+// select routes by namespace. Without permission to list namespaces such
+// listeners admit nothing.
+func (c *Controller) namespaceLabels(ctx context.Context) map[string]map[string]string {
+	list, err := c.kube.CoreV1().Namespaces().List(ctx, metav1.ListOptions{})
+	if err != nil {
+		log.Warn().Err(err).Msg("Failed to list namespaces for allowedRoutes selectors")
+		return nil
+	}
+	out := make(map[string]map[string]string, len(list.Items))
+	for _, ns := range list.Items {
+		out[ns.Name] = ns.Labels
+	}
+	return out
+}
+
+func parentKey(routeNamespace string, ref gatewayv1.ParentReference) string {
+	ns := routeNamespace
+	if ref.Namespace != nil {
+		ns = string(*ref.Namespace)
+	}
+	return ns + "/" + string(ref.Name)
+}
+
+func validate(routes []config.RouteConfig) error {
+	for _, r := range routes {
+		if err := r.Validate(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
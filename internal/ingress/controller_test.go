@@ -0,0 +1,192 @@
+package ingress
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	kubefake "k8s.io/client-go/kubernetes/fake"
+	gatewayv1 "sigs.k8s.io/gateway-api/apis/v1"
+	gatewayfake "sigs.k8s.io/gateway-api/pkg/client/clientset/versioned/fake"
+
+	"github.com/shrihariharanba/go-gateway/internal/config"
+)
+
+const controllerName = "go-gateway.io/test"
+
+func gateway(listeners ...gatewayv1.Listener) *gatewayv1.Gateway {
+	return &gatewayv1.Gateway{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "infra", Name: "gw"},
+		Spec:       gatewayv1.GatewaySpec{GatewayClassName: "go-gateway", Listeners: listeners},
+	}
+}
+
+func listener(name string, port int32, from *gatewayv1.FromNamespaces) gatewayv1.Listener {
+	l := gatewayv1.Listener{Name: gatewayv1.SectionName(name), Port: gatewayv1.PortNumber(port), Protocol: gatewayv1.HTTPProtocolType}
+	if from != nil {
+		l.AllowedRoutes = &gatewayv1.AllowedRoutes{Namespaces: &gatewayv1.RouteNamespaces{From: from}}
+	}
+	return l
+}
+
+// recorder is an ApplyFunc that keeps the last routes and can fail.
+type recorder struct {
+	mu     sync.Mutex
+	routes []config.RouteConfig
+	calls  int
+	err    error
+}
+
+func (r *recorder) apply(routes []config.RouteConfig) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.calls++
+	if r.err != nil {
+		return r.err
+	}
+	r.routes = routes
+	return nil
+}
+
+// syncRoutes builds a controller over the objects and runs one reconciliation.
+func syncRoutes(t *testing.T, rec *recorder, objects ...*gatewayv1.HTTPRoute) (*gatewayfake.Clientset, error) {
+	t.Helper()
+	return syncGateway(t, rec, gateway(listener("http", 80, nil)), objects...)
+}
+
+func syncGateway(t *testing.T, rec *recorder, gw *gatewayv1.Gateway, objects ...*gatewayv1.HTTPRoute) (*gatewayfake.Clientset, error) {
+	t.Helper()
+	class := &gatewayv1.GatewayClass{
+		ObjectMeta: metav1.ObjectMeta{Name: "go-gateway"},
+		Spec:       gatewayv1.GatewayClassSpec{ControllerName: controllerName},
+	}
+	// The object tracker guesses "gatewaies" for Gateway, so objects are
+	// created through the typed client rather than passed in.
+	gwClient := gatewayfake.NewSimpleClientset(class)
+	if _, err := gwClient.GatewayV1().Gateways(gw.Namespace).Create(context.Background(), gw, metav1.CreateOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	for _, hr := range objects {
+		if _, err := gwClient.GatewayV1().HTTPRoutes(hr.Namespace).Create(context.Background(), hr, metav1.CreateOptions{}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	kube := kubefake.NewSimpleClientset(
+		&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "infra"}},
+		&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "shop", Labels: map[string]string{"gateway": "shared"}}},
+	)
+
+	c, err := NewWithClients(Config{ControllerName: controllerName}, kube, gwClient, rec.apply)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if err := c.startInformers(ctx); err != nil {
+		t.Fatal(err)
+	}
+	return gwClient, c.Sync(ctx)
+}
+
+func routeAccepted(t *testing.T, client *gatewayfake.Clientset, namespace, name string) *metav1.Condition {
+	t.Helper()
+	hr, err := client.GatewayV1().HTTPRoutes(namespace).Get(context.Background(), name, metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, ps := range hr.Status.Parents {
+		if string(ps.ControllerName) == controllerName {
+			return meta.FindStatusCondition(ps.Conditions, string(gatewayv1.RouteConditionAccepted))
+		}
+	}
+	return nil
+}
+
+func TestSyncSameNamespaceByDefault(t *testing.T) {
+	rec := &recorder{}
+	client, err := syncRoutes(t, rec,
+		httpRoute("infra", "own", rule(gatewayv1.PathMatchExact, "/own", "web", 80)),
+		httpRoute("shop", "foreign", rule(gatewayv1.PathMatchExact, "/foreign", "web", 80)),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(rec.routes) != 1 || rec.routes[0].Path != "/own" {
+		t.Fatalf("applied routes = %v, want only /own", rec.routes)
+	}
+	if c := routeAccepted(t, client, "infra", "own"); c == nil || c.Status != metav1.ConditionTrue {
+		t.Errorf("own route Accepted = %v", c)
+	}
+	c := routeAccepted(t, client, "shop", "foreign")
+	if c == nil || c.Status != metav1.ConditionFalse || c.Reason != string(gatewayv1.RouteReasonNotAllowedByListeners) {
+		t.Errorf("foreign route Accepted = %v, want False/NotAllowedByListeners", c)
+	}
+
+	gw, _ := client.GatewayV1().Gateways("infra").Get(context.Background(), "gw", metav1.GetOptions{})
+	if len(gw.Status.Listeners) != 1 || gw.Status.Listeners[0].AttachedRoutes != 1 {
+		t.Errorf("listener status = %+v", gw.Status.Listeners)
+	}
+}
+
+func TestSyncAllowedRoutes(t *testing.T) {
+	all, selector := gatewayv1.NamespacesFromAll, gatewayv1.NamespacesFromSelector
+	selected := listener("selected", 8081, &selector)
+	selected.AllowedRoutes.Namespaces.Selector = &metav1.LabelSelector{MatchLabels: map[string]string{"gateway": "shared"}}
+	gw := gateway(listener("same", 80, nil), listener("open", 8080, &all), selected)
+
+	toSection := func(hr *gatewayv1.HTTPRoute, section string) *gatewayv1.HTTPRoute {
+		hr.Spec.ParentRefs[0].SectionName = ptr(gatewayv1.SectionName(section))
+		return hr
+	}
+	rec := &recorder{}
+	client, err := syncGateway(t, rec, gw,
+		toSection(httpRoute("shop", "open", rule(gatewayv1.PathMatchExact, "/open", "web", 80)), "open"),
+		toSection(httpRoute("shop", "same", rule(gatewayv1.PathMatchExact, "/same", "web", 80)), "same"),
+		toSection(httpRoute("shop", "selected", rule(gatewayv1.PathMatchExact, "/selected", "web", 80)), "selected"),
+		toSection(httpRoute("shop", "missing", rule(gatewayv1.PathMatchExact, "/missing", "web", 80)), "missing"),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	paths := map[string]bool{}
+	for _, r := range rec.routes {
+		paths[r.Path] = true
+	}
+	if !paths["/open"] || !paths["/selected"] || paths["/same"] || paths["/missing"] {
+		t.Errorf("applied paths = %v, want /open and /selected", paths)
+	}
+	if c := routeAccepted(t, client, "shop", "missing"); c == nil || c.Reason != string(gatewayv1.RouteReasonNoMatchingParent) {
+		t.Errorf("missing section Accepted = %v, want NoMatchingParent", c)
+	}
+
+	updated, _ := client.GatewayV1().Gateways("infra").Get(context.Background(), "gw", metav1.GetOptions{})
+	attached := map[gatewayv1.SectionName]int32{}
+	for _, l := range updated.Status.Listeners {
+		attached[l.Name] = l.AttachedRoutes
+	}
+	if attached["same"] != 0 || attached["open"] != 1 || attached["selected"] != 1 {
+		t.Errorf("attached routes = %v", attached)
+	}
+}
+
+func TestSyncStatusAfterApply(t *testing.T) {
+	rec := &recorder{err: errors.New("boom")}
+	client, err := syncRoutes(t, rec, httpRoute("infra", "own", rule(gatewayv1.PathMatchExact, "/own", "web", 80)))
+	if err == nil {
+		t.Fatal("Sync should fail when apply fails")
+	}
+	if c := routeAccepted(t, client, "infra", "own"); c != nil {
+		t.Errorf("route status written before apply succeeded: %v", c)
+	}
+	gw, _ := client.GatewayV1().Gateways("infra").Get(context.Background(), "gw", metav1.GetOptions{})
+	if meta.FindStatusCondition(gw.Status.Conditions, string(gatewayv1.GatewayConditionProgrammed)) != nil {
+		t.Error("Gateway marked Programmed before apply succeeded")
+	}
+}
@@ -0,0 +1,179 @@
+package ingress
+
+import (
+	"context"
+	"errors"
+	"net"
+
+	"github.com/rs/zerolog/log"
+	networkingv1 "k8s.io/api/networking/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	gatewayv1 "sigs.k8s.io/gateway-api/apis/v1"
+)
+
+func (c *Controller) updateGatewayClassStatus(ctx context.Context, gc *gatewayv1.GatewayClass) {
+	updated := gc.DeepCopy()
+	meta.SetStatusCondition(&updated.Status.Conditions, metav1.Condition{
+		Type:               string(gatewayv1.GatewayClassConditionStatusAccepted),
+		Status:             metav1.ConditionTrue,
+		Reason:             string(gatewayv1.GatewayClassReasonAccepted),
+		Message:            "Handled by " + c.cfg.ControllerName,
+		ObservedGeneration: gc.Generation,
+	})
+	if equality.Semantic.DeepEqual(gc.Status, updated.Status) {
+		return
+	}
+	if _, err := c.gateway.GatewayV1().GatewayClasses().UpdateStatus(ctx, updated, metav1.UpdateOptions{}); err != nil {
+		log.Warn().Err(err).Str("gatewayclass", gc.Name).Msg("Failed to update GatewayClass status")
+	}
+}
+
+func (c *Controller) updateGatewayStatus(ctx context.Context, gw *gatewayv1.Gateway, attachedRoutes map[gatewayv1.SectionName]int) {
+	updated := gw.DeepCopy()
+	for _, cond := range []metav1.Condition{
+		{
+			Type:   string(gatewayv1.GatewayConditionAccepted),
+			Reason: string(gatewayv1.GatewayReasonAccepted),
+		},
+		{
+			Type:   string(gatewayv1.GatewayConditionProgrammed),
+			Reason: string(gatewayv1.GatewayReasonProgrammed),
+		},
+	} {
+		cond.Status = metav1.ConditionTrue
+		cond.ObservedGeneration = gw.Generation
+		meta.SetStatusCondition(&updated.Status.Conditions, cond)
+	}
+
+	if c.cfg.PublishAddress != "" {
+		addrType := gatewayv1.HostnameAddressType
+		if net.ParseIP(c.cfg.PublishAddress) != nil {
+			addrType = gatewayv1.IPAddressType
+		}
+		updated.Status.Addresses = []gatewayv1.GatewayStatusAddress{{Type: &addrType, Value: c.cfg.PublishAddress}}
+	}
+
+	group := gatewayv1.Group(gatewayv1.GroupName)
+	listeners := make([]gatewayv1.ListenerStatus, 0, len(gw.Spec.Listeners))
+	for _, l := range gw.Spec.Listeners {
+		status := gatewayv1.ListenerStatus{
+			Name:           l.Name,
+			SupportedKinds: []gatewayv1.RouteGroupKind{{Group: &group, Kind: "HTTPRoute"}},
+			AttachedRoutes: int32(attachedRoutes[l.Name]),
+		}
+		for _, existing := range gw.Status.Listeners {
+			if existing.Name == l.Name {
+				status.Conditions = existing.Conditions
+			}
+		}
+		meta.SetStatusCondition(&status.Conditions, metav1.Condition{
+			Type:               string(gatewayv1.ListenerConditionProgrammed),
+			Status:             metav1.ConditionTrue,
+			Reason:             string(gatewayv1.ListenerReasonProgrammed),
+			ObservedGeneration: gw.Generation,
+		})
+		listeners = append(listeners, status)
+	}
+	updated.Status.Listeners = listeners
+
+	if equality.Semantic.DeepEqual(gw.Status, updated.Status) {
+		return
+	}
+	if _, err := c.gateway.GatewayV1().Gateways(gw.Namespace).UpdateStatus(ctx, updated, metav1.UpdateOptions{}); err != nil {
+		log.Warn().Err(err).Str("gateway", gw.Namespace+"/"+gw.Name).Msg("Failed to update Gateway status")
+	}
+}
+
+// updateHTTPRouteStatus records Accepted and ResolvedRefs for each of our
+// parents, leaving entries written by other controllers untouched. Parents
+// that refused the route report why.
+func (c *Controller) updateHTTPRouteStatus(ctx context.Context, hr *gatewayv1.HTTPRoute, parents []parentBinding, translateErr error) {
+	accepted := metav1.Condition{
+		Type:   string(gatewayv1.RouteConditionAccepted),
+		Status: metav1.ConditionTrue,
+		Reason: string(gatewayv1.RouteReasonAccepted),
+	}
+	resolved := metav1.Condition{
+		Type:   string(gatewayv1.RouteConditionResolvedRefs),
+		Status: metav1.ConditionTrue,
+		Reason: string(gatewayv1.RouteReasonResolvedRefs),
+	}
+
+	var te *TranslateError
+	if errors.As(translateErr, &te) {
+		target := &accepted
+		if te.Condition == string(gatewayv1.RouteConditionResolvedRefs) {
+			target = &resolved
+		}
+		target.Status = metav1.ConditionFalse
+		target.Reason = te.Reason
+		target.Message = te.Message
+	} else if translateErr != nil && !errors.Is(translateErr, errNotAllowed) {
+		accepted.Status = metav1.ConditionFalse
+		accepted.Reason = string(gatewayv1.RouteReasonUnsupportedValue)
+		accepted.Message = translateErr.Error()
+	}
+
+	updated := hr.DeepCopy()
+	var statuses []gatewayv1.RouteParentStatus
+	for _, ps := range hr.Status.Parents {
+		if string(ps.ControllerName) != c.cfg.ControllerName {
+			statuses = append(statuses, ps)
+		}
+	}
+	for _, p := range parents {
+		ref := p.ref
+		ps := gatewayv1.RouteParentStatus{
+			ParentRef:      ref,
+			ControllerName: gatewayv1.GatewayController(c.cfg.ControllerName),
+		}
+		for _, existing := range hr.Status.Parents {
+			if string(existing.ControllerName) == c.cfg.ControllerName && equality.Semantic.DeepEqual(existing.ParentRef, ref) {
+				ps.Conditions = existing.Conditions
+			}
+		}
+		parentAccepted := accepted
+		if !p.accepted() {
+			parentAccepted.Status = metav1.ConditionFalse
+			parentAccepted.Reason = string(p.reason)
+			parentAccepted.Message = p.message
+		}
+		for _, cond := range []metav1.Condition{parentAccepted, resolved} {
+			cond.ObservedGeneration = hr.Generation
+			meta.SetStatusCondition(&ps.Conditions, cond)
+		}
+		statuses = append(statuses, ps)
+	}
+	updated.Status.Parents = statuses
+
+	if equality.Semantic.DeepEqual(hr.Status, updated.Status) {
+		return
+	}
+	if _, err := c.gateway.GatewayV1().HTTPRoutes(hr.Namespace).UpdateStatus(ctx, updated, metav1.UpdateOptions{}); err != nil {
+		log.Warn().Err(err).Str("httproute", hr.Namespace+"/"+hr.Name).Msg("Failed to update HTTPRoute status")
+	}
+}
+
+// updateIngressStatus publishes the gateway address. Ingress has no
+// conditions, so the load balancer address is the only status to report.
+func (c *Controller) updateIngressStatus(ctx context.Context, ing *networkingv1.Ingress) {
+	if c.cfg.PublishAddress == "" {
+		return
+	}
+
+	lb := networkingv1.IngressLoadBalancerIngress{Hostname: c.cfg.PublishAddress}
+	if net.ParseIP(c.cfg.PublishAddress) != nil {
+		lb = networkingv1.IngressLoadBalancerIngress{IP: c.cfg.PublishAddress}
+	}
+
+	updated := ing.DeepCopy()
+	updated.Status.LoadBalancer.Ingress = []networkingv1.IngressLoadBalancerIngress{lb}
+	if equality.Semantic.DeepEqual(ing.Status, updated.Status) {
+		return
+	}
+	if _, err := c.kube.NetworkingV1().Ingresses(ing.Namespace).UpdateStatus(ctx, updated, metav1.UpdateOptions{}); err != nil {
+		log.Warn().Err(err).Str("ingress", ing.Namespace+"/"+ing.Name).Msg("Failed to update Ingress status")
+	}
+}
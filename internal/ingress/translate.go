@@ -0,0 +1,236 @@
+package ingress
+
+import (
+	"fmt"
+	"strings"
+
+	networkingv1 "k8s.io/api/networking/v1"
+	gatewayv1 "sigs.k8s.io/gateway-api/apis/v1"
+
+	"github.com/shrihariharanba/go-gateway/internal/config"
+	discoveryProviders "github.com/shrihariharanba/go-gateway/internal/discovery/providers"
+)
+
+// Annotations read from HTTPRoute and Ingress resources to set the auth
+// policy of the generated routes.
+const (
+	AnnotationAuthPolicy = "go-gateway.io/auth-policy" // required (default), optional or none
+	AnnotationScopes     = "go-gateway.io/scopes"      // comma separated
+)
+
+// TranslateError is returned when a resource cannot be translated. Condition
+// and Reason are the Gateway API status condition type and reason to report.
+type TranslateError struct {
+	Condition string
+	Reason    string
+	Message   string
+}
+
+func (e *TranslateError) Error() string { return e.Message }
+
+func unsupported(format string, args ...any) *TranslateError {
+	return &TranslateError{
+		Condition: string(gatewayv1.RouteConditionAccepted),
+		Reason:    string(gatewayv1.RouteReasonUnsupportedValue),
+		Message:   fmt.Sprintf(format, args...),
+	}
+}
+
+func unresolved(reason gatewayv1.RouteConditionReason, format string, args ...any) *TranslateError {
+	return &TranslateError{
+		Condition: string(gatewayv1.RouteConditionResolvedRefs),
+		Reason:    string(reason),
+		Message:   fmt.Sprintf(format, args...),
+	}
+}
+
+// TranslateHTTPRoute converts an HTTPRoute into gateway routes. Backends are
+// resolved through Kubernetes EndpointSlice discovery using kubeconfig.
+// Features the gateway cannot honour (filters, header/query/method matches,
+// regular expressions, weighted backends) reject the whole route rather than
+// silently routing traffic differently from what was declared.
+func TranslateHTTPRoute(route *gatewayv1.HTTPRoute, kubeconfig string) ([]config.RouteConfig, error) {
+	policy, scopes := authFromAnnotations(route.Annotations)
+
+	hosts := make([]string, 0, len(route.Spec.Hostnames))
+	for _, h := range route.Spec.Hostnames {
+		hosts = append(hosts, string(h))
+	}
+
+	var routes []config.RouteConfig
+	for i, rule := range route.Spec.Rules {
+		if len(rule.Filters) > 0 {
+			return nil, unsupported("rule %d: filters are not supported", i)
+		}
+
+		service, err := httpBackend(route.Namespace, rule.BackendRefs)
+		if err != nil {
+			return nil, err
+		}
+
+		matches := rule.Matches
+		if len(matches) == 0 {
+			matches = []gatewayv1.HTTPRouteMatch{{}}
+		}
+		for _, m := range matches {
+			if len(m.Headers) > 0 || len(m.QueryParams) > 0 || m.Method != nil {
+				return nil, unsupported("rule %d: header, query and method matches are not supported", i)
+			}
+
+			matchType, value := gatewayv1.PathMatchPathPrefix, "/"
+			if m.Path != nil {
+				if m.Path.Type != nil {
+					matchType = *m.Path.Type
+				}
+				if m.Path.Value != nil {
+					value = *m.Path.Value
+				}
+			}
+
+			paths, err := routerPaths(matchType == gatewayv1.PathMatchExact, matchType == gatewayv1.PathMatchPathPrefix, value)
+			if err != nil {
+				return nil, unsupported("rule %d: %s", i, err)
+			}
+			for _, p := range paths {
+				routes = append(routes, newRoute(p, hosts, service, kubeconfig, policy, scopes))
+			}
+		}
+	}
+	return routes, nil
+}
+
+// TranslateIngress converts a networking.k8s.io/v1 Ingress into gateway
+// routes. Prefix and ImplementationSpecific paths are treated as prefixes.
+func TranslateIngress(ing *networkingv1.Ingress, kubeconfig string) ([]config.RouteConfig, error) {
+	policy, scopes := authFromAnnotations(ing.Annotations)
+
+	var routes []config.RouteConfig
+	for _, rule := range ing.Spec.Rules {
+		if rule.HTTP == nil {
+			continue
+		}
+		var hosts []string
+		if rule.Host != "" {
+			hosts = []string{rule.Host}
+		}
+		for _, p := range rule.HTTP.Paths {
+			service, err := ingressBackend(ing.Namespace, p.Backend)
+			if err != nil {
+				return nil, err
+			}
+			exact := p.PathType != nil && *p.PathType == networkingv1.PathTypeExact
+			value := p.Path
+			if value == "" {
+				value = "/"
+			}
+			paths, err := routerPaths(exact, !exact, value)
+			if err != nil {
+				return nil, err
+			}
+			for _, path := range paths {
+				routes = append(routes, newRoute(path, hosts, service, kubeconfig, policy, scopes))
+			}
+		}
+	}
+
+	if ing.Spec.DefaultBackend != nil {
+		service, err := ingressBackend(ing.Namespace, *ing.Spec.DefaultBackend)
+		if err != nil {
+			return nil, err
+		}
+		routes = append(routes, newRoute("/*", nil, service, kubeconfig, policy, scopes))
+	}
+	return routes, nil
+}
+
+func newRoute(path string, hosts []string, service, kubeconfig, policy string, scopes []string) config.RouteConfig {
+	return config.RouteConfig{
+		Path:  path,
+		Hosts: hosts,
+		Discovery: &config.DiscoveryConfig{
+			Type:       discoveryProviders.ProviderK8s,
+			Service:    service,
+			Kubeconfig: kubeconfig,
+		},
+		Scopes:     scopes,
+		AuthPolicy: policy,
+	}
+}
+
+// routerPaths maps a path match onto router patterns. A prefix needs both the
+// exact path and a wildcard for everything below it.
+func routerPaths(exact, prefix bool, value string) ([]string, error) {
+	if !strings.HasPrefix(value, "/") {
+		return nil, fmt.Errorf("path %q must start with /", value)
+	}
+	if strings.ContainsAny(value, "{}*") {
+		return nil, fmt.Errorf("path %q contains unsupported characters", value)
+	}
+
+	switch {
+	case exact:
+		return []string{value}, nil
+	case prefix:
+		trimmed := strings.TrimSuffix(value, "/")
+		if trimmed == "" {
+			return []string{"/*"}, nil
+		}
+		return []string{trimmed, trimmed + "/*"}, nil
+	default:
+		return nil, fmt.Errorf("path %q: only Exact and PathPrefix matches are supported", value)
+	}
+}
+
+func httpBackend(namespace string, refs []gatewayv1.HTTPBackendRef) (string, error) {
+	if len(refs) == 0 {
+		return "", unresolved(gatewayv1.RouteReasonBackendNotFound, "rule has no backendRefs")
+	}
+	if len(refs) > 1 {
+		return "", unsupported("multiple backendRefs per rule are not supported")
+	}
+
+	ref := refs[0]
+	if len(ref.Filters) > 0 {
+		return "", unsupported("backendRef filters are not supported")
+	}
+	if ref.Group != nil && *ref.Group != "" && *ref.Group != "core" {
+		return "", unresolved(gatewayv1.RouteReasonInvalidKind, "backend group %s is not supported", *ref.Group)
+	}
+	if ref.Kind != nil && *ref.Kind != "Service" {
+		return "", unresolved(gatewayv1.RouteReasonInvalidKind, "backend kind %s is not supported", *ref.Kind)
+	}
+	if ref.Namespace != nil && string(*ref.Namespace) != namespace {
+		return "", unresolved(gatewayv1.RouteReasonRefNotPermitted, "cross-namespace backend %s/%s is not permitted", *ref.Namespace, ref.Name)
+	}
+	if ref.Port == nil {
+		return "", unsupported("backend %s requires a port", ref.Name)
+	}
+	return fmt.Sprintf("%s.%s:%d", ref.Name, namespace, *ref.Port), nil
+}
+
+func ingressBackend(namespace string, backend networkingv1.IngressBackend) (string, error) {
+	if backend.Service == nil {
+		return "", unresolved(gatewayv1.RouteReasonInvalidKind, "only service backends are supported")
+	}
+	port := backend.Service.Port.Name
+	if port == "" {
+		port = fmt.Sprintf("%d", backend.Service.Port.Number)
+	}
+	return fmt.Sprintf("%s.%s:%s", backend.Service.Name, namespace, port), nil
+}
+
+func authFromAnnotations(annotations map[string]string) (string, []string) {
+	var scopes []string
+	for _, s := range strings.Split(annotations[AnnotationScopes], ",") {
+		if s = strings.TrimSpace(s); s != "" {
+			scopes = append(scopes, s)
+		}
+	}
+
+	// Cluster resources are published authenticated unless they opt out.
+	policy := annotations[AnnotationAuthPolicy]
+	if policy == "" {
+		policy = "required"
+	}
+	return policy, scopes
+}
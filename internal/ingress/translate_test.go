@@ -0,0 +1,126 @@
+package ingress
+
+import (
+	"errors"
+	"testing"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	gatewayv1 "sigs.k8s.io/gateway-api/apis/v1"
+)
+
+func ptr[T any](v T) *T { return &v }
+
+func httpRoute(namespace, name string, rules ...gatewayv1.HTTPRouteRule) *gatewayv1.HTTPRoute {
+	return &gatewayv1.HTTPRoute{
+		ObjectMeta: metav1.ObjectMeta{Namespace: namespace, Name: name},
+		Spec: gatewayv1.HTTPRouteSpec{
+			CommonRouteSpec: gatewayv1.CommonRouteSpec{
+				ParentRefs: []gatewayv1.ParentReference{{Name: "gw", Namespace: ptr(gatewayv1.Namespace("infra"))}},
+			},
+			Hostnames: []gatewayv1.Hostname{"shop.example.com"},
+			Rules:     rules,
+		},
+	}
+}
+
+func rule(matchType gatewayv1.PathMatchType, path string, backend string, port int32) gatewayv1.HTTPRouteRule {
+	return gatewayv1.HTTPRouteRule{
+		Matches: []gatewayv1.HTTPRouteMatch{{Path: &gatewayv1.HTTPPathMatch{Type: &matchType, Value: &path}}},
+		BackendRefs: []gatewayv1.HTTPBackendRef{{BackendRef: gatewayv1.BackendRef{
+			BackendObjectReference: gatewayv1.BackendObjectReference{Name: gatewayv1.ObjectName(backend), Port: ptr(gatewayv1.PortNumber(port))},
+		}}},
+	}
+}
+
+func TestTranslateHTTPRoute(t *testing.T) {
+	hr := httpRoute("shop", "web",
+		rule(gatewayv1.PathMatchExact, "/healthz", "web", 8080),
+		rule(gatewayv1.PathMatchPathPrefix, "/api", "api", 80),
+	)
+	routes, err := TranslateHTTPRoute(hr, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(routes) == 0 {
+		t.Fatal("no routes")
+	}
+	for _, r := range routes {
+		if r.AuthPolicy != "required" {
+			t.Errorf("route %s: authPolicy = %q, want required by default", r.Path, r.AuthPolicy)
+		}
+		if len(r.Hosts) != 1 || r.Hosts[0] != "shop.example.com" {
+			t.Errorf("route %s: hosts = %v", r.Path, r.Hosts)
+		}
+		if r.Discovery == nil {
+			t.Fatalf("route %s has no discovery", r.Path)
+		}
+		if err := r.Validate(); err != nil {
+			t.Errorf("route %s: %v", r.Path, err)
+		}
+	}
+	if routes[0].Path != "/healthz" || routes[0].Discovery.Service != "web.shop:8080" {
+		t.Errorf("first route = %s -> %s", routes[0].Path, routes[0].Discovery.Service)
+	}
+	var prefixed bool
+	for _, r := range routes[1:] {
+		if r.Discovery.Service != "api.shop:80" {
+			t.Errorf("route %s -> %s, want api.shop:80", r.Path, r.Discovery.Service)
+		}
+		prefixed = prefixed || r.Path == "/api/*"
+	}
+	if !prefixed {
+		t.Errorf("prefix match did not produce /api/*: %v", routes)
+	}
+}
+
+func TestTranslateHTTPRouteAnnotations(t *testing.T) {
+	tests := []struct {
+		annotations map[string]string
+		policy      string
+		scopes      int
+	}{
+		{nil, "required", 0},
+		{map[string]string{AnnotationAuthPolicy: "none"}, "none", 0},
+		{map[string]string{AnnotationAuthPolicy: "optional"}, "optional", 0},
+		{map[string]string{AnnotationScopes: "orders.read, orders.write"}, "required", 2},
+	}
+	for _, tt := range tests {
+		hr := httpRoute("shop", "web", rule(gatewayv1.PathMatchExact, "/", "web", 80))
+		hr.Annotations = tt.annotations
+		routes, err := TranslateHTTPRoute(hr, "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if routes[0].AuthPolicy != tt.policy || len(routes[0].Scopes) != tt.scopes {
+			t.Errorf("%v: policy %q scopes %v", tt.annotations, routes[0].AuthPolicy, routes[0].Scopes)
+		}
+	}
+}
+
+func TestTranslateHTTPRouteRejects(t *testing.T) {
+	crossNamespace := rule(gatewayv1.PathMatchExact, "/", "web", 80)
+	crossNamespace.BackendRefs[0].Namespace = ptr(gatewayv1.Namespace("other"))
+
+	withFilter := rule(gatewayv1.PathMatchExact, "/", "web", 80)
+	withFilter.Filters = []gatewayv1.HTTPRouteFilter{{Type: gatewayv1.HTTPRouteFilterRequestHeaderModifier}}
+
+	tests := []struct {
+		name      string
+		rule      gatewayv1.HTTPRouteRule
+		condition gatewayv1.RouteConditionType
+		reason    gatewayv1.RouteConditionReason
+	}{
+		{"cross-namespace backend", crossNamespace, gatewayv1.RouteConditionResolvedRefs, gatewayv1.RouteReasonRefNotPermitted},
+		{"filters", withFilter, gatewayv1.RouteConditionAccepted, gatewayv1.RouteReasonUnsupportedValue},
+	}
+	for _, tt := range tests {
+		_, err := TranslateHTTPRoute(httpRoute("shop", "web", tt.rule), "")
+		var te *TranslateError
+		if !errors.As(err, &te) {
+			t.Fatalf("%s: err = %v, want a TranslateError", tt.name, err)
+		}
+		if te.Condition != string(tt.condition) || te.Reason != string(tt.reason) {
+			t.Errorf("%s: %s/%s, want %s/%s", tt.name, te.Condition, te.Reason, tt.condition, tt.reason)
+		}
+	}
+}
@@ -0,0 +1,67 @@
+package server
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/shrihariharanba/go-gateway/internal/clientip"
+	"github.com/shrihariharanba/go-gateway/internal/config"
+	"github.com/shrihariharanba/go-gateway/internal/egress"
+)
+
+const defaultEgressAddr = ":3128"
+
+// newEgressListener serves the forward proxy, or returns nil when egress
+// is disabled. It speaks HTTP/1.1 only, which CONNECT tunnels rely on.
+func (s *Server) newEgressListener() (*listener, error) {
+	ec := s.cfg.Egress
+	if !ec.Enabled {
+		return nil, nil
+	}
+
+	auth := egress.AuthConfig{}
+	if ec.Auth.SSO {
+		auth.SSO = s.ssoProvider
+	}
+	if len(ec.Auth.Users) > 0 {
+		auth.Users = make(map[string]string, len(ec.Auth.Users))
+		for _, u := range ec.Auth.Users {
+			auth.Users[u.Username] = u.Password
+		}
+	}
+
+	p, err := egress.New(egress.Config{
+		Allow:       egress.Rules{Hosts: ec.Allow.Hosts, CIDRs: ec.Allow.CIDRs, Ports: ec.Allow.Ports},
+		Deny:        egress.Rules{Hosts: ec.Deny.Hosts, CIDRs: ec.Deny.CIDRs, Ports: ec.Deny.Ports},
+		Auth:        auth,
+		DialTimeout: ec.DialTimeout,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	resolver, err := clientip.NewResolver(s.cfg.Server.TrustedProxies, s.cfg.Server.ClientIPHeader)
+	if err != nil {
+		return nil, err
+	}
+
+	addr := ec.Address
+	if addr == "" {
+		addr = defaultEgressAddr
+	}
+	srv := &http.Server{
+		Addr:              addr,
+		Handler:           resolver.Middleware(p),
+		ReadHeaderTimeout: 10 * time.Second,
+		Protocols:         new(http.Protocols),
+	}
+	srv.Protocols.SetHTTP1(true)
+
+	return &listener{
+		name:     "egress",
+		addr:     addr,
+		protocol: string(config.ProtocolHTTP1),
+		serve:    srv.ListenAndServe,
+		shutdown: srv.Shutdown,
+	}, nil
+}
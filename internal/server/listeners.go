@@ -0,0 +1,276 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/pires/go-proxyproto"
+	"github.com/quic-go/quic-go/http3"
+
+	"github.com/shrihariharanba/go-gateway/internal/config"
+)
+
+const (
+	defaultAdminAddr = "127.0.0.1:9901"
+	altSvcMaxAge     = 24 * time.Hour
+)
+
+// listener is a server bound to one address. serve blocks until the
+// listener fails or is shut down.
+type listener struct {
+	name     string
+	addr     string
+	protocol string
+	tls      bool
+	serve    func() error
+	shutdown func(context.Context) error
+}
+
+// listenerConfigs returns server.listeners, or a single listener built from
+// the legacy port/tlsEnabled/tls settings.
+func (s *Server) listenerConfigs() []config.ListenerConfig {
+	if len(s.cfg.Server.Listeners) > 0 {
+		return s.cfg.Server.Listeners
+	}
+
+	l := config.ListenerConfig{
+		Name:     "default",
+		Address:  fmt.Sprintf(":%d", s.cfg.Server.Port),
+		Protocol: config.ProtocolHTTP1,
+	}
+	if s.cfg.Server.TLSEnabled {
+		tc := s.cfg.Server.TLS
+		l.TLS = &tc
+		l.Protocol = config.ProtocolH2
+	}
+	return []config.ListenerConfig{l}
+}
+
+// newListeners builds the public listeners. HTTP/3 listeners are
+// advertised with Alt-Svc on the TLS listeners over TCP.
+func (s *Server) newListeners() ([]*listener, error) {
+	cfgs := s.listenerConfigs()
+	altSvc := altSvcHeader(cfgs)
+
+	var out []*listener
+	for i, lc := range cfgs {
+		name := lc.Name
+		if name == "" {
+			name = fmt.Sprintf("listener-%d", i)
+		}
+
+		var (
+			l   *listener
+			err error
+		)
+		if lc.Protocol == config.ProtocolH3 {
+			l, err = s.newH3Listener(lc, s.listenerHandler(lc, ""))
+		} else {
+			l, err = s.newTCPListener(lc, s.listenerHandler(lc, altSvc))
+		}
+		if err != nil {
+			return nil, fmt.Errorf("listener %s: %w", name, err)
+		}
+		l.name = name
+		out = append(out, l)
+	}
+	return out, nil
+}
+
+func (s *Server) newTCPListener(lc config.ListenerConfig, handler http.Handler) (*listener, error) {
+	srv := &http.Server{
+		Addr:              lc.Address,
+		Handler:           handler,
+		ReadHeaderTimeout: 10 * time.Second,
+		Protocols:         new(http.Protocols),
+	}
+	srv.Protocols.SetHTTP1(true)
+
+	protocol := lc.Protocol
+	if protocol == "" {
+		protocol = config.ProtocolHTTP1
+	}
+
+	switch protocol {
+	case config.ProtocolH2C:
+		srv.Protocols.SetUnencryptedHTTP2(true)
+	case config.ProtocolH2:
+		// An explicit ALPN list without h2 switches HTTP/2 off.
+		if len(lc.TLS.ALPN) == 0 || slices.Contains(lc.TLS.ALPN, "h2") {
+			srv.Protocols.SetHTTP2(true)
+		}
+	}
+
+	if lc.TLS == nil {
+		return &listener{
+			addr:     lc.Address,
+			protocol: string(protocol),
+			serve: func() error {
+				ln, err := s.listenTCP(lc)
+				if err != nil {
+					return err
+				}
+				return srv.Serve(ln)
+			},
+			shutdown: srv.Shutdown,
+		}, nil
+	}
+
+	alpn := []string{"http/1.1"}
+	if protocol == config.ProtocolH2 {
+		alpn = []string{"h2", "http/1.1"}
+	}
+	tlsCfg, err := s.listenerTLS(*lc.TLS, alpn)
+	if err != nil {
+		return nil, err
+	}
+	srv.TLSConfig = tlsCfg
+
+	return &listener{
+		addr:     lc.Address,
+		protocol: string(protocol),
+		tls:      true,
+		serve: func() error {
+			ln, err := s.listenTCP(lc)
+			if err != nil {
+				return err
+			}
+			return srv.ServeTLS(ln, "", "")
+		},
+		shutdown: srv.Shutdown,
+	}, nil
+}
+
+// listenTCP binds a listener's address, accepting PROXY protocol headers
+// when configured. The connection's RemoteAddr then reports the client the
+// load balancer saw rather than the load balancer itself.
+func (s *Server) listenTCP(lc config.ListenerConfig) (net.Listener, error) {
+	ln, err := net.Listen("tcp", lc.Address)
+	if err != nil || !lc.ProxyProtocol {
+		return ln, err
+	}
+
+	// Config validation requires trustedProxies here; headers from any
+	// other peer are refused.
+	policy, err := proxyproto.StrictWhiteListPolicy(s.cfg.Server.TrustedProxies)
+	if err != nil {
+		ln.Close()
+		return nil, err
+	}
+	return &proxyproto.Listener{Listener: ln, Policy: policy}, nil
+}
+
+func (s *Server) newH3Listener(lc config.ListenerConfig, handler http.Handler) (*listener, error) {
+	tlsCfg, err := s.listenerTLS(*lc.TLS, []string{http3.NextProtoH3})
+	if err != nil {
+		return nil, err
+	}
+	srv := &http3.Server{
+		Addr:      lc.Address,
+		Handler:   handler,
+		TLSConfig: http3.ConfigureTLSConfig(tlsCfg),
+	}
+
+	return &listener{
+		addr:     lc.Address,
+		protocol: string(config.ProtocolH3),
+		tls:      true,
+		serve: func() error {
+			conn, err := net.ListenPacket("udp", lc.Address)
+			if err != nil {
+				return err
+			}
+			defer conn.Close()
+			return srv.Serve(conn)
+		},
+		shutdown: srv.Shutdown,
+	}, nil
+}
+
+// newAdminListener serves metrics and admin endpoints on their own
+// address, or returns nil when the admin listener is disabled.
+func (s *Server) newAdminListener() *listener {
+	if s.admin == nil {
+		return nil
+	}
+	addr := s.cfg.Server.Admin.Address
+	if addr == "" {
+		addr = defaultAdminAddr
+	}
+	srv := &http.Server{
+		Addr:              addr,
+		Handler:           s.admin,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+	return &listener{
+		name:     "admin",
+		addr:     addr,
+		protocol: string(config.ProtocolHTTP1),
+		serve:    srv.ListenAndServe,
+		shutdown: srv.Shutdown,
+	}
+}
+
+// plainListener wraps the redirect/ACME challenge server.
+func plainListener(srv *http.Server) *listener {
+	return &listener{
+		name:     "http-redirect",
+		addr:     srv.Addr,
+		protocol: string(config.ProtocolHTTP1),
+		serve:    srv.ListenAndServe,
+		shutdown: srv.Shutdown,
+	}
+}
+
+// listenerHandler restricts the router to the listener's virtual hosts and
+// adds the Alt-Svc header on TLS listeners. /health is answered for any
+// host so that load balancers can probe by IP.
+func (s *Server) listenerHandler(lc config.ListenerConfig, altSvc string) http.Handler {
+	hosts := lc.Hosts
+	advertise := altSvc != "" && lc.TLS != nil
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if len(hosts) > 0 && r.URL.Path != "/health" && !matchHost(hosts, r.Host) {
+			http.Error(w, "Misdirected Request", http.StatusMisdirectedRequest)
+			return
+		}
+		if advertise {
+			w.Header().Set("Alt-Svc", altSvc)
+		}
+		s.router.ServeHTTP(w, r)
+	})
+}
+
+// altSvcHeader advertises every HTTP/3 listener, e.g. h3=":443"; ma=86400.
+func altSvcHeader(cfgs []config.ListenerConfig) string {
+	var entries []string
+	for _, lc := range cfgs {
+		if lc.Protocol != config.ProtocolH3 {
+			continue
+		}
+		_, port, err := net.SplitHostPort(lc.Address)
+		if err != nil {
+			continue
+		}
+		entries = append(entries, fmt.Sprintf(`h3=":%s"; ma=%d`, port, int(altSvcMaxAge.Seconds())))
+	}
+	return strings.Join(entries, ", ")
+}
+
+// httpsPort returns the port of the first TLS listener over TCP.
+func (s *Server) httpsPort() string {
+	for _, lc := range s.listenerConfigs() {
+		if lc.TLS == nil || lc.Protocol == config.ProtocolH3 {
+			continue
+		}
+		if _, port, err := net.SplitHostPort(lc.Address); err == nil {
+			return port
+		}
+	}
+	return "443"
+}
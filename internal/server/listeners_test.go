@@ -0,0 +1,57 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/shrihariharanba/go-gateway/internal/config"
+)
+
+func TestAltSvcHeader(t *testing.T) {
+	tlsCfg := &config.ServerTLSConfig{}
+	for _, tc := range []struct {
+		name string
+		cfgs []config.ListenerConfig
+		want string
+	}{
+		{"no h3", []config.ListenerConfig{{Address: ":443", Protocol: config.ProtocolH2, TLS: tlsCfg}}, ""},
+		{"one h3", []config.ListenerConfig{
+			{Address: ":443", Protocol: config.ProtocolH2, TLS: tlsCfg},
+			{Address: ":443", Protocol: config.ProtocolH3, TLS: tlsCfg},
+		}, `h3=":443"; ma=86400`},
+		{"several h3", []config.ListenerConfig{
+			{Address: ":443", Protocol: config.ProtocolH3, TLS: tlsCfg},
+			{Address: "10.0.0.1:8443", Protocol: config.ProtocolH3, TLS: tlsCfg},
+		}, `h3=":443"; ma=86400, h3=":8443"; ma=86400`},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := altSvcHeader(tc.cfgs); got != tc.want {
+				t.Errorf("got %q, want %q", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestListenerHandlerAdvertisesOnTLSOnly(t *testing.T) {
+	s := newTestServer(config.ServerConfig{})
+	s.router.Get("/", func(http.ResponseWriter, *http.Request) {})
+	const altSvc = `h3=":443"; ma=86400`
+
+	for _, tc := range []struct {
+		name string
+		lc   config.ListenerConfig
+		want string
+	}{
+		{"TLS", config.ListenerConfig{Address: ":443", TLS: &config.ServerTLSConfig{}}, altSvc},
+		{"plain", config.ListenerConfig{Address: ":80"}, ""},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			s.listenerHandler(tc.lc, altSvc).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+			if got := rec.Header().Get("Alt-Svc"); got != tc.want {
+				t.Errorf("Alt-Svc = %q, want %q", got, tc.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,54 @@
+package proxy
+
+import (
+	"net/http/httputil"
+	"strings"
+
+	"github.com/shrihariharanba/go-gateway/internal/clientip"
+)
+
+// setForwarded writes X-Forwarded-* and Forwarded headers for the upstream.
+// Chains received from a trusted proxy are extended; from anyone else they
+// are discarded and started afresh, so clients cannot spoof their address.
+func setForwarded(pr *httputil.ProxyRequest) {
+	in, out := pr.In, pr.Out
+	info := clientip.FromRequest(in)
+
+	proto := "http"
+	if in.TLS != nil {
+		proto = "https"
+	}
+
+	xff := ""
+	if info.Peer.IsValid() {
+		xff = info.Peer.String()
+	}
+	xfHost, xfProto := in.Host, proto
+	var forwarded []string
+
+	if info.Trusted {
+		if prior := in.Header.Values("X-Forwarded-For"); len(prior) > 0 {
+			xff = strings.Join(append(prior, xff), ", ")
+		}
+		if v := in.Header.Get("X-Forwarded-Host"); v != "" {
+			xfHost = v
+		}
+		if v := in.Header.Get("X-Forwarded-Proto"); v != "" {
+			xfProto = v
+		}
+		forwarded = in.Header.Values("Forwarded")
+	}
+
+	out.Header.Set("X-Forwarded-For", xff)
+	out.Header.Set("X-Forwarded-Host", xfHost)
+	out.Header.Set("X-Forwarded-Proto", xfProto)
+
+	elem := "proto=" + proto
+	if info.Peer.IsValid() {
+		elem = "for=" + clientip.Node(info.Peer) + ";" + elem
+	}
+	if in.Host != "" {
+		elem += `;host="` + in.Host + `"`
+	}
+	out.Header.Set("Forwarded", strings.Join(append(forwarded, elem), ", "))
+}
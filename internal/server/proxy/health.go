@@ -0,0 +1,211 @@
+package proxy
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	defaultHealthInterval  = 10 * time.Second
+	defaultHealthTimeout   = 2 * time.Second
+	defaultUnhealthyThresh = 3
+	defaultHealthyThresh   = 2
+)
+
+// HealthCheckConfig configures active health checks. A target is taken out
+// of rotation after UnhealthyThreshold failed probes in a row and put back
+// after HealthyThreshold successful ones.
+type HealthCheckConfig struct {
+	Interval           time.Duration
+	Timeout            time.Duration
+	Path               string // HTTP GET expecting 2xx/3xx; empty probes with a connect
+	UnhealthyThreshold int
+	HealthyThreshold   int
+}
+
+type targetHealth struct {
+	healthy   bool
+	successes int
+	failures  int
+}
+
+// HealthChecked is an Upstream that skips targets failing their health
+// checks. If every target is unhealthy, all of them are returned so that
+// traffic is not dropped on a probe misconfiguration.
+type HealthChecked struct {
+	upstream Upstream
+	cfg      HealthCheckConfig
+	client   *http.Client
+	dial     func(ctx context.Context, target *url.URL) (net.Conn, error)
+
+	mu    sync.RWMutex
+	state map[string]*targetHealth
+}
+
+// NewHealthChecked probes the targets of upstream until ctx is cancelled.
+// transport is used for HTTP probes; dial for connect probes, with nil
+// meaning a dial to the target host or Unix socket.
+func NewHealthChecked(ctx context.Context, upstream Upstream, cfg HealthCheckConfig, transport http.RoundTripper, dial func(ctx context.Context, target *url.URL) (net.Conn, error)) *HealthChecked {
+	if cfg.Interval <= 0 {
+		cfg.Interval = defaultHealthInterval
+	}
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = defaultHealthTimeout
+	}
+	if cfg.UnhealthyThreshold <= 0 {
+		cfg.UnhealthyThreshold = defaultUnhealthyThresh
+	}
+	if cfg.HealthyThreshold <= 0 {
+		cfg.HealthyThreshold = defaultHealthyThresh
+	}
+	if dial == nil {
+		dial = func(ctx context.Context, target *url.URL) (net.Conn, error) {
+			var d net.Dialer
+			if IsUnix(target) {
+				return d.DialContext(ctx, "unix", target.Path)
+			}
+			return d.DialContext(ctx, "tcp", target.Host)
+		}
+	}
+
+	h := &HealthChecked{
+		upstream: upstream,
+		cfg:      cfg,
+		client:   &http.Client{Transport: transport, Timeout: cfg.Timeout},
+		dial:     dial,
+		state:    make(map[string]*targetHealth),
+	}
+	go h.run(ctx)
+	return h
+}
+
+func (h *HealthChecked) Targets() []*url.URL {
+	all := h.upstream.Targets()
+
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	healthy := make([]*url.URL, 0, len(all))
+	for _, t := range all {
+		if st, ok := h.state[t.String()]; !ok || st.healthy {
+			healthy = append(healthy, t)
+		}
+	}
+	if len(healthy) == 0 {
+		return all
+	}
+	return healthy
+}
+
+func (h *HealthChecked) run(ctx context.Context) {
+	ticker := time.NewTicker(h.cfg.Interval)
+	defer ticker.Stop()
+
+	for {
+		h.probeAll(ctx)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (h *HealthChecked) probeAll(ctx context.Context) {
+	// Weighted upstreams list a target once per share; probe it once.
+	var targets []*url.URL
+	listed := make(map[*url.URL]bool)
+	for _, t := range h.upstream.Targets() {
+		if !listed[t] {
+			listed[t] = true
+			targets = append(targets, t)
+		}
+	}
+	results := make([]bool, len(targets))
+
+	var wg sync.WaitGroup
+	for i, t := range targets {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			results[i] = h.probe(ctx, t)
+		}()
+	}
+	wg.Wait()
+	if ctx.Err() != nil {
+		return
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	seen := make(map[string]bool, len(targets))
+	for i, t := range targets {
+		key := t.String()
+		seen[key] = true
+		st, ok := h.state[key]
+		if !ok {
+			st = &targetHealth{healthy: true}
+			h.state[key] = st
+		}
+		h.record(key, st, results[i])
+	}
+	// Forget targets that left the upstream.
+	for key := range h.state {
+		if !seen[key] {
+			delete(h.state, key)
+		}
+	}
+}
+
+func (h *HealthChecked) record(key string, st *targetHealth, ok bool) {
+	if ok {
+		st.successes++
+		st.failures = 0
+		if !st.healthy && st.successes >= h.cfg.HealthyThreshold {
+			st.healthy = true
+			log.Info().Str("target", key).Msg("Upstream target healthy")
+		}
+		return
+	}
+	st.failures++
+	st.successes = 0
+	if st.healthy && st.failures >= h.cfg.UnhealthyThreshold {
+		st.healthy = false
+		log.Warn().Str("target", key).Msg("Upstream target unhealthy")
+	}
+}
+
+func (h *HealthChecked) probe(ctx context.Context, target *url.URL) bool {
+	ctx, cancel := context.WithTimeout(ctx, h.cfg.Timeout)
+	defer cancel()
+
+	if h.cfg.Path == "" {
+		conn, err := h.dial(ctx, target)
+		if err != nil {
+			return false
+		}
+		conn.Close()
+		return true
+	}
+
+	u := *httpTarget(target)
+	u.Path = h.cfg.Path
+	u.RawQuery = ""
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	if err != nil {
+		return false
+	}
+	resp, err := h.client.Do(req)
+	if err != nil {
+		return false
+	}
+	resp.Body.Close()
+	return resp.StatusCode >= 200 && resp.StatusCode < 400
+}
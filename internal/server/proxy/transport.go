@@ -0,0 +1,49 @@
+package proxy
+
+import (
+	"crypto/tls"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"golang.org/x/net/http/httpproxy"
+)
+
+// TransportConfig describes how the gateway connects to an upstream.
+type TransportConfig struct {
+	TLS *tls.Config
+	// Proxy is an outbound HTTP (CONNECT) or SOCKS5 proxy, credentials in
+	// its userinfo. Without one, the environment's HTTP_PROXY settings apply.
+	Proxy *url.URL
+	// NoProxy lists NO_PROXY-style exceptions: hosts, .domains, CIDRs and
+	// host:port pairs that are dialled directly.
+	NoProxy []string
+	// H2C speaks HTTP/2 with prior knowledge to plain HTTP upstreams.
+	H2C bool
+}
+
+// NewTransport returns a transport with http.DefaultTransport's pooling and
+// timeout settings plus the given upstream options. TLS and timeouts apply
+// the same way through a proxy as on direct connections. unix:// targets
+// are dialled through their socket.
+func NewTransport(cfg TransportConfig) http.RoundTripper {
+	t := http.DefaultTransport.(*http.Transport).Clone()
+	t.DialContext = dialContext(t.DialContext)
+	if cfg.H2C {
+		t.Protocols = new(http.Protocols)
+		t.Protocols.SetUnencryptedHTTP2(true)
+	}
+	if cfg.TLS != nil {
+		t.TLSClientConfig = cfg.TLS
+	}
+	if cfg.Proxy != nil {
+		proxyFunc := (&httpproxy.Config{
+			HTTPProxy:  cfg.Proxy.String(),
+			HTTPSProxy: cfg.Proxy.String(),
+			NoProxy:    strings.Join(cfg.NoProxy, ","),
+		}).ProxyFunc()
+		t.Proxy = func(r *http.Request) (*url.URL, error) { return proxyFunc(r.URL) }
+	}
+	t.Proxy = bypassUnix(t.Proxy)
+	return t
+}
@@ -0,0 +1,62 @@
+package proxy
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+)
+
+// unixHostSuffix marks placeholder hosts that stand for Unix sockets.
+const unixHostSuffix = ".unix.invalid"
+
+// unixSockets maps placeholder hosts to socket paths.
+var unixSockets sync.Map
+
+// IsUnix reports whether target is a unix:///path/to.sock upstream.
+func IsUnix(target *url.URL) bool { return target.Scheme == "unix" }
+
+// httpTarget returns the URL requests to target are sent to. A Unix socket
+// becomes plain HTTP to a placeholder host derived from the socket path, so
+// that the transport keeps a separate connection pool per socket.
+func httpTarget(target *url.URL) *url.URL {
+	if !IsUnix(target) {
+		return target
+	}
+	sum := sha256.Sum256([]byte(target.Path))
+	host := hex.EncodeToString(sum[:8]) + unixHostSuffix
+	unixSockets.Store(host, target.Path)
+	return &url.URL{Scheme: "http", Host: host}
+}
+
+// bypassUnix keeps placeholder hosts away from any outbound proxy; their
+// connections are dialled locally through the socket.
+func bypassUnix(proxy func(*http.Request) (*url.URL, error)) func(*http.Request) (*url.URL, error) {
+	if proxy == nil {
+		return nil
+	}
+	return func(r *http.Request) (*url.URL, error) {
+		if strings.HasSuffix(r.URL.Hostname(), unixHostSuffix) {
+			return nil, nil
+		}
+		return proxy(r)
+	}
+}
+
+// dialContext dials TCP addresses normally and placeholder hosts through
+// their Unix socket.
+func dialContext(dial func(ctx context.Context, network, addr string) (net.Conn, error)) func(ctx context.Context, network, addr string) (net.Conn, error) {
+	return func(ctx context.Context, network, addr string) (net.Conn, error) {
+		host, _, err := net.SplitHostPort(addr)
+		if err == nil && strings.HasSuffix(host, unixHostSuffix) {
+			if path, ok := unixSockets.Load(host); ok {
+				return dial(ctx, "unix", path.(string))
+			}
+		}
+		return dial(ctx, network, addr)
+	}
+}
@@ -0,0 +1,38 @@
+package proxy
+
+import (
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"path/filepath"
+	"testing"
+)
+
+func TestUnixTargetBypassesProxy(t *testing.T) {
+	sock := filepath.Join(t.TempDir(), "app.sock")
+	ln, err := net.Listen("unix", sock)
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, "from socket")
+	}))
+	srv.Listener = ln
+	srv.Start()
+	defer srv.Close()
+
+	// Nothing listens on the proxy address, so a proxied request fails.
+	transport := NewTransport(TransportConfig{Proxy: &url.URL{Scheme: "http", Host: "127.0.0.1:1"}})
+	target := httpTarget(&url.URL{Scheme: "unix", Path: sock})
+	resp, err := (&http.Client{Transport: transport}).Get(target.String() + "/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(resp.Body)
+	if string(body) != "from socket" {
+		t.Errorf("body = %q", body)
+	}
+}
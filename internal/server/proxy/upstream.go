@@ -0,0 +1,85 @@
+package proxy
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"sync/atomic"
+)
+
+// Upstream supplies the set of targets a route proxies to. Implementations
+// may change the returned set at any time.
+type Upstream interface {
+	Targets() []*url.URL
+}
+
+// StaticUpstream is a fixed target set taken from configuration.
+type StaticUpstream []*url.URL
+
+func NewStaticUpstream(raw string) (StaticUpstream, error) {
+	target, err := url.Parse(raw)
+	if err != nil {
+		return nil, fmt.Errorf("invalid upstream %q: %w", raw, err)
+	}
+	if IsUnix(target) && target.Path == "" {
+		return nil, fmt.Errorf("invalid upstream %q: unix upstreams need a socket path", raw)
+	}
+	return StaticUpstream{target}, nil
+}
+
+func (s StaticUpstream) Targets() []*url.URL { return s }
+
+// RoundRobin picks the current targets of an Upstream in turn.
+type RoundRobin struct {
+	upstream Upstream
+	next     atomic.Uint64
+}
+
+func NewRoundRobin(upstream Upstream) *RoundRobin {
+	return &RoundRobin{upstream: upstream}
+}
+
+// Next returns the next target, or nil when the upstream has none.
+func (rr *RoundRobin) Next() *url.URL {
+	targets := rr.upstream.Targets()
+	if len(targets) == 0 {
+		return nil
+	}
+	return targets[(rr.next.Add(1)-1)%uint64(len(targets))]
+}
+
+type targetKey struct{}
+
+// BalancedProxy spreads requests round-robin over the current targets of an
+// Upstream. A nil transport uses http.DefaultTransport.
+type BalancedProxy struct {
+	balancer *RoundRobin
+	proxy    *httputil.ReverseProxy
+}
+
+func NewBalancedProxy(upstream Upstream, transport http.RoundTripper) *BalancedProxy {
+	b := &BalancedProxy{balancer: NewRoundRobin(upstream)}
+	b.proxy = &httputil.ReverseProxy{
+		Transport: transport,
+		Rewrite: func(pr *httputil.ProxyRequest) {
+			target := pr.In.Context().Value(targetKey{}).(*url.URL)
+			pr.SetURL(httpTarget(target))
+			pr.Out.Host = pr.In.Host
+			setForwarded(pr)
+		},
+	}
+	return b
+}
+
+func (b *BalancedProxy) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	target := b.balancer.Next()
+	if target == nil {
+		http.Error(w, "Service Unavailable: no upstream targets", http.StatusServiceUnavailable)
+		return
+	}
+
+	ctx := context.WithValue(r.Context(), targetKey{}, target)
+	b.proxy.ServeHTTP(w, r.WithContext(ctx))
+}
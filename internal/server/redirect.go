@@ -0,0 +1,107 @@
+package server
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	defaultRedirectAddr = ":80"
+	defaultHSTSMaxAge   = 365 * 24 * 60 * 60
+)
+
+// plainHTTPServer returns the plain HTTP listener used for HTTPS redirects
+// and ACME HTTP-01 challenges, or nil when neither is enabled. /health stays
+// reachable so that load balancers can probe without TLS.
+func (s *Server) plainHTTPServer() *http.Server {
+	redirect := s.cfg.Server.HTTPRedirect
+	challenges := s.acme != nil && s.acme.HTTPChallenge()
+	if !redirect.Enabled && !challenges {
+		return nil
+	}
+
+	var handler http.Handler = http.HandlerFunc(s.redirectToHTTPS)
+	if challenges {
+		handler = s.acme.HTTPHandler(handler)
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /health", healthHandler)
+	mux.Handle("/", handler)
+
+	addr := redirect.Addr
+	if !redirect.Enabled || addr == "" {
+		ac, _ := s.acmeConfig()
+		addr = ac.HTTPAddr
+	}
+	if addr == "" {
+		addr = defaultRedirectAddr
+	}
+
+	return &http.Server{
+		Addr:              addr,
+		Handler:           mux,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+}
+
+// redirectToHTTPS permanently redirects to the same URL on the TLS port.
+// Methods other than GET and HEAD get 308 so clients keep method and body.
+func (s *Server) redirectToHTTPS(w http.ResponseWriter, r *http.Request) {
+	host := r.Host
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	host = strings.Trim(host, "[]")
+
+	port := s.httpsPort()
+	if p := s.cfg.Server.HTTPRedirect.HTTPSPort; p != 0 {
+		port = strconv.Itoa(p)
+	}
+	if port != "443" {
+		host = net.JoinHostPort(host, port)
+	} else if strings.Contains(host, ":") {
+		host = "[" + host + "]"
+	}
+
+	target := "https://" + host + r.URL.RequestURI()
+
+	code := http.StatusMovedPermanently
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		code = http.StatusPermanentRedirect
+	}
+	http.Redirect(w, r, target, code)
+}
+
+// hstsMiddleware adds Strict-Transport-Security to responses served over TLS.
+// Browsers ignore the header on plain HTTP, so it is never sent there.
+func (s *Server) hstsMiddleware(next http.Handler) http.Handler {
+	h := s.cfg.Server.HSTS
+	maxAge := defaultHSTSMaxAge
+	if h.MaxAge != nil {
+		maxAge = *h.MaxAge
+	}
+
+	value := fmt.Sprintf("max-age=%d", maxAge)
+	if h.IncludeSubDomains {
+		value += "; includeSubDomains"
+	}
+	if h.Preload {
+		value += "; preload"
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.TLS != nil {
+			w.Header().Set("Strict-Transport-Security", value)
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func healthHandler(w http.ResponseWriter, r *http.Request) {
+	w.Write([]byte("OK"))
+}
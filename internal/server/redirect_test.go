@@ -0,0 +1,94 @@
+package server
+
+import (
+	"crypto/tls"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+
+	"github.com/shrihariharanba/go-gateway/internal/config"
+)
+
+func newTestServer(sc config.ServerConfig) *Server {
+	return &Server{cfg: &config.Config{Server: sc}, router: chi.NewRouter()}
+}
+
+func TestRedirectToHTTPS(t *testing.T) {
+	tlsListener := func(addr string) config.ServerConfig {
+		return config.ServerConfig{Listeners: []config.ListenerConfig{
+			{Address: ":8080"},
+			{Address: addr, TLS: &config.ServerTLSConfig{}},
+		}}
+	}
+
+	for _, tc := range []struct {
+		name     string
+		sc       config.ServerConfig
+		method   string
+		host     string
+		target   string
+		wantCode int
+		wantLoc  string
+	}{
+		{"GET", tlsListener(":443"), http.MethodGet, "example.com", "/a?b=c", http.StatusMovedPermanently, "https://example.com/a?b=c"},
+		{"HEAD", tlsListener(":443"), http.MethodHead, "example.com", "/", http.StatusMovedPermanently, "https://example.com/"},
+		{"POST keeps the method", tlsListener(":443"), http.MethodPost, "example.com", "/form", http.StatusPermanentRedirect, "https://example.com/form"},
+		{"request port is dropped", tlsListener(":443"), http.MethodGet, "example.com:80", "/", http.StatusMovedPermanently, "https://example.com/"},
+		{"non-default TLS port", tlsListener(":8443"), http.MethodGet, "example.com:8080", "/", http.StatusMovedPermanently, "https://example.com:8443/"},
+		{"IPv6", tlsListener(":443"), http.MethodGet, "[::1]:80", "/", http.StatusMovedPermanently, "https://[::1]/"},
+		{"IPv6 with port", tlsListener(":8443"), http.MethodGet, "[::1]:80", "/", http.StatusMovedPermanently, "https://[::1]:8443/"},
+		{"httpsPort override", config.ServerConfig{
+			Listeners:    tlsListener(":8443").Listeners,
+			HTTPRedirect: config.HTTPRedirectConfig{HTTPSPort: 443},
+		}, http.MethodGet, "example.com", "/", http.StatusMovedPermanently, "https://example.com/"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			s := newTestServer(tc.sc)
+			req := httptest.NewRequest(tc.method, "http://"+tc.host+tc.target, nil)
+			rec := httptest.NewRecorder()
+			s.redirectToHTTPS(rec, req)
+
+			if rec.Code != tc.wantCode {
+				t.Errorf("code = %d, want %d", rec.Code, tc.wantCode)
+			}
+			if loc := rec.Header().Get("Location"); loc != tc.wantLoc {
+				t.Errorf("Location = %q, want %q", loc, tc.wantLoc)
+			}
+		})
+	}
+}
+
+func TestHSTSOnlyOverTLS(t *testing.T) {
+	zero := 0
+	for _, tc := range []struct {
+		name string
+		hsts config.HSTSConfig
+		want string
+	}{
+		{"default", config.HSTSConfig{Enabled: true}, "max-age=31536000"},
+		{"forget", config.HSTSConfig{Enabled: true, MaxAge: &zero}, "max-age=0"},
+		{"preload", config.HSTSConfig{Enabled: true, IncludeSubDomains: true, Preload: true}, "max-age=31536000; includeSubDomains; preload"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			s := newTestServer(config.ServerConfig{HSTS: tc.hsts})
+			h := s.hstsMiddleware(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {}))
+
+			req := httptest.NewRequest(http.MethodGet, "https://example.com/", nil)
+			req.TLS = &tls.ConnectionState{}
+			rec := httptest.NewRecorder()
+			h.ServeHTTP(rec, req)
+			if got := rec.Header().Get("Strict-Transport-Security"); got != tc.want {
+				t.Errorf("over TLS: got %q, want %q", got, tc.want)
+			}
+
+			req = httptest.NewRequest(http.MethodGet, "http://example.com/", nil)
+			rec = httptest.NewRecorder()
+			h.ServeHTTP(rec, req)
+			if got := rec.Header().Get("Strict-Transport-Security"); got != "" {
+				t.Errorf("over plain HTTP: got %q, want none", got)
+			}
+		})
+	}
+}
@@ -0,0 +1,305 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"slices"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/rs/zerolog/log"
+
+	"github.com/shrihariharanba/go-gateway/internal/authz"
+	"github.com/shrihariharanba/go-gateway/internal/clientip"
+	"github.com/shrihariharanba/go-gateway/internal/config"
+	"github.com/shrihariharanba/go-gateway/internal/discovery"
+	discoveryProviders "github.com/shrihariharanba/go-gateway/internal/discovery/providers"
+	"github.com/shrihariharanba/go-gateway/internal/extauthz"
+	"github.com/shrihariharanba/go-gateway/internal/hostmatch"
+	"github.com/shrihariharanba/go-gateway/internal/server/proxy"
+	"github.com/shrihariharanba/go-gateway/internal/sso"
+	"github.com/shrihariharanba/go-gateway/internal/tlsutil"
+)
+
+// routeTable is an immutable set of application routes. Replacing it cancels
+// the background workers (discovery watches) of the previous table.
+type routeTable struct {
+	handler http.Handler
+	cancel  context.CancelFunc
+}
+
+// hostRoute is a route handler restricted to a set of hostnames.
+type hostRoute struct {
+	hosts   []string
+	handler http.Handler
+}
+
+// ----------------------------------------------
+// ROUTES
+// ----------------------------------------------
+
+// SetRoutes validates and atomically replaces the application routes.
+// In-flight requests finish on the table they started with.
+func (s *Server) SetRoutes(routes []config.RouteConfig) error {
+	ctx, cancel := context.WithCancel(s.ctx)
+
+	mux := chi.NewRouter()
+	byPath := make(map[string][]hostRoute)
+	var paths []string
+
+	for _, rt := range routes {
+		route := rt
+		if err := route.Validate(); err != nil {
+			cancel()
+			return err
+		}
+
+		upstream, err := s.newUpstream(ctx, route)
+		if err != nil {
+			cancel()
+			return fmt.Errorf("route '%s': %w", route.Path, err)
+		}
+		transport, err := s.newTransport(ctx, route)
+		if err != nil {
+			cancel()
+			return fmt.Errorf("route '%s': %w", route.Path, err)
+		}
+		if route.HealthCheck != nil {
+			upstream = proxy.NewHealthChecked(ctx, upstream, healthCheckConfig(route.HealthCheck), transport, nil)
+		}
+		backend := proxy.NewBalancedProxy(upstream, transport)
+
+		var handler http.Handler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			s.handleReverseProxy(route, backend, w, r)
+		})
+
+		// External authorization, asked last so that local checks avoid a callout
+		if route.ExtAuthz != nil {
+			client, err := newExtAuthz(ctx, route.ExtAuthz)
+			if err != nil {
+				cancel()
+				return fmt.Errorf("route '%s': %w", route.Path, err)
+			}
+			handler = client.Middleware(route.Path, s.observeAuthz)(handler)
+		}
+
+		// Authorization policies, evaluated once the user is known
+		if route.Authz != nil {
+			policies, err := newPolicies(ctx, route.Authz)
+			if err != nil {
+				cancel()
+				return fmt.Errorf("route '%s': %w", route.Path, err)
+			}
+			handler = authz.Middleware(route.Path, policies, s.observeAuthz)(handler)
+		}
+
+		// SSO per-route policy
+		if s.ssoProvider != nil && route.AuthPolicy != "none" {
+			authRequired := route.AuthPolicy == "required"
+			scopes := sso.NewScopeRequirement(route.Scopes, route.MethodScopes, route.ScopeMode)
+			handler = sso.AuthMiddleware(s.ssoProvider, s.login, authRequired, scopes)(handler)
+		}
+
+		if _, ok := byPath[route.Path]; !ok {
+			paths = append(paths, route.Path)
+		}
+		byPath[route.Path] = append(byPath[route.Path], hostRoute{hosts: route.Hosts, handler: handler})
+	}
+
+	// Routes sharing a path are told apart by Host; the first match wins.
+	for _, path := range paths {
+		candidates := byPath[path]
+		mux.Handle(path, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			for _, c := range candidates {
+				if matchHost(c.hosts, r.Host) {
+					c.handler.ServeHTTP(w, r)
+					return
+				}
+			}
+			http.NotFound(w, r)
+		}))
+	}
+
+	old := s.routes.Swap(&routeTable{handler: mux, cancel: cancel})
+	if old != nil {
+		old.cancel()
+	}
+	return nil
+}
+
+// newPolicies compiles a route's policies. Rego bundles are watched for
+// changes until ctx is cancelled.
+func newPolicies(ctx context.Context, cfg *config.AuthzConfig) ([]authz.Policy, error) {
+	var policies []authz.Policy
+	for _, pc := range cfg.CEL {
+		p, err := authz.CompileCEL(pc.Name, pc.Expression, pc.Message)
+		if err != nil {
+			return nil, err
+		}
+		policies = append(policies, p)
+	}
+	for _, pc := range cfg.Rego {
+		p, err := authz.LoadRego(ctx, authz.RegoConfig{
+			Name:   pc.Name,
+			Bundle: pc.Bundle,
+			Query:  pc.Query,
+			Trace:  pc.Trace,
+		})
+		if err != nil {
+			return nil, err
+		}
+		go func() {
+			if err := p.Watch(ctx); err != nil {
+				log.Error().Err(err).Str("bundle", pc.Bundle).Msg("Rego bundle watch stopped")
+			}
+		}()
+		policies = append(policies, p)
+	}
+	return policies, nil
+}
+
+// newExtAuthz connects to a route's authorization service. The connection
+// is closed when ctx is cancelled.
+func newExtAuthz(ctx context.Context, cfg *config.ExtAuthzConfig) (*extauthz.Client, error) {
+	client, err := extauthz.New(extauthz.Config{
+		URL:          cfg.URL,
+		Timeout:      cfg.Timeout,
+		Headers:      slices.Clone(cfg.Headers),
+		IncludeBody:  cfg.IncludeBody,
+		MaxBodyBytes: cfg.MaxBodyBytes,
+		FailOpen:     cfg.FailOpen,
+		CacheTTL:     cfg.CacheTTL,
+	})
+	if err != nil {
+		return nil, err
+	}
+	go func() {
+		<-ctx.Done()
+		client.Close()
+	}()
+	return client, nil
+}
+
+func (s *Server) observeAuthz(route, policy, decision string) {
+	if s.telemetry != nil {
+		s.telemetry.ObserveAuthz(route, policy, decision)
+	}
+}
+
+func (s *Server) serveRoutes(w http.ResponseWriter, r *http.Request) {
+	s.routes.Load().handler.ServeHTTP(w, r)
+}
+
+// matchHost reports whether host matches one of the patterns. An empty list
+// matches every host; "*.example.com" matches any subdomain of example.com.
+func matchHost(patterns []string, host string) bool {
+	if len(patterns) == 0 {
+		return true
+	}
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	return hostmatch.Match(patterns, host)
+}
+
+// newUpstream builds the target set for a route: a static URL, or a
+// discovered service that keeps itself up to date until ctx is cancelled.
+func (s *Server) newUpstream(ctx context.Context, route config.RouteConfig) (proxy.Upstream, error) {
+	if route.Discovery == nil {
+		return proxy.NewStaticUpstream(route.Upstream)
+	}
+	return newDiscoveryService(ctx, route.Discovery, route.Discovery.Scheme)
+}
+
+func newDiscoveryService(ctx context.Context, d *config.DiscoveryConfig, scheme string) (*discovery.Service, error) {
+	provider, err := discovery.NewProvider(discoveryProviders.Config{
+		Type:       d.Type,
+		Service:    d.Service,
+		Port:       d.Port,
+		RecordType: d.RecordType,
+		Resolver:   d.Resolver,
+		Path:       d.Path,
+		Endpoint:   d.Endpoint,
+		Token:      d.Token,
+		Datacenter: d.Datacenter,
+		Tag:        d.Tag,
+		Kubeconfig: d.Kubeconfig,
+		MinRefresh: d.MinRefresh,
+		MaxRefresh: d.MaxRefresh,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	svc := discovery.NewService(provider, scheme)
+	svc.Start(ctx)
+	return svc, nil
+}
+
+func healthCheckConfig(hc *config.HealthCheckConfig) proxy.HealthCheckConfig {
+	return proxy.HealthCheckConfig{
+		Interval:           hc.Interval,
+		Timeout:            hc.Timeout,
+		Path:               hc.Path,
+		UnhealthyThreshold: hc.UnhealthyThreshold,
+		HealthyThreshold:   hc.HealthyThreshold,
+	}
+}
+
+// newTransport applies the route's upstream TLS, protocol and proxy
+// settings. Certificate files are watched until ctx is cancelled.
+func (s *Server) newTransport(ctx context.Context, route config.RouteConfig) (http.RoundTripper, error) {
+	cfg := proxy.TransportConfig{H2C: route.UpstreamProtocol == "h2c"}
+
+	if t := route.TLS; t != nil {
+		tlsCfg, err := tlsutil.NewClientTLS(ctx, tlsutil.ClientConfig{
+			CAFile:             t.CAFile,
+			CertFile:           t.CertFile,
+			KeyFile:            t.KeyFile,
+			ServerName:         t.ServerName,
+			MinVersion:         t.MinVersion,
+			CipherSuites:       t.CipherSuites,
+			InsecureSkipVerify: t.InsecureSkipVerify,
+		})
+		if err != nil {
+			return nil, err
+		}
+		cfg.TLS = tlsCfg
+	}
+
+	if p := route.Proxy; p != nil {
+		proxyURL, err := url.Parse(p.URL)
+		if err != nil {
+			return nil, fmt.Errorf("invalid proxy url: %w", err)
+		}
+		if p.Username != "" {
+			proxyURL.User = url.UserPassword(p.Username, p.Password)
+		}
+		cfg.Proxy = proxyURL
+		cfg.NoProxy = p.NoProxy
+	}
+
+	return proxy.NewTransport(cfg), nil
+}
+
+// ----------------------------------------------
+// PROXY
+// ----------------------------------------------
+func (s *Server) handleReverseProxy(route config.RouteConfig, backend http.Handler, w http.ResponseWriter, r *http.Request) {
+	upstream := route.Upstream
+	if route.Discovery != nil {
+		upstream = string(route.Discovery.Type) + "://" + route.Discovery.Service
+	}
+
+	log.Info().
+		Str("method", r.Method).
+		Str("path", route.Path).
+		Str("client", clientip.String(r)).
+		Str("upstream", upstream).
+		Str("authPolicy", route.AuthPolicy).
+		Msg("Proxying request")
+
+	backend.ServeHTTP(w, r)
+}
@@ -6,35 +6,60 @@ import (
 	"net/http"
 	"os"
 	"os/signal"
+	"slices"
+	"sync/atomic"
 	"syscall"
 	"time"
 
 	"github.com/go-chi/chi/v5"
 	"github.com/rs/zerolog/log"
-	"github.com/shrihariharanba/go-gateway/internal/server/proxy"
 
+	"github.com/shrihariharanba/go-gateway/internal/acme"
+	"github.com/shrihariharanba/go-gateway/internal/clientip"
 	"github.com/shrihariharanba/go-gateway/internal/config"
+	"github.com/shrihariharanba/go-gateway/internal/ingress"
 	"github.com/shrihariharanba/go-gateway/internal/sso"
 	"github.com/shrihariharanba/go-gateway/internal/sso/providers"
+	"github.com/shrihariharanba/go-gateway/internal/sso/providers/google"
 	"github.com/shrihariharanba/go-gateway/internal/telemetry"
 	teleprovider "github.com/shrihariharanba/go-gateway/internal/telemetry/providers"
 )
 
 type Server struct {
 	router      *chi.Mux
+	admin       *chi.Mux // internal admin/metrics listener, nil when disabled
 	cfg         *config.Config
-	httpServer  *http.Server
 	ssoProvider providers.SSOProvider
+	login       *sso.Login // browser login flow, nil when unsupported
 	telemetry   *telemetry.Telemetry
+	routes      atomic.Pointer[routeTable]
+	acme        *acme.Manager
+
+	// ctx is cancelled on shutdown and stops background workers such as
+	// service discovery watches.
+	ctx    context.Context
+	cancel context.CancelFunc
 }
 
 func NewServer(cfg *config.Config) *Server {
 	r := chi.NewRouter()
 
 	// Create server
+	ctx, cancel := context.WithCancel(context.Background())
 	s := &Server{
 		router: r,
 		cfg:    cfg,
+		ctx:    ctx,
+		cancel: cancel,
+	}
+
+	// ---------------------------
+	// Admin listener
+	// ---------------------------
+	// Metrics and admin endpoints move off the public listeners.
+	if cfg.Server.Admin.Enabled {
+		s.admin = chi.NewRouter()
+		s.admin.Get("/health", healthHandler)
 	}
 
 	// ---------------------------
@@ -49,6 +74,38 @@ func NewServer(cfg *config.Config) *Server {
 			TenantID:     cfg.SSO.TenantID,
 			IssuerURL:    cfg.SSO.IssuerURL,
 			RedirectURL:  cfg.SSO.RedirectURL,
+
+			AllowedTenants: cfg.SSO.AllowedTenants,
+			HostedDomains:  cfg.SSO.HostedDomains,
+
+			Audiences:  cfg.SSO.Audiences,
+			Algorithms: cfg.SSO.Algorithms,
+			ClockSkew:  cfg.SSO.ClockSkew,
+		}
+		claims, err := cfg.SSO.Claims.Mapping()
+		if err != nil {
+			log.Fatal().Err(err).Msg("Invalid SSO claim mapping")
+		}
+		pCfg.Claims = claims
+		if in := cfg.SSO.Introspection; in != nil {
+			pCfg.Introspection = &providers.IntrospectionConfig{
+				URL:          in.URL,
+				ClientID:     in.ClientID,
+				ClientSecret: in.ClientSecret,
+				AuthMethod:   in.AuthMethod,
+				CacheTTL:     in.CacheTTL,
+			}
+		}
+		if g := cfg.SSO.GoogleGroups; g != nil {
+			groups, err := google.NewDirectoryGroups(google.DirectoryConfig{
+				CredentialsFile: g.CredentialsFile,
+				AdminEmail:      g.AdminEmail,
+				CacheTTL:        g.CacheTTL,
+			})
+			if err != nil {
+				log.Fatal().Err(err).Msg("Failed to initialize Google group lookup")
+			}
+			pCfg.Groups = groups
 		}
 
 		provider, err := sso.NewProvider(pCfg)
@@ -57,11 +114,67 @@ func NewServer(cfg *config.Config) *Server {
 		}
 		s.ssoProvider = provider
 		log.Info().Str("provider", provider.Name()).Msg("SSO enabled")
+
+		if lp, ok := provider.(providers.LoginProvider); ok {
+			sc := cfg.SSO.Session
+			store, err := sso.NewSessionStore(sso.StoreConfig{Type: sc.Store.Type, Path: sc.Store.Path})
+			if err != nil {
+				log.Fatal().Err(err).Msg("Failed to initialize SSO session store")
+			}
+			s.login, err = sso.NewLogin(lp, sso.LoginConfig{
+				LoginPath:     cfg.SSO.LoginPath,
+				RedirectURL:   cfg.SSO.RedirectURL,
+				CookieName:    sc.CookieName,
+				MaxAge:        sc.MaxAge,
+				Secret:        sc.Secret,
+				Store:         store,
+				IdleTimeout:   sc.IdleTimeout,
+				RefreshBefore: sc.RefreshBefore,
+
+				LogoutPath:            cfg.SSO.Logout.Path,
+				PostLogoutRedirectURL: cfg.SSO.Logout.RedirectURL,
+				EndSession:            cfg.SSO.Logout.EndSession,
+				BackChannelPath:       cfg.SSO.Logout.BackChannelPath,
+			})
+			if err != nil {
+				log.Fatal().Err(err).Msg("Failed to initialize SSO login")
+			}
+			if sc.Secret == "" {
+				log.Warn().Msg("sso.session.secret not set: sessions end on restart and are not shared between replicas")
+			}
+			go s.login.Run(ctx)
+
+			// Session admin API, only on the internal listener.
+			if store != nil {
+				if s.admin != nil {
+					s.login.RegisterAdminHandlers(s.admin)
+				} else {
+					log.Warn().Msg("server.admin disabled: SSO session admin API not served")
+				}
+			}
+		}
 	} else {
 		s.ssoProvider = &sso.NoAuthProvider{}
 		log.Warn().Msg("SSO disabled: running without authentication")
 	}
 
+	// ---------------------------
+	// Client IP resolution
+	// ---------------------------
+	// Runs first so logging, telemetry and policies see the real client.
+	resolver, err := clientip.NewResolver(cfg.Server.TrustedProxies, cfg.Server.ClientIPHeader)
+	if err != nil {
+		log.Fatal().Err(err).Msg("Invalid trusted proxies")
+	}
+	r.Use(resolver.Middleware)
+
+	// ---------------------------
+	// HSTS
+	// ---------------------------
+	if cfg.Server.HasTLS() && cfg.Server.HSTS.Enabled {
+		r.Use(s.hstsMiddleware)
+	}
+
 	// ---------------------------
 	// Telemetry Setup
 	// ---------------------------
@@ -90,106 +203,148 @@ func NewServer(cfg *config.Config) *Server {
 		// -----------------------
 		if s.telemetry != nil {
 			r.Use(s.telemetry.Middleware)
-			s.telemetry.RegisterHandlers(r)
+			if s.admin != nil {
+				s.telemetry.RegisterHandlers(s.admin)
+			} else {
+				s.telemetry.RegisterHandlers(r)
+			}
 		}
 	}
 
 	// ---------------------------
 	// Health endpoint (no auth)
 	// ---------------------------
-	r.Get("/health", func(w http.ResponseWriter, r *http.Request) {
-		w.Write([]byte("OK"))
-	})
+	r.Get("/health", healthHandler)
+
+	// ---------------------------
+	// Browser login and logout (no auth)
+	// ---------------------------
+	if s.login != nil {
+		r.Get(s.login.LoginPath(), s.login.HandleLogin)
+		r.Get(s.login.CallbackPath(), s.login.HandleCallback)
+		r.Get(s.login.LogoutPath(), s.login.HandleLogoutPage)
+		r.Post(s.login.LogoutPath(), s.login.HandleLogout)
+		if p := s.login.BackChannelPath(); p != "" {
+			r.Post(p, s.login.HandleBackChannelLogout)
+		}
+	}
 
 	// ---------------------------
 	// Register application routes
 	// ---------------------------
-	s.registerRoutes()
+	// Application routes live in a swappable table so that they can be
+	// replaced at runtime (e.g. by the ingress controller).
+	r.Mount("/", http.HandlerFunc(s.serveRoutes))
+	if err := s.SetRoutes(cfg.Routes); err != nil {
+		log.Fatal().Err(err).Msg("Failed to register routes")
+	}
+
+	// ---------------------------
+	// Ingress controller mode
+	// ---------------------------
+	if cfg.Ingress.Enabled {
+		s.startIngressController()
+	}
 
 	return s
 }
 
-// ----------------------------------------------
-// ROUTES
-// ----------------------------------------------
-func (s *Server) registerRoutes() {
-	for _, rt := range s.cfg.Routes {
-		route := rt
-
-		var handler http.Handler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-			s.handleReverseProxy(route, w, r)
-		})
-
-		// SSO per-route policy
-		if s.ssoProvider != nil && route.AuthPolicy != "none" {
-			authRequired := route.AuthPolicy == "required"
-			handler = sso.AuthMiddleware(s.ssoProvider, authRequired)(handler)
-		}
-
-		s.router.Handle(route.Path, handler)
+// startIngressController merges routes discovered in the cluster with the
+// statically configured ones.
+func (s *Server) startIngressController() {
+	ic := s.cfg.Ingress
+	ctrl, err := ingress.New(ingress.Config{
+		Kubeconfig:       ic.Kubeconfig,
+		Namespace:        ic.Namespace,
+		ControllerName:   ic.ControllerName,
+		WatchIngress:     ic.WatchIngress,
+		IngressClassName: ic.IngressClassName,
+		PublishAddress:   ic.PublishAddress,
+	}, func(routes []config.RouteConfig) error {
+		return s.SetRoutes(append(slices.Clone(s.cfg.Routes), routes...))
+	})
+	if err != nil {
+		log.Fatal().Err(err).Msg("Failed to initialize ingress controller")
 	}
-}
 
-// ----------------------------------------------
-// PROXY
-// ----------------------------------------------
-func (s *Server) handleReverseProxy(route config.RouteConfig, w http.ResponseWriter, r *http.Request) {
-	log.Info().
-		Str("method", r.Method).
-		Str("path", route.Path).
-		Str("upstream", route.Upstream).
-		Str("authPolicy", route.AuthPolicy).
-		Msg("Proxying request")
-
-	proxy.ReverseProxy(route.Upstream).ServeHTTP(w, r)
+	go func() {
+		if err := ctrl.Run(s.ctx); err != nil {
+			log.Error().Err(err).Msg("Ingress controller stopped")
+		}
+	}()
 }
 
 // ----------------------------------------------
 // SERVER START / SHUTDOWN
 // ----------------------------------------------
 func (s *Server) Start() error {
-	addr := fmt.Sprintf(":%d", s.cfg.Server.Port)
-	s.httpServer = &http.Server{
-		Addr:              addr,
-		Handler:           s.router,
-		ReadHeaderTimeout: 10 * time.Second,
+	listeners, err := s.newListeners()
+	if err != nil {
+		return err
+	}
+	streams, err := s.newStreamListeners()
+	if err != nil {
+		return err
+	}
+	listeners = append(listeners, streams...)
+	egressListener, err := s.newEgressListener()
+	if err != nil {
+		return fmt.Errorf("egress setup failed: %w", err)
+	}
+	if egressListener != nil {
+		listeners = append(listeners, egressListener)
+	}
+	if admin := s.newAdminListener(); admin != nil {
+		listeners = append(listeners, admin)
+	}
+	// Built after the public listeners, which create the ACME manager.
+	if plain := s.plainHTTPServer(); plain != nil {
+		listeners = append(listeners, plainListener(plain))
 	}
-
-	log.Info().
-		Str("addr", addr).
-		Bool("tls", s.cfg.Server.TLSEnabled).
-		Bool("sso", s.cfg.SSO.Enabled).
-		Msg("Starting gateway server")
 
 	stopChan := make(chan os.Signal, 1)
 	signal.Notify(stopChan, syscall.SIGINT, syscall.SIGTERM)
 
-	serverErrChan := make(chan error)
+	serverErrChan := make(chan error, len(listeners))
 
-	go func() {
-		if s.cfg.Server.TLSEnabled {
-			serverErrChan <- s.httpServer.ListenAndServeTLS("cert.pem", "key.pem")
-		} else {
-			serverErrChan <- s.httpServer.ListenAndServe()
-		}
-	}()
+	for _, l := range listeners {
+		log.Info().
+			Str("listener", l.name).
+			Str("addr", l.addr).
+			Str("protocol", l.protocol).
+			Bool("tls", l.tls).
+			Bool("sso", s.cfg.SSO.Enabled).
+			Msg("Starting gateway listener")
+
+		go func() {
+			if err := l.serve(); err != nil && err != http.ErrServerClosed {
+				serverErrChan <- fmt.Errorf("listener %s: %w", l.name, err)
+				return
+			}
+			serverErrChan <- nil
+		}()
+	}
 
+	var serveErr error
 	select {
 	case <-stopChan:
 		log.Warn().Msg("Received shutdown signal")
-	case err := <-serverErrChan:
-		if err != nil && err != http.ErrServerClosed {
-			return fmt.Errorf("server error: %w", err)
-		}
+	case serveErr = <-serverErrChan:
 	}
 
 	ctx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
 	defer cancel()
 
 	log.Info().Msg("Graceful shutdown...")
+	s.cancel()
 
-	if err := s.httpServer.Shutdown(ctx); err != nil {
-		return fmt.Errorf("shutdown failed: %w", err)
+	for _, l := range listeners {
+		if err := l.shutdown(ctx); err != nil {
+			log.Warn().Err(err).Str("listener", l.name).Msg("Listener shutdown failed")
+		}
+	}
+	if serveErr != nil {
+		return fmt.Errorf("server error: %w", serveErr)
 	}
 
 	log.Info().Msg("Server stopped")
@@ -0,0 +1,110 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"time"
+
+	"github.com/shrihariharanba/go-gateway/internal/config"
+	"github.com/shrihariharanba/go-gateway/internal/server/proxy"
+	"github.com/shrihariharanba/go-gateway/internal/stream"
+)
+
+// newStreamListeners builds the L4 proxies for streamRoutes. TCP routes
+// sharing a listen address are served by one proxy that picks between
+// them by SNI.
+func (s *Server) newStreamListeners() ([]*listener, error) {
+	var out []*listener
+	tcp := make(map[string][]*stream.Route)
+	var tcpAddrs []string
+
+	for _, rc := range s.cfg.StreamRoutes {
+		name := rc.Name
+		if name == "" {
+			name = rc.Listen
+		}
+
+		upstream, err := s.newStreamUpstream(rc)
+		if err != nil {
+			return nil, fmt.Errorf("stream route '%s': %w", name, err)
+		}
+		if rc.HealthCheck != nil {
+			upstream = proxy.NewHealthChecked(s.ctx, upstream, healthCheckConfig(rc.HealthCheck), nil, nil)
+		}
+
+		route := &stream.Route{
+			Name:        name,
+			Balancer:    proxy.NewRoundRobin(upstream),
+			IdleTimeout: rc.IdleTimeout,
+		}
+		if len(rc.SNI) > 0 {
+			sni := rc.SNI
+			route.MatchSNI = func(serverName string) bool { return matchHost(sni, serverName) }
+		}
+
+		if rc.Protocol == config.StreamUDP {
+			p := stream.NewUDP(rc.Listen, route, s.observeStream)
+			out = append(out, streamListener(name, string(config.StreamUDP), p))
+			continue
+		}
+		if _, ok := tcp[rc.Listen]; !ok {
+			tcpAddrs = append(tcpAddrs, rc.Listen)
+		}
+		tcp[rc.Listen] = append(tcp[rc.Listen], route)
+	}
+
+	for _, addr := range tcpAddrs {
+		p := stream.NewTCP(addr, tcp[addr], s.observeStream)
+		out = append(out, streamListener("stream "+addr, string(config.StreamTCP), p))
+	}
+	return out, nil
+}
+
+// newStreamUpstream resolves a stream route's pool to tcp:// or udp://
+// targets so that it fits the same Upstream and health check machinery as
+// HTTP routes.
+func (s *Server) newStreamUpstream(rc config.StreamRouteConfig) (proxy.Upstream, error) {
+	scheme := string(rc.Protocol)
+	if scheme == "" {
+		scheme = string(config.StreamTCP)
+	}
+	if rc.Discovery != nil {
+		return newDiscoveryService(s.ctx, rc.Discovery, scheme)
+	}
+
+	targets := make(proxy.StaticUpstream, 0, len(rc.Upstreams))
+	for _, u := range rc.Upstreams {
+		targets = append(targets, &url.URL{Scheme: scheme, Host: u})
+	}
+	return targets, nil
+}
+
+func (s *Server) observeStream(route, protocol string, bytesIn, bytesOut int64, duration time.Duration) {
+	if s.telemetry != nil {
+		s.telemetry.ObserveStream(route, protocol, bytesIn, bytesOut, duration)
+	}
+}
+
+type streamProxy interface {
+	Addr() string
+	ListenAndServe() error
+	Shutdown(ctx context.Context) error
+}
+
+func streamListener(name, protocol string, p streamProxy) *listener {
+	return &listener{
+		name:     name,
+		addr:     p.Addr(),
+		protocol: protocol,
+		serve: func() error {
+			if err := p.ListenAndServe(); !errors.Is(err, net.ErrClosed) {
+				return err
+			}
+			return nil
+		},
+		shutdown: p.Shutdown,
+	}
+}
@@ -0,0 +1,92 @@
+package server
+
+import (
+	"crypto/tls"
+	"slices"
+
+	"github.com/shrihariharanba/go-gateway/internal/acme"
+	"github.com/shrihariharanba/go-gateway/internal/config"
+	"github.com/shrihariharanba/go-gateway/internal/tlsutil"
+)
+
+// Legacy certificate locations used when no certificates are configured.
+const (
+	defaultCertFile = "cert.pem"
+	defaultKeyFile  = "key.pem"
+)
+
+// listenerTLS builds the TLS settings of a listener. Certificate files are
+// watched until the server shuts down. defaultALPN is offered when the
+// configuration does not list protocols itself.
+func (s *Server) listenerTLS(tc config.ServerTLSConfig, defaultALPN []string) (*tls.Config, error) {
+	certs := make([]tlsutil.CertificateFiles, 0, len(tc.Certificates))
+	for _, c := range tc.Certificates {
+		certs = append(certs, tlsutil.CertificateFiles{CertFile: c.CertFile, KeyFile: c.KeyFile})
+	}
+	if len(certs) == 0 {
+		certs = []tlsutil.CertificateFiles{{CertFile: defaultCertFile, KeyFile: defaultKeyFile}}
+	}
+
+	alpn := tc.ALPN
+	if len(alpn) == 0 {
+		alpn = defaultALPN
+	}
+
+	var dynamic tlsutil.CertificateProvider
+	if tc.ACME.Enabled {
+		// Listeners share one manager; validation ensures their settings match.
+		if s.acme == nil {
+			mgr, err := newACMEManager(tc.ACME)
+			if err != nil {
+				return nil, err
+			}
+			s.acme = mgr
+		}
+		dynamic = s.acme
+
+		// With ACME the built-in certificates are optional.
+		if len(tc.Certificates) == 0 {
+			certs = nil
+		}
+		if s.acme.TLSALPNChallenge() {
+			alpn = append(slices.Clone(alpn), acme.ALPNProto)
+		}
+	}
+
+	return tlsutil.NewServerTLS(s.ctx, tlsutil.ServerConfig{
+		Certificates: certs,
+		MinVersion:   tc.MinVersion,
+		MaxVersion:   tc.MaxVersion,
+		CipherSuites: tc.CipherSuites,
+		ALPN:         alpn,
+		OCSPStapling: tc.OCSPStapling,
+		Dynamic:      dynamic,
+	})
+}
+
+// acmeConfig returns the ACME settings shared by the listeners, if any.
+func (s *Server) acmeConfig() (config.ACMEConfig, bool) {
+	for _, l := range s.listenerConfigs() {
+		if l.TLS != nil && l.TLS.ACME.Enabled {
+			return l.TLS.ACME, true
+		}
+	}
+	return config.ACMEConfig{}, false
+}
+
+func newACMEManager(ac config.ACMEConfig) (*acme.Manager, error) {
+	store, err := acme.NewStore(acme.StoreConfig{Type: ac.Store.Type, Path: ac.Store.Path})
+	if err != nil {
+		return nil, err
+	}
+	return acme.New(acme.Config{
+		Hosts:        ac.Hosts,
+		Email:        ac.Email,
+		DirectoryURL: ac.DirectoryURL,
+		CAFile:       ac.CAFile,
+		AcceptTOS:    ac.AcceptTOS,
+		Challenges:   ac.Challenges,
+		RenewBefore:  ac.RenewBefore,
+		Store:        store,
+	})
+}
@@ -0,0 +1,130 @@
+package sso
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/rs/zerolog/log"
+)
+
+// sessionInfo is a stored session as shown by the admin API, without its
+// tokens.
+type sessionInfo struct {
+	ID          string    `json:"id"`
+	UserID      string    `json:"user"`
+	Email       string    `json:"email,omitempty"`
+	Roles       []string  `json:"roles,omitempty"`
+	Created     time.Time `json:"created"`
+	LastSeen    time.Time `json:"lastSeen"`
+	Expires     time.Time `json:"expires"`
+	TokenExpiry time.Time `json:"tokenExpiry,omitzero"`
+	Refreshable bool      `json:"refreshable"`
+}
+
+// RegisterAdminHandlers adds the session admin API:
+//
+//	GET    /sessions?user=ID  list sessions, of one user when given
+//	DELETE /sessions?user=ID  revoke all sessions of a user
+//	DELETE /sessions/{id}     revoke one session
+//
+// It is only meant for the admin listener and does nothing without a
+// session store.
+func (l *Login) RegisterAdminHandlers(r chi.Router) {
+	if l.cfg.Store == nil {
+		return
+	}
+	r.Get("/sessions", l.handleListSessions)
+	r.Delete("/sessions", l.handleRevokeUser)
+	r.Delete("/sessions/{id}", l.handleRevokeSession)
+}
+
+func (l *Login) handleListSessions(w http.ResponseWriter, r *http.Request) {
+	sessions, err := l.cfg.Store.List(r.Context(), r.URL.Query().Get("user"))
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to list SSO sessions")
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	now := time.Now()
+	out := make([]sessionInfo, 0, len(sessions))
+	for _, s := range sessions {
+		if now.After(s.Expires) {
+			continue
+		}
+		out = append(out, sessionInfo{
+			ID:          s.ID,
+			UserID:      s.UserID,
+			Email:       s.Email,
+			Roles:       s.Roles,
+			Created:     s.Created,
+			LastSeen:    s.LastSeen,
+			Expires:     s.Expires,
+			TokenExpiry: s.TokenExpiry,
+			Refreshable: s.RefreshToken != "",
+		})
+	}
+	slices.SortFunc(out, func(a, b sessionInfo) int {
+		if c := strings.Compare(a.UserID, b.UserID); c != 0 {
+			return c
+		}
+		return a.Created.Compare(b.Created)
+	})
+	writeJSON(w, out)
+}
+
+func (l *Login) handleRevokeUser(w http.ResponseWriter, r *http.Request) {
+	user := r.URL.Query().Get("user")
+	if user == "" {
+		http.Error(w, "user parameter is required", http.StatusBadRequest)
+		return
+	}
+	sessions, err := l.cfg.Store.List(r.Context(), user)
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to list SSO sessions")
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+	for _, s := range sessions {
+		if err := l.cfg.Store.Delete(r.Context(), s.ID); err != nil {
+			log.Error().Err(err).Str("user", user).Msg("Failed to revoke SSO session")
+			http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+			return
+		}
+	}
+	log.Info().Str("user", user).Int("count", len(sessions)).Msg("SSO sessions revoked")
+	writeJSON(w, map[string]int{"revoked": len(sessions)})
+}
+
+func (l *Login) handleRevokeSession(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+	sess, err := l.cfg.Store.Get(r.Context(), id)
+	if err != nil {
+		if errors.Is(err, ErrSessionNotFound) {
+			http.Error(w, "Not Found", http.StatusNotFound)
+			return
+		}
+		log.Error().Err(err).Msg("Failed to load SSO session")
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+	if err := l.cfg.Store.Delete(r.Context(), id); err != nil {
+		log.Error().Err(err).Str("user", sess.UserID).Msg("Failed to revoke SSO session")
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+	log.Info().Str("user", sess.UserID).Msg("SSO session revoked")
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func writeJSON(w http.ResponseWriter, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		log.Warn().Err(err).Msg("Failed to write admin response")
+	}
+}
@@ -0,0 +1,530 @@
+package sso
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/rs/zerolog/log"
+	"golang.org/x/oauth2"
+	"golang.org/x/sync/singleflight"
+
+	"github.com/shrihariharanba/go-gateway/internal/sso/providers"
+)
+
+const (
+	defaultLoginPath     = "/login"
+	defaultCookieName    = "gw_session"
+	defaultSessionMaxAge = 8 * time.Hour
+	defaultIdleTimeout   = time.Hour
+	defaultRefreshBefore = time.Minute
+	loginStateMaxAge     = 10 * time.Minute
+
+	// touchInterval limits how often a request records activity on a
+	// stored session, and so the precision of the idle timeout.
+	touchInterval = time.Minute
+	// purgeInterval is how often expired stored sessions are deleted.
+	purgeInterval = 5 * time.Minute
+	// refreshTimeout bounds a token refresh, which requests of the session
+	// wait on.
+	refreshTimeout = 30 * time.Second
+	// maxUpdateAttempts bounds retries of a stored session update that
+	// keeps losing to concurrent writers.
+	maxUpdateAttempts = 3
+
+	// maxCookieSize is the limit browsers are required to support.
+	maxCookieSize = 4096
+
+	returnParam = "rd"
+)
+
+// LoginConfig configures the browser login flow.
+type LoginConfig struct {
+	LoginPath   string        // default /login
+	RedirectURL string        // the callback registered with the provider
+	CookieName  string        // default gw_session
+	MaxAge      time.Duration // absolute session lifetime, default 8h
+	Secret      string        // cookie key material; random when empty
+
+	// Store keeps sessions and their tokens server side; nil keeps the
+	// session in the cookie, without tokens.
+	Store         SessionStore
+	IdleTimeout   time.Duration // stored sessions only, default 1h
+	RefreshBefore time.Duration // refresh access tokens this early, default 1m
+
+	LogoutPath            string // default /logout
+	PostLogoutRedirectURL string // where the browser goes after logout
+	EndSession            bool   // also log out at the provider
+	BackChannelPath       string // back-channel logout receiver; needs Store
+}
+
+// Login serves the authorization-code flow. HandleLogin sends the browser to
+// the provider with a random state, nonce and PKCE verifier kept in a short
+// lived cookie; HandleCallback checks them, redeems the code and replaces
+// that cookie with the session.
+//
+// With a store, the cookie only names a StoredSession. Its access token is
+// refreshed shortly before it expires, and the session ends when idle for
+// IdleTimeout, after MaxAge, or when the refresh token is rejected.
+type Login struct {
+	provider     providers.LoginProvider
+	codec        *SessionCodec
+	cfg          LoginConfig
+	callbackPath string
+	secure       bool
+	refreshes    singleflight.Group
+}
+
+var errCookieTooLarge = errors.New("cookie too large")
+
+// loginState is kept in a cookie between HandleLogin and HandleCallback.
+type loginState struct {
+	State    string    `json:"state"`
+	Nonce    string    `json:"nonce"`
+	Verifier string    `json:"verifier"`
+	Return   string    `json:"rd"`
+	Expires  time.Time `json:"exp"`
+}
+
+func NewLogin(provider providers.LoginProvider, cfg LoginConfig) (*Login, error) {
+	if cfg.LoginPath == "" {
+		cfg.LoginPath = defaultLoginPath
+	}
+	if cfg.CookieName == "" {
+		cfg.CookieName = defaultCookieName
+	}
+	if cfg.MaxAge <= 0 {
+		cfg.MaxAge = defaultSessionMaxAge
+	}
+	if cfg.LogoutPath == "" {
+		cfg.LogoutPath = defaultLogoutPath
+	}
+	if cfg.IdleTimeout <= 0 {
+		cfg.IdleTimeout = defaultIdleTimeout
+	}
+	if cfg.RefreshBefore <= 0 {
+		cfg.RefreshBefore = defaultRefreshBefore
+	}
+
+	redirect, err := url.Parse(cfg.RedirectURL)
+	if err != nil || redirect.Path == "" {
+		return nil, fmt.Errorf("invalid sso redirect url %q", cfg.RedirectURL)
+	}
+	codec, err := NewSessionCodec(cfg.Secret)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Login{
+		provider:     provider,
+		codec:        codec,
+		cfg:          cfg,
+		callbackPath: redirect.Path,
+		// Browsers drop Secure cookies set over plain HTTP, so follow the
+		// scheme the provider redirects back to.
+		secure: redirect.Scheme == "https",
+	}, nil
+}
+
+func (l *Login) LoginPath() string    { return l.cfg.LoginPath }
+func (l *Login) CallbackPath() string { return l.callbackPath }
+
+func (l *Login) loginCookie() string { return l.cfg.CookieName + "_login" }
+
+// HandleLogin starts a login. The page to return to is taken from the rd
+// query parameter.
+func (l *Login) HandleLogin(w http.ResponseWriter, r *http.Request) {
+	st := loginState{
+		State:    rand.Text(),
+		Nonce:    rand.Text(),
+		Verifier: oauth2.GenerateVerifier(),
+		Return:   safeReturn(r.URL.Query().Get(returnParam)),
+		Expires:  time.Now().Add(loginStateMaxAge),
+	}
+	if err := l.setCookie(w, l.loginCookie(), st, st.Expires); err != nil {
+		log.Error().Err(err).Msg("Failed to start SSO login")
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+	http.Redirect(w, r, l.provider.AuthCodeURL(st.State, st.Nonce, st.Verifier), http.StatusFound)
+}
+
+// HandleCallback completes a login at the redirect URL.
+func (l *Login) HandleCallback(w http.ResponseWriter, r *http.Request) {
+	var st loginState
+	c, err := r.Cookie(l.loginCookie())
+	if err != nil || l.codec.Open(l.loginCookie(), c.Value, &st) != nil || time.Now().After(st.Expires) {
+		http.Error(w, "Login expired, please try again", http.StatusBadRequest)
+		return
+	}
+	l.clearCookie(w, l.loginCookie())
+
+	q := r.URL.Query()
+	if subtle.ConstantTimeCompare([]byte(q.Get("state")), []byte(st.State)) != 1 {
+		http.Error(w, "Invalid login state", http.StatusBadRequest)
+		return
+	}
+	if e := q.Get("error"); e != "" {
+		log.Warn().Str("error", e).Str("description", q.Get("error_description")).Msg("SSO login rejected by provider")
+		http.Error(w, "Login failed: "+e, http.StatusUnauthorized)
+		return
+	}
+
+	tok, err := l.provider.Exchange(r.Context(), q.Get("code"), st.Verifier)
+	if err != nil {
+		log.Warn().Err(err).Msg("SSO login failed")
+		http.Error(w, "Login failed", http.StatusUnauthorized)
+		return
+	}
+	idToken, _ := tok.Extra("id_token").(string)
+	if idToken == "" {
+		log.Warn().Msg("SSO login failed: no id_token in token response")
+		http.Error(w, "Login failed", http.StatusUnauthorized)
+		return
+	}
+	authCtx, err := l.provider.Authenticate(providers.WithNonce(r.Context(), st.Nonce), idToken)
+	if err != nil {
+		log.Warn().Err(err).Msg("SSO login failed")
+		http.Error(w, "Login failed", http.StatusUnauthorized)
+		return
+	}
+
+	sess := Session{
+		UserID:  authCtx.UserID,
+		Email:   authCtx.UserEmail,
+		Roles:   authCtx.Roles,
+		Scopes:  tokenScopes(tok),
+		Claims:  authCtx.Claims,
+		Expires: time.Now().Add(l.cfg.MaxAge),
+	}
+	if l.cfg.Store != nil {
+		sess, err = l.storeSession(r.Context(), authCtx, tok, idToken)
+		if err != nil {
+			log.Error().Err(err).Str("user", authCtx.UserID).Msg("Failed to store SSO session")
+			http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+			return
+		}
+	}
+	err = l.setCookie(w, l.cfg.CookieName, sess, sess.Expires)
+	if errors.Is(err, errCookieTooLarge) && sess.Claims != nil {
+		// Policies on claims then deny rather than the login failing.
+		log.Warn().Str("user", sess.UserID).Msg("SSO claims do not fit in the session cookie and are dropped; use a session store to keep them")
+		sess.Claims = nil
+		err = l.setCookie(w, l.cfg.CookieName, sess, sess.Expires)
+	}
+	if err != nil {
+		log.Error().Err(err).Str("user", sess.UserID).Msg("Failed to create SSO session")
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	log.Info().Str("user", sess.UserID).Str("provider", l.provider.Name()).Msg("SSO login")
+	http.Redirect(w, r, st.Return, http.StatusFound)
+}
+
+// Session returns the user of a valid session cookie.
+func (l *Login) Session(r *http.Request) (*providers.AuthContext, bool) {
+	c, err := r.Cookie(l.cfg.CookieName)
+	if err != nil {
+		return nil, false
+	}
+	var sess Session
+	if err := l.codec.Open(l.cfg.CookieName, c.Value, &sess); err != nil || time.Now().After(sess.Expires) {
+		return nil, false
+	}
+	if l.cfg.Store != nil {
+		return l.storedSession(r.Context(), sess.ID)
+	}
+	return sess.authContext(), true
+}
+
+// storeSession saves a new login and returns the cookie naming it.
+func (l *Login) storeSession(ctx context.Context, authCtx *providers.AuthContext, tok *oauth2.Token, idToken string) (Session, error) {
+	now := time.Now()
+	sub, sid := tokenSession(idToken)
+	stored := &StoredSession{
+		ID:           rand.Text(),
+		Subject:      sub,
+		SID:          sid,
+		UserID:       authCtx.UserID,
+		Email:        authCtx.UserEmail,
+		Roles:        authCtx.Roles,
+		Scopes:       tokenScopes(tok),
+		Claims:       authCtx.Claims,
+		IDToken:      idToken,
+		AccessToken:  tok.AccessToken,
+		RefreshToken: tok.RefreshToken,
+		TokenExpiry:  tok.Expiry,
+		Created:      now,
+		LastSeen:     now,
+	}
+	l.setExpires(stored)
+	if err := l.cfg.Store.Create(ctx, stored); err != nil {
+		return Session{}, err
+	}
+	return Session{
+		ID:      stored.ID,
+		UserID:  stored.UserID,
+		Expires: stored.Created.Add(l.cfg.MaxAge),
+	}, nil
+}
+
+// storedSession loads a session, ending it once expired and refreshing its
+// access token when due.
+func (l *Login) storedSession(ctx context.Context, id string) (*providers.AuthContext, bool) {
+	sess, err := l.cfg.Store.Get(ctx, id)
+	if err != nil {
+		if !errors.Is(err, ErrSessionNotFound) {
+			log.Error().Err(err).Msg("Failed to load SSO session")
+		}
+		return nil, false
+	}
+
+	now := time.Now()
+	if now.After(sess.Expires) {
+		l.endSession(ctx, sess, "expired")
+		return nil, false
+	}
+	if l.refreshDue(sess, now) {
+		if sess, err = l.refresh(ctx, id); err != nil {
+			return nil, false
+		}
+	}
+	if now.Sub(sess.LastSeen) >= touchInterval {
+		touched, err := l.update(ctx, sess, func(s *StoredSession) { s.LastSeen = now })
+		switch {
+		case errors.Is(err, ErrSessionNotFound):
+			// Revoked while this request was in flight.
+			return nil, false
+		case err != nil:
+			log.Warn().Err(err).Str("user", sess.UserID).Msg("Failed to record SSO session activity")
+		default:
+			sess = touched
+		}
+	}
+	return sess.authContext(), true
+}
+
+func (l *Login) refreshDue(sess *StoredSession, now time.Time) bool {
+	return sess.RefreshToken != "" && !sess.TokenExpiry.IsZero() &&
+		now.Add(l.cfg.RefreshBefore).After(sess.TokenExpiry)
+}
+
+// refresh renews the access token of session id. Concurrent requests of a
+// session share one refresh, since providers that rotate refresh tokens
+// reject all but the first use.
+func (l *Login) refresh(ctx context.Context, id string) (*StoredSession, error) {
+	// The refresh outlives the request that started it, as others wait
+	// on it, but not a provider that stops answering.
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), refreshTimeout)
+	defer cancel()
+	v, err, _ := l.refreshes.Do(id, func() (any, error) {
+		// Reload: a refresh that just finished may already have run.
+		sess, err := l.cfg.Store.Get(ctx, id)
+		if err != nil {
+			return nil, err
+		}
+		now := time.Now()
+		if !l.refreshDue(sess, now) {
+			return sess, nil
+		}
+
+		tok, err := l.provider.Refresh(ctx, sess.RefreshToken)
+		if err != nil {
+			// A transient failure leaves a still valid token in use; the
+			// next request tries again.
+			if now.Before(sess.TokenExpiry) {
+				log.Warn().Err(err).Str("user", sess.UserID).Msg("SSO token refresh failed, retrying later")
+				return sess, nil
+			}
+			log.Warn().Err(err).Str("user", sess.UserID).Msg("SSO token refresh failed")
+			l.endSession(ctx, sess, "refresh failed")
+			return nil, err
+		}
+
+		idToken, _ := tok.Extra("id_token").(string)
+		var authCtx *providers.AuthContext
+		if idToken != "" {
+			authCtx, err = l.provider.Authenticate(ctx, idToken)
+			if err != nil || authCtx.UserID != sess.UserID {
+				if err == nil {
+					err = fmt.Errorf("refreshed token is for user %q", authCtx.UserID)
+				}
+				log.Warn().Err(err).Str("user", sess.UserID).Msg("SSO token refresh failed")
+				l.endSession(ctx, sess, "refresh failed")
+				return nil, err
+			}
+		}
+		updated, err := l.update(ctx, sess, func(s *StoredSession) {
+			if authCtx != nil {
+				s.IDToken = idToken
+				s.Email = authCtx.UserEmail
+				s.Roles = authCtx.Roles
+				s.Claims = authCtx.Claims
+			}
+			s.AccessToken = tok.AccessToken
+			s.TokenExpiry = tok.Expiry
+			if scopes := tokenScopes(tok); len(scopes) > 0 {
+				s.Scopes = scopes
+			}
+			if tok.RefreshToken != "" {
+				s.RefreshToken = tok.RefreshToken
+			}
+		})
+		if err != nil {
+			if !errors.Is(err, ErrSessionNotFound) {
+				log.Error().Err(err).Str("user", sess.UserID).Msg("Failed to store refreshed SSO session")
+			}
+			return nil, err
+		}
+		sess = updated
+		log.Debug().Str("user", sess.UserID).Time("expiry", sess.TokenExpiry).Msg("SSO token refreshed")
+		return sess, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return v.(*StoredSession), nil
+}
+
+// tokenScopes returns the scopes granted in a token response. The scope
+// parameter may be left out when they are the ones requested.
+func tokenScopes(tok *oauth2.Token) []string {
+	scope, _ := tok.Extra("scope").(string)
+	return strings.Fields(scope)
+}
+
+// setExpires recomputes the deadline of sess from the idle and absolute
+// lifetimes.
+func (l *Login) setExpires(sess *StoredSession) {
+	sess.Expires = sess.LastSeen.Add(l.cfg.IdleTimeout)
+	if limit := sess.Created.Add(l.cfg.MaxAge); limit.Before(sess.Expires) {
+		sess.Expires = limit
+	}
+}
+
+// update applies change to sess and saves it. When another writer saved
+// the session since sess was read, change is applied again to a fresh copy
+// so that neither update is lost; a session deleted meanwhile stays deleted.
+func (l *Login) update(ctx context.Context, sess *StoredSession, change func(*StoredSession)) (*StoredSession, error) {
+	for attempt := 1; ; attempt++ {
+		change(sess)
+		l.setExpires(sess)
+		err := l.cfg.Store.Update(ctx, sess)
+		if err == nil {
+			return sess, nil
+		}
+		if !errors.Is(err, ErrSessionConflict) || attempt == maxUpdateAttempts {
+			return nil, err
+		}
+		if sess, err = l.cfg.Store.Get(ctx, sess.ID); err != nil {
+			return nil, err
+		}
+	}
+}
+
+func (l *Login) endSession(ctx context.Context, sess *StoredSession, reason string) {
+	if err := l.cfg.Store.Delete(ctx, sess.ID); err != nil {
+		log.Error().Err(err).Str("user", sess.UserID).Msg("Failed to delete SSO session")
+		return
+	}
+	log.Info().Str("user", sess.UserID).Str("reason", reason).Msg("SSO session ended")
+}
+
+// Run purges expired stored sessions until ctx is cancelled, then closes
+// the store.
+func (l *Login) Run(ctx context.Context) {
+	if l.cfg.Store == nil {
+		return
+	}
+	defer l.cfg.Store.Close()
+
+	ticker := time.NewTicker(purgeInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			n, err := l.cfg.Store.DeleteExpired(ctx, now)
+			if err != nil {
+				log.Error().Err(err).Msg("Failed to purge expired SSO sessions")
+			} else if n > 0 {
+				log.Debug().Int("count", n).Msg("Purged expired SSO sessions")
+			}
+		}
+	}
+}
+
+// RedirectToLogin sends the browser to the login page, returning to the
+// current URL afterwards.
+func (l *Login) RedirectToLogin(w http.ResponseWriter, r *http.Request) {
+	target := l.cfg.LoginPath + "?" + url.Values{returnParam: {r.URL.RequestURI()}}.Encode()
+	http.Redirect(w, r, target, http.StatusFound)
+}
+
+func (l *Login) setCookie(w http.ResponseWriter, name string, v any, expires time.Time) error {
+	value, err := l.codec.Seal(name, v)
+	if err != nil {
+		return err
+	}
+	if len(name)+len(value) > maxCookieSize {
+		return fmt.Errorf("%w: %s is %d bytes, over %d", errCookieTooLarge, name, len(value), maxCookieSize)
+	}
+	http.SetCookie(w, &http.Cookie{
+		Name:     name,
+		Value:    value,
+		Path:     "/",
+		Expires:  expires,
+		HttpOnly: true,
+		Secure:   l.secure,
+		// Lax lets the cookie through the top-level redirect back from
+		// the provider.
+		SameSite: http.SameSiteLaxMode,
+	})
+	return nil
+}
+
+func (l *Login) clearCookie(w http.ResponseWriter, name string) {
+	http.SetCookie(w, &http.Cookie{
+		Name:     name,
+		Path:     "/",
+		MaxAge:   -1,
+		HttpOnly: true,
+		Secure:   l.secure,
+		SameSite: http.SameSiteLaxMode,
+	})
+}
+
+// safeReturn only allows local paths so that the login flow cannot be used
+// as an open redirect. Browsers drop tabs and newlines from URLs and read a
+// backslash as "/", so "/<tab>/evil.com" would become "//evil.com"; any
+// control character or space is refused rather than second-guessing them.
+func safeReturn(rd string) string {
+	if !strings.HasPrefix(rd, "/") || strings.HasPrefix(rd, "//") || strings.HasPrefix(rd, "/\\") {
+		return "/"
+	}
+	if strings.ContainsFunc(rd, func(r rune) bool { return r <= ' ' || r == 0x7f }) {
+		return "/"
+	}
+	u, err := url.Parse(rd)
+	if err != nil || u.Scheme != "" || u.Host != "" || u.User != nil {
+		return "/"
+	}
+	return rd
+}
+
+// wantsHTML reports whether r looks like a browser navigation, which is sent
+// to the login page rather than answered with 401.
+func wantsHTML(r *http.Request) bool {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		return false
+	}
+	return strings.Contains(r.Header.Get("Accept"), "text/html")
+}
@@ -0,0 +1,70 @@
+package sso
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestSafeReturn(t *testing.T) {
+	for _, tc := range []struct {
+		rd, want string
+	}{
+		{"/app/page?x=1#top", "/app/page?x=1#top"},
+		{"/", "/"},
+		{"", "/"},
+		{"app", "/"},
+		{"https://evil.com/", "/"},
+		{"//evil.com", "/"},
+		{"/\\evil.com", "/"},
+		{"/\t/evil.com", "/"},
+		{"/\n/evil.com", "/"},
+		{"/\r\n/evil.com", "/"},
+		{"/ /evil.com", "/"},
+		{"/\x00", "/"},
+		{"/\x7f", "/"},
+		{"/%zz", "/"},
+		{"/a//b", "/a//b"},
+	} {
+		if got := safeReturn(tc.rd); got != tc.want {
+			t.Errorf("safeReturn(%q) = %q, want %q", tc.rd, got, tc.want)
+		}
+	}
+}
+
+func TestLogoutPageOnlyConfirms(t *testing.T) {
+	l, err := NewLogin(nil, LoginConfig{RedirectURL: "https://gw.example.com/callback"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodGet, "/logout?rd=/app%3Fa%3D1", nil)
+	r.AddCookie(&http.Cookie{Name: defaultCookieName, Value: "session"})
+	l.HandleLogoutPage(w, r)
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d", w.Code)
+	}
+	if c := w.Result().Cookies(); len(c) != 0 {
+		t.Errorf("GET changed cookies: %v", c)
+	}
+	body := w.Body.String()
+	if !strings.Contains(body, `method="post"`) || !strings.Contains(body, `action="/logout?rd=%2Fapp%3Fa%3D1"`) {
+		t.Errorf("unexpected form:\n%s", body)
+	}
+
+	w = httptest.NewRecorder()
+	r = httptest.NewRequest(http.MethodPost, "/logout?rd=/app", nil)
+	l.HandleLogout(w, r)
+	if w.Code != http.StatusSeeOther || w.Header().Get("Location") != "/app" {
+		t.Errorf("POST: status %d, location %q", w.Code, w.Header().Get("Location"))
+	}
+	cleared := false
+	for _, c := range w.Result().Cookies() {
+		cleared = cleared || (c.Name == defaultCookieName && c.MaxAge < 0)
+	}
+	if !cleared {
+		t.Error("POST did not clear the session cookie")
+	}
+}
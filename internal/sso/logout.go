@@ -0,0 +1,154 @@
+package sso
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"html/template"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/rs/zerolog/log"
+
+	"github.com/shrihariharanba/go-gateway/internal/sso/providers"
+)
+
+const defaultLogoutPath = "/logout"
+
+func (l *Login) LogoutPath() string { return l.cfg.LogoutPath }
+
+// BackChannelPath returns the back-channel logout receiver path, or "" when
+// it is disabled.
+func (l *Login) BackChannelPath() string { return l.cfg.BackChannelPath }
+
+// logoutPage asks for confirmation before logging out. Logout only happens
+// on POST: session cookies are SameSite=Lax, so another site can make the
+// browser GET the logout path but cannot make it POST there with them.
+var logoutPage = template.Must(template.New("logout").Parse(`<!DOCTYPE html>
+<html>
+<head><meta charset="utf-8"><title>Sign out</title></head>
+<body>
+<form method="post" action="{{.Action}}">
+<p>Sign out of this site?</p>
+<button type="submit">Sign out</button>
+</form>
+</body>
+</html>
+`))
+
+// HandleLogoutPage renders a form that POSTs to the logout path, keeping
+// the rd parameter.
+func (l *Login) HandleLogoutPage(w http.ResponseWriter, r *http.Request) {
+	action := l.cfg.LogoutPath
+	if rd := r.URL.Query().Get(returnParam); rd != "" {
+		action += "?" + url.Values{returnParam: {safeReturn(rd)}}.Encode()
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-store")
+	w.Header().Set("X-Frame-Options", "DENY")
+	if err := logoutPage.Execute(w, struct{ Action string }{action}); err != nil {
+		log.Error().Err(err).Msg("Failed to render logout page")
+	}
+}
+
+// HandleLogout ends the gateway session. It must only be routed for POST;
+// see HandleLogoutPage. With EndSession set the browser continues to the
+// provider's logout endpoint, otherwise to PostLogoutRedirectURL or the
+// local rd parameter.
+func (l *Login) HandleLogout(w http.ResponseWriter, r *http.Request) {
+	var idToken string
+	if c, err := r.Cookie(l.cfg.CookieName); err == nil {
+		var sess Session
+		if l.codec.Open(l.cfg.CookieName, c.Value, &sess) == nil {
+			if l.cfg.Store != nil && sess.ID != "" {
+				if stored, err := l.cfg.Store.Get(r.Context(), sess.ID); err == nil {
+					idToken = stored.IDToken
+					l.endSession(r.Context(), stored, "logout")
+				}
+			} else {
+				log.Info().Str("user", sess.UserID).Msg("SSO logout")
+			}
+		}
+	}
+	l.clearCookie(w, l.cfg.CookieName)
+
+	target := l.cfg.PostLogoutRedirectURL
+	if target == "" {
+		target = safeReturn(r.URL.Query().Get(returnParam))
+	}
+	if l.cfg.EndSession {
+		if lp, ok := l.provider.(providers.LogoutProvider); ok {
+			if u := lp.EndSessionURL(idToken, l.cfg.PostLogoutRedirectURL); u != "" {
+				target = u
+			}
+		}
+	}
+	// 303 so the browser follows with a GET.
+	http.Redirect(w, r, target, http.StatusSeeOther)
+}
+
+// HandleBackChannelLogout receives OpenID Connect back-channel logout
+// requests from the provider and ends the stored sessions they name.
+func (l *Login) HandleBackChannelLogout(w http.ResponseWriter, r *http.Request) {
+	// Per the spec the response must not be cached.
+	w.Header().Set("Cache-Control", "no-store")
+
+	lp, ok := l.provider.(providers.LogoutProvider)
+	if !ok || l.cfg.Store == nil {
+		http.Error(w, "Not Implemented", http.StatusNotImplemented)
+		return
+	}
+	token := r.PostFormValue("logout_token")
+	if token == "" {
+		http.Error(w, "missing logout_token", http.StatusBadRequest)
+		return
+	}
+	lt, err := lp.VerifyLogoutToken(r.Context(), token)
+	if err != nil {
+		log.Warn().Err(err).Msg("Rejected SSO back-channel logout")
+		http.Error(w, "invalid logout_token", http.StatusBadRequest)
+		return
+	}
+
+	sessions, err := l.cfg.Store.List(r.Context(), "")
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to list SSO sessions")
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+	n := 0
+	for _, s := range sessions {
+		if lt.SessionID != "" {
+			if s.SID != lt.SessionID || (lt.Subject != "" && s.Subject != lt.Subject) {
+				continue
+			}
+		} else if s.Subject != lt.Subject {
+			continue
+		}
+		l.endSession(r.Context(), s, "back-channel logout")
+		n++
+	}
+	log.Info().Str("sub", lt.Subject).Str("sid", lt.SessionID).Int("count", n).Msg("SSO back-channel logout")
+	w.WriteHeader(http.StatusOK)
+}
+
+// tokenSession reads the sub and sid claims of an ID token that has
+// already been verified.
+func tokenSession(idToken string) (sub, sid string) {
+	parts := strings.Split(idToken, ".")
+	if len(parts) != 3 {
+		return "", ""
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return "", ""
+	}
+	var claims struct {
+		Subject   string `json:"sub"`
+		SessionID string `json:"sid"`
+	}
+	if json.Unmarshal(payload, &claims) != nil {
+		return "", ""
+	}
+	return claims.Subject, claims.SessionID
+}
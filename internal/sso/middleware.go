@@ -3,6 +3,7 @@ package sso
 import (
 	"context"
 	"net/http"
+	"strings"
 
 	"github.com/shrihariharanba/go-gateway/internal/sso/providers"
 )
@@ -11,13 +12,24 @@ type contextKey string
 
 const AuthContextKey contextKey = "auth"
 
-func AuthMiddleware(provider providers.SSOProvider, authRequired bool) func(http.Handler) http.Handler {
+// AuthMiddleware authenticates requests by bearer token or, when login is
+// set, by session cookie. Browsers without either are sent to the login
+// page on required routes. Users lacking the scopes the route requires for
+// the request method are refused with 403.
+func AuthMiddleware(provider providers.SSOProvider, login *Login, authRequired bool, scopes ScopeRequirement) func(http.Handler) http.Handler {
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 			var authCtx *providers.AuthContext
-			if provider != nil {
-				token := r.Header.Get("Authorization")
+			token := bearerToken(r)
+			if token == "" && login != nil {
+				authCtx, _ = login.Session(r)
+			}
+			if provider != nil && authCtx == nil {
 				if token == "" && authRequired {
+					if login != nil && wantsHTML(r) {
+						login.RedirectToLogin(w, r)
+						return
+					}
 					http.Error(w, "Unauthorized: missing token", http.StatusUnauthorized)
 					return
 				}
@@ -29,6 +41,21 @@ func AuthMiddleware(provider providers.SSOProvider, authRequired bool) func(http
 				}
 			}
 
+			if required := scopes.For(r.Method); len(required) > 0 {
+				if authCtx == nil {
+					if login != nil && wantsHTML(r) {
+						login.RedirectToLogin(w, r)
+						return
+					}
+					http.Error(w, "Unauthorized: authentication required", http.StatusUnauthorized)
+					return
+				}
+				if !scopes.Allows(authCtx, r.Method) {
+					insufficientScope(w, required)
+					return
+				}
+			}
+
 			if authCtx == nil {
 				authCtx = &providers.AuthContext{
 					UserID: "anonymous",
@@ -53,3 +80,13 @@ func FromContext(ctx context.Context) *providers.AuthContext {
 		Roles:  []string{"public"},
 	}
 }
+
+// bearerToken returns the credentials of an "Authorization: Bearer" header.
+// Other schemes are passed on as is.
+func bearerToken(r *http.Request) string {
+	auth := r.Header.Get("Authorization")
+	if scheme, token, ok := strings.Cut(auth, " "); ok && strings.EqualFold(scheme, "Bearer") {
+		return strings.TrimSpace(token)
+	}
+	return auth
+}
@@ -2,31 +2,242 @@ package azure
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
 
+	gooidc "github.com/coreos/go-oidc/v3/oidc"
 	"github.com/shrihariharanba/go-gateway/internal/sso/providers"
+	"github.com/shrihariharanba/go-gateway/internal/sso/providers/oidc"
 )
 
+const (
+	defaultAuthority = "https://login.microsoftonline.com"
+	discoveryTimeout = 10 * time.Second
+)
+
+// IsMultiTenant reports whether tenant is one of the Entra ID aliases that
+// accept users from more than one directory.
+func IsMultiTenant(tenant string) bool {
+	switch strings.ToLower(tenant) {
+	case "common", "organizations":
+		return true
+	}
+	return false
+}
+
+// AzureProvider is the generic OIDC provider with Entra ID's per-tenant
+// issuers and v1.0/v2.0 token formats.
 type AzureProvider struct {
-	cfg providers.Config
+	*oidc.Provider
+
+	v1Issuer string   // the cloud's STS host; may contain {tenantid}
+	v2Issuer string   // may contain {tenantid} for multi-tenant apps
+	tenants  []string // tid values accepted
+}
+
+// discoveryDoc holds the fields of the tenant's OpenID configuration that
+// the provider needs.
+type discoveryDoc struct {
+	Issuer   string `json:"issuer"`
+	AuthURL  string `json:"authorization_endpoint"`
+	TokenURL string `json:"token_endpoint"`
+	JWKSURL  string `json:"jwks_uri"`
+	// EndSessionURL is the logout endpoint.
+	EndSessionURL string `json:"end_session_endpoint"`
 }
 
+// NewAzureProvider discovers the Entra ID endpoints of cfg.TenantID. A tenant
+// GUID or domain restricts tokens to that directory; "common" and
+// "organizations" accept the directories in cfg.AllowedTenants. IssuerURL
+// optionally overrides the authority for national clouds.
 func NewAzureProvider(cfg providers.Config) (providers.SSOProvider, error) {
-	return &AzureProvider{cfg: cfg}, nil
+	if cfg.ClientID == "" {
+		return nil, errors.New("azure sso config missing client_id")
+	}
+	if cfg.TenantID == "" {
+		return nil, errors.New("azure sso config missing tenant_id")
+	}
+	multiTenant := IsMultiTenant(cfg.TenantID)
+	if multiTenant && len(cfg.AllowedTenants) == 0 {
+		return nil, fmt.Errorf("azure sso tenant %q requires allowed tenants", cfg.TenantID)
+	}
+
+	authority := strings.TrimSuffix(cfg.IssuerURL, "/")
+	if authority == "" {
+		authority = defaultAuthority
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), discoveryTimeout)
+	defer cancel()
+
+	doc, err := discover(ctx, fmt.Sprintf("%s/%s/v2.0/.well-known/openid-configuration", authority, cfg.TenantID))
+	if err != nil {
+		return nil, fmt.Errorf("failed to discover Azure tenant %s: %w", cfg.TenantID, err)
+	}
+	// v1.0 tokens are issued by the STS host of the authority's cloud
+	// (sts.windows.net for the public cloud), which only the v1.0
+	// metadata names.
+	v1, err := discover(ctx, fmt.Sprintf("%s/%s/.well-known/openid-configuration", authority, cfg.TenantID))
+	if err != nil {
+		return nil, fmt.Errorf("failed to discover Azure tenant %s v1.0 issuer: %w", cfg.TenantID, err)
+	}
+
+	tenants := cfg.AllowedTenants
+	if !multiTenant {
+		// The discovered issuer names the directory by GUID even when
+		// TenantID is a domain.
+		tid, ok := tenantFromIssuer(doc.Issuer)
+		if !ok {
+			return nil, fmt.Errorf("unexpected Azure issuer %q", doc.Issuer)
+		}
+		tenants = []string{tid}
+	}
+
+	oc := oidc.ConfigFrom("azure", cfg)
+	oc.Endpoints = &gooidc.ProviderConfig{
+		IssuerURL: doc.Issuer,
+		AuthURL:   doc.AuthURL,
+		TokenURL:  doc.TokenURL,
+		JWKSURL:   doc.JWKSURL,
+	}
+	oc.EndSessionURL = doc.EndSessionURL
+	// The issuer depends on the token version and tenant, so it is checked
+	// in Authenticate.
+	oc.SkipIssuerCheck = true
+	if len(oc.Audiences) == 0 {
+		// v1.0 tokens for an exposed API carry the App ID URI.
+		oc.Audiences = []string{cfg.ClientID, "api://" + cfg.ClientID}
+	}
+	// Group object IDs authorize the same way as app roles.
+	oc.Claims = oc.Claims.WithDefaults(providers.ClaimPaths{
+		UserID: "oid",
+		Email:  "preferred_username",
+		Roles:  "roles",
+		Groups: "groups",
+	})
+
+	provider, err := oidc.New(context.Background(), oc)
+	if err != nil {
+		return nil, err
+	}
+	return &AzureProvider{
+		Provider: provider,
+		v1Issuer: v1.Issuer,
+		v2Issuer: doc.Issuer,
+		tenants:  tenants,
+	}, nil
+}
+
+func discover(ctx context.Context, wellKnown string) (*discoveryDoc, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, wellKnown, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%s: %s", wellKnown, resp.Status)
+	}
+
+	var doc discoveryDoc
+	if err := json.NewDecoder(resp.Body).Decode(&doc); err != nil {
+		return nil, fmt.Errorf("decoding %s: %w", wellKnown, err)
+	}
+	if doc.Issuer == "" || doc.JWKSURL == "" {
+		return nil, fmt.Errorf("%s: missing issuer or jwks_uri", wellKnown)
+	}
+	return &doc, nil
+}
+
+// tenantFromIssuer extracts the tenant from https://<authority>/<tid>/v2.0.
+func tenantFromIssuer(issuer string) (string, bool) {
+	rest, ok := strings.CutSuffix(strings.TrimSuffix(issuer, "/"), "/v2.0")
+	if !ok {
+		return "", false
+	}
+	i := strings.LastIndex(rest, "/")
+	if i < 0 || rest[i+1:] == "" || strings.Contains(rest[i+1:], "{") {
+		return "", false
+	}
+	return rest[i+1:], true
+}
+
+type azureClaims struct {
+	Issuer     string `json:"iss"`
+	Version    string `json:"ver"`
+	TenantID   string `json:"tid"`
+	UPN        string `json:"upn"`         // v1.0
+	UniqueName string `json:"unique_name"` // v1.0, guests
+	Email      string `json:"email"`
 }
 
 func (a *AzureProvider) Authenticate(ctx context.Context, token string) (*providers.AuthContext, error) {
-	return &providers.AuthContext{
-		UserID:    "azure-user",
-		UserEmail: "user@azure.com",
-		Roles:     []string{"admin"},
-		Token:     token,
-	}, nil
+	idToken, err := a.Verify(ctx, token)
+	if err != nil {
+		return nil, err
+	}
+
+	claims, err := a.checkIssuer(idToken)
+	if err != nil {
+		return nil, err
+	}
+
+	authCtx, err := a.AuthContext(idToken, token)
+	if err != nil {
+		return nil, err
+	}
+	// v1.0 tokens have no preferred_username.
+	for _, alt := range []string{claims.UPN, claims.Email, claims.UniqueName} {
+		if authCtx.UserEmail == "" {
+			authCtx.UserEmail = alt
+		}
+	}
+	return authCtx, nil
 }
 
-func (a *AzureProvider) GetLoginURL() string {
-	return "https://login.microsoftonline.com/"
+// VerifyLogoutToken adds the tenant and issuer checks of Authenticate.
+func (a *AzureProvider) VerifyLogoutToken(ctx context.Context, token string) (*providers.LogoutToken, error) {
+	idToken, lt, err := a.VerifyLogout(ctx, token)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := a.checkIssuer(idToken); err != nil {
+		return nil, err
+	}
+	return lt, nil
+}
+
+// checkIssuer requires an allowed tenant and the issuer that tenant uses
+// for the token's version.
+func (a *AzureProvider) checkIssuer(idToken *gooidc.IDToken) (*azureClaims, error) {
+	var claims azureClaims
+	if err := idToken.Claims(&claims); err != nil {
+		return nil, fmt.Errorf("failed to parse Azure claims: %w", err)
+	}
+
+	if !slices.ContainsFunc(a.tenants, func(t string) bool { return strings.EqualFold(t, claims.TenantID) }) {
+		return nil, fmt.Errorf("azure tenant %q is not allowed", claims.TenantID)
+	}
+	if want := a.issuer(claims.Version, claims.TenantID); claims.Issuer != want {
+		return nil, fmt.Errorf("azure token issued by %q, expected %q", claims.Issuer, want)
+	}
+	return &claims, nil
 }
 
-func (a *AzureProvider) Name() string {
-	return "azure"
+// issuer returns the issuer expected for a token of the given version from
+// tenant tid.
+func (a *AzureProvider) issuer(version, tid string) string {
+	issuer := a.v2Issuer
+	if version == "1.0" {
+		issuer = a.v1Issuer
+	}
+	return strings.Replace(issuer, "{tenantid}", tid, 1)
 }
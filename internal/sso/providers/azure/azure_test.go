@@ -0,0 +1,60 @@
+package azure
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/shrihariharanba/go-gateway/internal/sso/providers"
+)
+
+const testTenant = "11111111-2222-3333-4444-555555555555"
+
+// cloud serves the v1.0 and v2.0 metadata of a national cloud whose STS
+// host differs from the public cloud's.
+func cloud(t *testing.T) *httptest.Server {
+	t.Helper()
+	var srv *httptest.Server
+	srv = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		tenant := strings.Split(strings.TrimPrefix(r.URL.Path, "/"), "/")[0]
+		tid := tenant
+		if IsMultiTenant(tenant) {
+			tid = "{tenantid}"
+		}
+		doc := map[string]string{"jwks_uri": srv.URL + "/keys", "authorization_endpoint": srv.URL + "/authorize", "token_endpoint": srv.URL + "/token"}
+		if strings.Contains(r.URL.Path, "/v2.0/") {
+			doc["issuer"] = srv.URL + "/" + tid + "/v2.0"
+		} else {
+			doc["issuer"] = "https://sts.usgovcloudapi.example/" + tid + "/"
+		}
+		json.NewEncoder(w).Encode(doc)
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestIssuerFromAuthority(t *testing.T) {
+	srv := cloud(t)
+
+	for _, tc := range []struct {
+		tenant  string
+		allowed []string
+	}{
+		{testTenant, nil},
+		{"organizations", []string{testTenant}},
+	} {
+		p, err := NewAzureProvider(providers.Config{ClientID: "app", TenantID: tc.tenant, IssuerURL: srv.URL, AllowedTenants: tc.allowed})
+		if err != nil {
+			t.Fatal(err)
+		}
+		a := p.(*AzureProvider)
+		if got, want := a.issuer("1.0", testTenant), "https://sts.usgovcloudapi.example/"+testTenant+"/"; got != want {
+			t.Errorf("%s: v1.0 issuer %q, want %q", tc.tenant, got, want)
+		}
+		if got, want := a.issuer("2.0", testTenant), srv.URL+"/"+testTenant+"/v2.0"; got != want {
+			t.Errorf("%s: v2.0 issuer %q, want %q", tc.tenant, got, want)
+		}
+	}
+}
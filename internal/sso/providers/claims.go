@@ -0,0 +1,329 @@
+package providers
+
+import (
+	"encoding/json"
+	"fmt"
+	"maps"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// ClaimMapping builds AuthContext from token claims. Each field is read
+// from a claim path and passed through its transforms.
+type ClaimMapping struct {
+	UserID ClaimRule
+	Email  ClaimRule
+	Roles  ClaimRule
+	// Groups adds its values to Roles.
+	Groups ClaimRule
+	// Extra adds named values to AuthContext.Claims.
+	Extra map[string]ClaimRule
+}
+
+// ClaimRule reads one value from the claims.
+//
+// Paths are JSONPath-like: dots descend into objects, brackets select a
+// quoted key or an array index, and [*] collects every element, as in
+// "realm_access.roles", `resource_access["my-app"].roles` or
+// "groups[*].name". A leading "$." is optional. A claim named by the whole
+// path wins, so that namespaced claims such as "https://example.com/roles"
+// work without quoting.
+type ClaimRule struct {
+	Path       string
+	Transforms []ClaimTransform
+}
+
+// ClaimTransform rewrites string values. Exactly one of StripPrefix,
+// Pattern or Lookup is set. Values it does not apply to are kept, or
+// dropped with DropUnmatched.
+type ClaimTransform struct {
+	StripPrefix string
+	Pattern     *regexp.Regexp // matches are replaced with Replace
+	Replace     string         // may use $1 etc.; default the whole match
+	Lookup      map[string]string
+	// DropUnmatched drops values without the prefix, not matching the
+	// pattern or missing from the lookup table.
+	DropUnmatched bool
+}
+
+// WithDefaults fills empty paths from def. The default groups path only
+// applies when roles is left to its default as well, so that a configured
+// roles rule is the only source of roles unless groups is also set.
+func (m ClaimMapping) WithDefaults(def ClaimPaths) ClaimMapping {
+	if m.UserID.Path == "" {
+		m.UserID.Path = def.UserID
+	}
+	if m.Email.Path == "" {
+		m.Email.Path = def.Email
+	}
+	if m.Roles.Path == "" {
+		m.Roles.Path = def.Roles
+		if m.Groups.Path == "" {
+			m.Groups.Path = def.Groups
+		}
+	}
+	return m
+}
+
+// Validate checks the syntax of every path.
+func (m ClaimMapping) Validate() error {
+	rules := map[string]ClaimRule{"userId": m.UserID, "email": m.Email, "roles": m.Roles, "groups": m.Groups}
+	for name, r := range m.Extra {
+		rules["extra."+name] = r
+	}
+	for name, r := range rules {
+		if r.Path == "" {
+			continue
+		}
+		if _, err := parseClaimPath(r.Path); err != nil {
+			return fmt.Errorf("claim %s: %w", name, err)
+		}
+	}
+	return nil
+}
+
+// Apply fills userID, email, roles and claims from the token claims.
+// Claims holds every token claim, overlaid by the Extra values.
+func (m ClaimMapping) Apply(claims map[string]any) *AuthContext {
+	authCtx := &AuthContext{
+		UserID:    m.UserID.String(claims),
+		UserEmail: m.Email.String(claims),
+		Roles:     append(m.Roles.Strings(claims), m.Groups.Strings(claims)...),
+		Claims:    maps.Clone(claims),
+	}
+	if authCtx.Claims == nil {
+		authCtx.Claims = make(map[string]any, len(m.Extra))
+	}
+	for name, r := range m.Extra {
+		if v, ok := r.Value(claims); ok {
+			authCtx.Claims[name] = v
+		}
+	}
+	return authCtx
+}
+
+// String returns the first value of the rule, or "".
+func (r ClaimRule) String(claims map[string]any) string {
+	if vals := r.Strings(claims); len(vals) > 0 {
+		return vals[0]
+	}
+	return ""
+}
+
+// Strings accepts a string, number or boolean claim or an array of them.
+func (r ClaimRule) Strings(claims map[string]any) []string {
+	v, ok := LookupClaim(claims, r.Path)
+	if !ok {
+		return nil
+	}
+	return r.transform(claimValues(v))
+}
+
+// Value returns the raw claim when there are no transforms, otherwise the
+// transformed strings: one string for a scalar claim, a list for an array.
+func (r ClaimRule) Value(claims map[string]any) (any, bool) {
+	v, ok := LookupClaim(claims, r.Path)
+	if !ok {
+		return nil, false
+	}
+	if len(r.Transforms) == 0 {
+		return v, true
+	}
+	vals := r.transform(claimValues(v))
+	if _, isList := v.([]any); isList {
+		return vals, true
+	}
+	if len(vals) == 0 {
+		return nil, false
+	}
+	return vals[0], true
+}
+
+func (r ClaimRule) transform(vals []string) []string {
+	for _, t := range r.Transforms {
+		out := vals[:0:0]
+		for _, v := range vals {
+			if v, ok := t.apply(v); ok {
+				out = append(out, v)
+			}
+		}
+		vals = out
+	}
+	return vals
+}
+
+func (t ClaimTransform) apply(v string) (string, bool) {
+	matched := true
+	switch {
+	case t.StripPrefix != "":
+		var ok bool
+		if v, ok = strings.CutPrefix(v, t.StripPrefix); !ok {
+			matched = false
+		}
+	case t.Pattern != nil:
+		if !t.Pattern.MatchString(v) {
+			matched = false
+		} else if t.Replace != "" {
+			v = t.Pattern.ReplaceAllString(v, t.Replace)
+		}
+	case t.Lookup != nil:
+		if mapped, ok := t.Lookup[v]; ok {
+			v = mapped
+		} else {
+			matched = false
+		}
+	}
+	if !matched && t.DropUnmatched {
+		return "", false
+	}
+	return v, true
+}
+
+// claimValues flattens a claim into strings. Other types are skipped.
+func claimValues(v any) []string {
+	switch v := v.(type) {
+	case []any:
+		out := make([]string, 0, len(v))
+		for _, e := range v {
+			out = append(out, claimValues(e)...)
+		}
+		return out
+	case string:
+		return []string{v}
+	case json.Number, float64, bool:
+		return []string{fmt.Sprint(v)}
+	}
+	return nil
+}
+
+// claimSegment is one step of a claim path: an object key, an array index
+// or the [*] wildcard.
+type claimSegment struct {
+	key      string
+	index    int
+	isIndex  bool
+	wildcard bool
+}
+
+func parseClaimPath(path string) ([]claimSegment, error) {
+	p := strings.TrimPrefix(path, "$")
+	var segs []claimSegment
+	for p != "" {
+		switch p[0] {
+		case '.':
+			p = p[1:]
+			end := strings.IndexAny(p, ".[")
+			if end < 0 {
+				end = len(p)
+			}
+			if end == 0 {
+				return nil, fmt.Errorf("invalid claim path %q: empty key", path)
+			}
+			segs = append(segs, claimSegment{key: p[:end]})
+			p = p[end:]
+		case '[':
+			end := strings.Index(p, "]")
+			if end < 0 {
+				return nil, fmt.Errorf("invalid claim path %q: unclosed [", path)
+			}
+			inner := p[1:end]
+			// A quoted key may contain "]".
+			if len(inner) > 0 && (inner[0] == '"' || inner[0] == '\'') {
+				q := inner[0]
+				n := strings.IndexByte(p[2:], q)
+				if n < 0 || len(p) < n+4 || p[n+3] != ']' {
+					return nil, fmt.Errorf("invalid claim path %q: bad quoted key", path)
+				}
+				segs = append(segs, claimSegment{key: p[2 : n+2]})
+				p = p[n+4:]
+				continue
+			}
+			if inner == "*" {
+				segs = append(segs, claimSegment{wildcard: true})
+			} else {
+				i, err := strconv.Atoi(inner)
+				if err != nil || i < 0 {
+					return nil, fmt.Errorf("invalid claim path %q: bad index %q", path, inner)
+				}
+				segs = append(segs, claimSegment{index: i, isIndex: true})
+			}
+			p = p[end+1:]
+		default:
+			// The first key needs no leading dot.
+			if len(segs) > 0 || strings.HasPrefix(path, "$") {
+				return nil, fmt.Errorf("invalid claim path %q", path)
+			}
+			p = "." + p
+		}
+	}
+	if len(segs) == 0 {
+		return nil, fmt.Errorf("invalid claim path %q", path)
+	}
+	return segs, nil
+}
+
+// LookupClaim resolves a claim path. Paths after a wildcard return every
+// match as a list.
+func LookupClaim(claims map[string]any, path string) (any, bool) {
+	if path == "" {
+		return nil, false
+	}
+	if v, ok := claims[path]; ok {
+		return v, true
+	}
+	segs, err := parseClaimPath(path)
+	if err != nil {
+		return nil, false
+	}
+	return lookupSegments(claims, segs)
+}
+
+func lookupSegments(cur any, segs []claimSegment) (any, bool) {
+	for i, seg := range segs {
+		switch {
+		case seg.wildcard:
+			arr, ok := cur.([]any)
+			if !ok {
+				return nil, false
+			}
+			var out []any
+			for _, e := range arr {
+				v, ok := lookupSegments(e, segs[i+1:])
+				if !ok {
+					continue
+				}
+				if list, isList := v.([]any); isList && hasWildcard(segs[i+1:]) {
+					out = append(out, list...)
+				} else {
+					out = append(out, v)
+				}
+			}
+			return out, true
+		case seg.isIndex:
+			arr, ok := cur.([]any)
+			if !ok || seg.index >= len(arr) {
+				return nil, false
+			}
+			cur = arr[seg.index]
+		default:
+			m, ok := cur.(map[string]any)
+			if !ok {
+				return nil, false
+			}
+			if cur, ok = m[seg.key]; !ok {
+				return nil, false
+			}
+		}
+	}
+	return cur, true
+}
+
+func hasWildcard(segs []claimSegment) bool {
+	for _, s := range segs {
+		if s.wildcard {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,134 @@
+package providers
+
+import (
+	"reflect"
+	"regexp"
+	"slices"
+	"testing"
+)
+
+func TestParseClaimPath(t *testing.T) {
+	for _, tc := range []struct {
+		path string
+		want []claimSegment
+	}{
+		{"email", []claimSegment{{key: "email"}}},
+		{"$.email", []claimSegment{{key: "email"}}},
+		{"realm_access.roles", []claimSegment{{key: "realm_access"}, {key: "roles"}}},
+		{`resource_access["my-app"].roles`, []claimSegment{{key: "resource_access"}, {key: "my-app"}, {key: "roles"}}},
+		{`a['x.y']`, []claimSegment{{key: "a"}, {key: "x.y"}}},
+		{`a["b]c"]`, []claimSegment{{key: "a"}, {key: "b]c"}}},
+		{"groups[*].name", []claimSegment{{key: "groups"}, {wildcard: true}, {key: "name"}}},
+		{"groups[1]", []claimSegment{{key: "groups"}, {index: 1, isIndex: true}}},
+	} {
+		got, err := parseClaimPath(tc.path)
+		if err != nil {
+			t.Errorf("parseClaimPath(%q): %v", tc.path, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("parseClaimPath(%q) = %+v, want %+v", tc.path, got, tc.want)
+		}
+	}
+}
+
+func TestParseClaimPathErrors(t *testing.T) {
+	for _, path := range []string{
+		"", "$", "a..b", "a.", "a[", "a[x]", "a[-1]", `a["b]`, `a["b"x]`, "$email", "a[0]b",
+	} {
+		if segs, err := parseClaimPath(path); err == nil {
+			t.Errorf("parseClaimPath(%q) = %+v, want an error", path, segs)
+		}
+	}
+}
+
+func TestLookupClaim(t *testing.T) {
+	claims := map[string]any{
+		"sub":                      "u1",
+		"https://example.com/role": "admin",
+		"realm_access":             map[string]any{"roles": []any{"a", "b"}},
+		"resource_access":          map[string]any{"my-app": map[string]any{"roles": []any{"c"}}},
+		"groups": []any{
+			map[string]any{"name": "g1", "tags": []any{"x"}},
+			map[string]any{"name": "g2", "tags": []any{"y", "z"}},
+			map[string]any{"id": 3},
+		},
+	}
+	for _, tc := range []struct {
+		path string
+		want any
+	}{
+		{"sub", "u1"},
+		{"https://example.com/role", "admin"},
+		{"realm_access.roles", []any{"a", "b"}},
+		{`resource_access["my-app"].roles[0]`, "c"},
+		{"groups[*].name", []any{"g1", "g2"}},
+		{"groups[*].tags[*]", []any{"x", "y", "z"}},
+		{"groups[1].name", "g2"},
+	} {
+		got, ok := LookupClaim(claims, tc.path)
+		if !ok || !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("LookupClaim(%q) = %v, %v, want %v", tc.path, got, ok, tc.want)
+		}
+	}
+	for _, path := range []string{"missing", "sub.x", "groups[9].name", "realm_access[0]", "a..b"} {
+		if got, ok := LookupClaim(claims, path); ok {
+			t.Errorf("LookupClaim(%q) = %v, want no match", path, got)
+		}
+	}
+}
+
+func TestClaimMappingApply(t *testing.T) {
+	m := ClaimMapping{
+		UserID: ClaimRule{Path: "sub"},
+		Roles: ClaimRule{Path: "groups", Transforms: []ClaimTransform{
+			{StripPrefix: "app-", DropUnmatched: true},
+			{Lookup: map[string]string{"ops": "admin"}},
+		}},
+		Extra: map[string]ClaimRule{
+			"tenant": {Path: "org.id", Transforms: []ClaimTransform{{Pattern: regexp.MustCompile(`^t-(\d+)$`), Replace: "$1"}}},
+		},
+	}
+	got := m.Apply(map[string]any{
+		"sub":    "u1",
+		"groups": []any{"app-ops", "app-dev", "other"},
+		"org":    map[string]any{"id": "t-42"},
+	})
+	if got.UserID != "u1" || !slices.Equal(got.Roles, []string{"admin", "dev"}) {
+		t.Errorf("user %q roles %v", got.UserID, got.Roles)
+	}
+	if got.Claims["tenant"] != "42" || got.Claims["sub"] != "u1" {
+		t.Errorf("claims %v", got.Claims)
+	}
+}
+
+func TestClaimMappingGroupsDefault(t *testing.T) {
+	def := ClaimPaths{Roles: "roles", Groups: "groups"}
+	claims := map[string]any{
+		"roles":  []any{"reader"},
+		"groups": []any{"0b8c6f1e-group"},
+	}
+
+	got := ClaimMapping{}.WithDefaults(def).Apply(claims)
+	if !slices.Equal(got.Roles, []string{"reader", "0b8c6f1e-group"}) {
+		t.Errorf("default roles %v", got.Roles)
+	}
+
+	// A configured roles rule replaces both defaults.
+	custom := ClaimMapping{Roles: ClaimRule{Path: "roles", Transforms: []ClaimTransform{
+		{Lookup: map[string]string{"reader": "viewer"}, DropUnmatched: true},
+	}}}
+	got = custom.WithDefaults(def).Apply(claims)
+	if !slices.Equal(got.Roles, []string{"viewer"}) {
+		t.Errorf("configured roles %v", got.Roles)
+	}
+
+	// Groups can be mapped alongside it.
+	custom.Groups = ClaimRule{Path: "groups", Transforms: []ClaimTransform{
+		{Lookup: map[string]string{"0b8c6f1e-group": "admin"}, DropUnmatched: true},
+	}}
+	got = custom.WithDefaults(def).Apply(claims)
+	if !slices.Equal(got.Roles, []string{"viewer", "admin"}) {
+		t.Errorf("configured roles and groups %v", got.Roles)
+	}
+}
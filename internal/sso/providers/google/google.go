@@ -2,31 +2,92 @@ package google
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
 
 	"github.com/shrihariharanba/go-gateway/internal/sso/providers"
+	"github.com/shrihariharanba/go-gateway/internal/sso/providers/oidc"
 )
 
+const issuer = "https://accounts.google.com"
+
+// AnyDomain as the only hosted domain accepts every Google account,
+// including consumer accounts without one.
+const AnyDomain = "*"
+
+// GoogleProvider is the generic OIDC provider for Google accounts, with
+// hosted-domain and verified-email checks.
 type GoogleProvider struct {
+	*oidc.Provider
 	cfg providers.Config
 }
 
+// NewGoogleProvider verifies Google ID tokens against Google's published
+// keys. Only Workspace accounts of cfg.HostedDomains are accepted, unless
+// the list is just AnyDomain; an empty list is refused.
 func NewGoogleProvider(cfg providers.Config) (providers.SSOProvider, error) {
-	return &GoogleProvider{cfg: cfg}, nil
+	if cfg.ClientID == "" {
+		return nil, errors.New("google sso config missing client_id")
+	}
+	if cfg.RedirectURL == "" {
+		return nil, errors.New("google sso config missing redirect_url")
+	}
+	if len(cfg.HostedDomains) == 0 {
+		return nil, errors.New("google sso config missing hosted domains")
+	}
+
+	oc := oidc.ConfigFrom("google", cfg)
+	oc.IssuerURL = issuer
+	// A single domain pre-selects the account chooser; the token is
+	// checked either way.
+	if len(cfg.HostedDomains) == 1 && cfg.HostedDomains[0] != AnyDomain {
+		oc.AuthParams = map[string]string{"hd": cfg.HostedDomains[0]}
+	}
+
+	provider, err := oidc.New(context.Background(), oc)
+	if err != nil {
+		return nil, err
+	}
+	return &GoogleProvider{Provider: provider, cfg: cfg}, nil
 }
 
 func (g *GoogleProvider) Authenticate(ctx context.Context, token string) (*providers.AuthContext, error) {
-	return &providers.AuthContext{
-		UserID:    "google-user",
-		UserEmail: "user@gmail.com",
-		Roles:     []string{"user"},
-		Token:     token,
-	}, nil
-}
+	idToken, err := g.Verify(ctx, token)
+	if err != nil {
+		return nil, err
+	}
 
-func (g *GoogleProvider) GetLoginURL() string {
-	return "https://accounts.google.com/o/oauth2/v2/auth"
-}
+	var claims struct {
+		EmailVerified bool   `json:"email_verified"`
+		HostedDomain  string `json:"hd"`
+	}
+	if err := idToken.Claims(&claims); err != nil {
+		return nil, fmt.Errorf("failed to parse Google claims: %w", err)
+	}
+
+	if !claims.EmailVerified {
+		return nil, errors.New("google account email is not verified")
+	}
+	// hd is only present for Workspace accounts, so consumer accounts
+	// never match.
+	if !slices.Equal(g.cfg.HostedDomains, []string{AnyDomain}) && !slices.ContainsFunc(g.cfg.HostedDomains, func(d string) bool {
+		return claims.HostedDomain != "" && strings.EqualFold(d, claims.HostedDomain)
+	}) {
+		return nil, fmt.Errorf("google hosted domain %q is not allowed", claims.HostedDomain)
+	}
 
-func (g *GoogleProvider) Name() string {
-	return "google"
+	authCtx, err := g.AuthContext(idToken, token)
+	if err != nil {
+		return nil, err
+	}
+	if g.cfg.Groups != nil {
+		groups, err := g.cfg.Groups.Groups(ctx, authCtx.UserEmail)
+		if err != nil {
+			return nil, fmt.Errorf("failed to look up Google groups: %w", err)
+		}
+		authCtx.Roles = append(authCtx.Roles, groups...)
+	}
+	return authCtx, nil
 }
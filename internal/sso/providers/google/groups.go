@@ -0,0 +1,164 @@
+package google
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"sync"
+	"time"
+
+	"golang.org/x/oauth2/jwt"
+)
+
+const (
+	directoryGroupsURL  = "https://admin.googleapis.com/admin/directory/v1/groups"
+	directoryGroupScope = "https://www.googleapis.com/auth/admin.directory.group.readonly"
+	defaultTokenURL     = "https://oauth2.googleapis.com/token"
+	defaultGroupsTTL    = 5 * time.Minute
+)
+
+// DirectoryConfig configures group lookups through the Admin SDK Directory
+// API. The service account needs domain-wide delegation for the group
+// read-only scope and impersonates AdminEmail.
+type DirectoryConfig struct {
+	CredentialsFile string        // service account key (JSON)
+	AdminEmail      string        // Workspace admin to impersonate
+	CacheTTL        time.Duration // default 5m
+}
+
+type groupsEntry struct {
+	groups  []string
+	expires time.Time
+}
+
+// DirectoryGroups is a providers.GroupLookup returning the email addresses
+// of the Workspace groups a user is a direct member of. Results are cached
+// per user.
+type DirectoryGroups struct {
+	client *http.Client
+	ttl    time.Duration
+
+	mu    sync.Mutex
+	cache map[string]groupsEntry
+}
+
+func NewDirectoryGroups(cfg DirectoryConfig) (*DirectoryGroups, error) {
+	if cfg.AdminEmail == "" {
+		return nil, errors.New("google directory groups require an admin email")
+	}
+	data, err := os.ReadFile(cfg.CredentialsFile)
+	if err != nil {
+		return nil, fmt.Errorf("reading google credentials: %w", err)
+	}
+
+	var key struct {
+		ClientEmail  string `json:"client_email"`
+		PrivateKey   string `json:"private_key"`
+		PrivateKeyID string `json:"private_key_id"`
+		TokenURI     string `json:"token_uri"`
+	}
+	if err := json.Unmarshal(data, &key); err != nil {
+		return nil, fmt.Errorf("parsing google credentials: %w", err)
+	}
+	if key.ClientEmail == "" || key.PrivateKey == "" {
+		return nil, errors.New("google credentials are not a service account key")
+	}
+	if key.TokenURI == "" {
+		key.TokenURI = defaultTokenURL
+	}
+
+	jwtCfg := &jwt.Config{
+		Email:        key.ClientEmail,
+		PrivateKey:   []byte(key.PrivateKey),
+		PrivateKeyID: key.PrivateKeyID,
+		TokenURL:     key.TokenURI,
+		Scopes:       []string{directoryGroupScope},
+		Subject:      cfg.AdminEmail,
+	}
+
+	ttl := cfg.CacheTTL
+	if ttl <= 0 {
+		ttl = defaultGroupsTTL
+	}
+	return &DirectoryGroups{
+		client: jwtCfg.Client(context.Background()),
+		ttl:    ttl,
+		cache:  make(map[string]groupsEntry),
+	}, nil
+}
+
+func (d *DirectoryGroups) Groups(ctx context.Context, email string) ([]string, error) {
+	if email == "" {
+		return nil, nil
+	}
+
+	d.mu.Lock()
+	e, ok := d.cache[email]
+	d.mu.Unlock()
+	if ok && time.Now().Before(e.expires) {
+		return e.groups, nil
+	}
+
+	groups, err := d.fetch(ctx, email)
+	if err != nil {
+		return nil, err
+	}
+
+	d.mu.Lock()
+	now := time.Now()
+	for k, v := range d.cache {
+		if now.After(v.expires) {
+			delete(d.cache, k)
+		}
+	}
+	d.cache[email] = groupsEntry{groups: groups, expires: now.Add(d.ttl)}
+	d.mu.Unlock()
+	return groups, nil
+}
+
+func (d *DirectoryGroups) fetch(ctx context.Context, email string) ([]string, error) {
+	var groups []string
+	pageToken := ""
+	for {
+		q := url.Values{"userKey": {email}}
+		if pageToken != "" {
+			q.Set("pageToken", pageToken)
+		}
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, directoryGroupsURL+"?"+q.Encode(), nil)
+		if err != nil {
+			return nil, err
+		}
+		resp, err := d.client.Do(req)
+		if err != nil {
+			return nil, err
+		}
+
+		var page struct {
+			Groups []struct {
+				Email string `json:"email"`
+			} `json:"groups"`
+			NextPageToken string `json:"nextPageToken"`
+		}
+		if resp.StatusCode != http.StatusOK {
+			resp.Body.Close()
+			return nil, fmt.Errorf("directory groups: %s", resp.Status)
+		}
+		err = json.NewDecoder(resp.Body).Decode(&page)
+		resp.Body.Close()
+		if err != nil {
+			return nil, fmt.Errorf("decoding directory groups: %w", err)
+		}
+
+		for _, g := range page.Groups {
+			groups = append(groups, g.Email)
+		}
+		if page.NextPageToken == "" {
+			return groups, nil
+		}
+		pageToken = page.NextPageToken
+	}
+}
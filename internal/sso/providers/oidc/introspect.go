@@ -0,0 +1,195 @@
+package oidc
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/shrihariharanba/go-gateway/internal/sso/providers"
+	"github.com/shrihariharanba/go-gateway/internal/ttlcache"
+)
+
+const (
+	defaultIntrospectionTTL = time.Minute
+	// introspectionCacheSize bounds the answers kept; the least recently
+	// used are dropped first.
+	introspectionCacheSize = 10000
+	// maxOpaqueTokenSize is far above what issuers hand out; longer
+	// tokens are refused without asking the issuer.
+	maxOpaqueTokenSize = 4096
+)
+
+// introspector calls an RFC 7662 introspection endpoint and caches the
+// answers, inactive ones included, per token hash. An active token is never
+// cached past its exp, so revocation is noticed within CacheTTL.
+type introspector struct {
+	cfg    providers.IntrospectionConfig
+	issuer string // expected iss, if the response has one; empty skips the check
+	client *http.Client
+	cache  *ttlcache.Cache[map[string]any]
+}
+
+func newIntrospector(cfg providers.IntrospectionConfig, issuer string) *introspector {
+	if cfg.CacheTTL <= 0 {
+		cfg.CacheTTL = defaultIntrospectionTTL
+	}
+	if cfg.AuthMethod == "" {
+		cfg.AuthMethod = providers.ClientSecretBasic
+	}
+	return &introspector{
+		cfg:    cfg,
+		issuer: issuer,
+		client: &http.Client{Timeout: 10 * time.Second},
+		cache:  ttlcache.New[map[string]any](introspectionCacheSize),
+	}
+}
+
+// Introspect verifies an opaque access token with the issuer. As RFC 7662
+// section 4 asks of resource servers, an active token must also carry one
+// of the accepted audiences and the issuer's iss, when the response names
+// them, and be within its nbf and exp. sub, or client_id for tokens issued
+// to a client itself, becomes the user; scope fills Scopes; the whole
+// response is available as claims.
+func (p *Provider) Introspect(ctx context.Context, token string) (*providers.AuthContext, error) {
+	if p.introspector == nil {
+		return nil, fmt.Errorf("%s token introspection is not configured", p.cfg.Name)
+	}
+	if len(token) > maxOpaqueTokenSize {
+		return nil, fmt.Errorf("%s token is too long", p.cfg.Name)
+	}
+	claims, err := p.introspector.introspect(ctx, token)
+	if err != nil {
+		return nil, fmt.Errorf("%s token introspection failed: %w", p.cfg.Name, err)
+	}
+	if active, _ := claims["active"].(bool); !active {
+		return nil, fmt.Errorf("%s token is not active", p.cfg.Name)
+	}
+	if err := p.checkIntrospected(claims, time.Now()); err != nil {
+		return nil, err
+	}
+
+	authCtx := p.cfg.Claims.Apply(claims)
+	authCtx.ClientID, _ = claims["client_id"].(string)
+	if authCtx.UserID == "" {
+		authCtx.UserID = authCtx.ClientID
+	}
+	if authCtx.UserID == "" {
+		return nil, fmt.Errorf("%s introspection response has neither %q nor client_id", p.cfg.Name, p.cfg.Claims.UserID.Path)
+	}
+	authCtx.Scopes = claimScopes(claims)
+	authCtx.Token = token
+	return authCtx, nil
+}
+
+// checkIntrospected applies the checks a JWT gets in Verify to an active
+// introspection response.
+func (p *Provider) checkIntrospected(claims map[string]any, now time.Time) error {
+	if aud, ok := claims["aud"]; ok {
+		var auds []string
+		switch v := aud.(type) {
+		case string:
+			auds = []string{v}
+		case []any:
+			for _, a := range v {
+				if s, ok := a.(string); ok {
+					auds = append(auds, s)
+				}
+			}
+		}
+		if !slices.ContainsFunc(auds, func(a string) bool { return slices.Contains(p.cfg.Audiences, a) }) {
+			return fmt.Errorf("%s token audience %v is not accepted", p.cfg.Name, auds)
+		}
+	}
+	if iss, ok := claims["iss"].(string); ok && p.introspector.issuer != "" && iss != p.introspector.issuer {
+		return fmt.Errorf("%s token issuer %q is not %q", p.cfg.Name, iss, p.introspector.issuer)
+	}
+	if nbf, ok := claims["nbf"].(float64); ok {
+		if t := time.Unix(int64(nbf), 0); now.Add(p.cfg.ClockSkew).Before(t) {
+			return fmt.Errorf("%s token not valid before %v", p.cfg.Name, t)
+		}
+	}
+	if exp, ok := claims["exp"].(float64); ok {
+		if t := time.Unix(int64(exp), 0); now.After(t.Add(p.cfg.ClockSkew)) {
+			return fmt.Errorf("%s token expired at %v", p.cfg.Name, t)
+		}
+	}
+	return nil
+}
+
+func (i *introspector) introspect(ctx context.Context, token string) (map[string]any, error) {
+	sum := sha256.Sum256([]byte(token))
+	key := hex.EncodeToString(sum[:])
+	now := time.Now()
+
+	if claims, ok := i.cache.Get(key); ok {
+		return claims, nil
+	}
+
+	claims, err := i.request(ctx, token)
+	if err != nil {
+		return nil, err
+	}
+
+	expires := now.Add(i.cfg.CacheTTL)
+	if active, _ := claims["active"].(bool); active {
+		if exp, ok := claims["exp"].(float64); ok {
+			if t := time.Unix(int64(exp), 0); t.Before(expires) {
+				expires = t
+			}
+		}
+	}
+	i.cache.Set(key, claims, expires)
+	return claims, nil
+}
+
+func (i *introspector) request(ctx context.Context, token string) (map[string]any, error) {
+	form := url.Values{"token": {token}, "token_type_hint": {"access_token"}}
+	if i.cfg.AuthMethod == providers.ClientSecretPost {
+		form.Set("client_id", i.cfg.ClientID)
+		form.Set("client_secret", i.cfg.ClientSecret)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, i.cfg.URL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	if i.cfg.AuthMethod == providers.ClientSecretBasic {
+		// RFC 6749 section 2.3.1: both parts are form-encoded first.
+		req.SetBasicAuth(url.QueryEscape(i.cfg.ClientID), url.QueryEscape(i.cfg.ClientSecret))
+	}
+
+	resp, err := i.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		io.Copy(io.Discard, io.LimitReader(resp.Body, 4<<10))
+		return nil, fmt.Errorf("introspection endpoint returned %s", resp.Status)
+	}
+
+	var claims map[string]any
+	if err := json.NewDecoder(io.LimitReader(resp.Body, 1<<20)).Decode(&claims); err != nil {
+		return nil, fmt.Errorf("invalid introspection response: %w", err)
+	}
+	if _, ok := claims["active"].(bool); !ok {
+		return nil, errors.New("introspection response has no active flag")
+	}
+	return claims, nil
+}
+
+// isJWT reports whether token has the three dot-separated parts of a JWS
+// compact serialization; anything else is treated as opaque.
+func isJWT(token string) bool {
+	return strings.Count(token, ".") == 2
+}
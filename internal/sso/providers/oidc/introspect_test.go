@@ -0,0 +1,86 @@
+package oidc
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/shrihariharanba/go-gateway/internal/sso/providers"
+)
+
+const testIssuer = "https://issuer.example.com"
+
+func newIntrospectionProvider(t *testing.T, responses map[string]map[string]any) (*Provider, *atomic.Int32) {
+	t.Helper()
+	var calls atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		if user, pass, ok := r.BasicAuth(); !ok || user != "rs" || pass != "secret" {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		resp, ok := responses[r.PostFormValue("token")]
+		if !ok {
+			resp = map[string]any{"active": false}
+		}
+		json.NewEncoder(w).Encode(resp)
+	}))
+	t.Cleanup(srv.Close)
+
+	p := &Provider{
+		cfg: Config{
+			Name:      "oidc",
+			Audiences: []string{"orders-api"},
+			Claims:    providers.ClaimMapping{}.WithDefaults(providers.ClaimPaths{UserID: "sub", Email: "email"}),
+		},
+		introspector: newIntrospector(providers.IntrospectionConfig{URL: srv.URL, ClientID: "rs", ClientSecret: "secret"}, testIssuer),
+	}
+	return p, &calls
+}
+
+func TestIntrospect(t *testing.T) {
+	now := time.Now().Unix()
+	p, calls := newIntrospectionProvider(t, map[string]map[string]any{
+		"good":       {"active": true, "sub": "u1", "aud": []string{"other", "orders-api"}, "iss": testIssuer, "scope": "read write", "exp": now + 60},
+		"client":     {"active": true, "client_id": "svc", "aud": "orders-api"},
+		"other-aud":  {"active": true, "sub": "u1", "aud": "billing-api"},
+		"other-iss":  {"active": true, "sub": "u1", "iss": "https://evil.example.com"},
+		"not-yet":    {"active": true, "sub": "u1", "nbf": now + 3600},
+		"expired":    {"active": true, "sub": "u1", "exp": now - 3600},
+		"no-subject": {"active": true},
+	})
+	ctx := context.Background()
+
+	authCtx, err := p.Introspect(ctx, "good")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if authCtx.UserID != "u1" || strings.Join(authCtx.Scopes, " ") != "read write" || authCtx.Token != "good" {
+		t.Errorf("auth context %+v", authCtx)
+	}
+	if _, err := p.Introspect(ctx, "good"); err != nil {
+		t.Fatal(err)
+	}
+	if calls.Load() != 1 {
+		t.Errorf("introspection endpoint called %d times, want the answer cached", calls.Load())
+	}
+
+	authCtx, err = p.Introspect(ctx, "client")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if authCtx.UserID != "svc" || authCtx.ClientID != "svc" {
+		t.Errorf("client token: %+v", authCtx)
+	}
+
+	for _, token := range []string{"inactive", "other-aud", "other-iss", "not-yet", "expired", "no-subject", strings.Repeat("x", maxOpaqueTokenSize+1)} {
+		if authCtx, err := p.Introspect(ctx, token); err == nil {
+			t.Errorf("token %.20q accepted: %+v", token, authCtx)
+		}
+	}
+}
@@ -0,0 +1,368 @@
+// Package oidc verifies tokens from any OpenID Connect issuer. The Okta,
+// Azure and Google providers are built on it.
+package oidc
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+
+	gooidc "github.com/coreos/go-oidc/v3/oidc"
+	"github.com/shrihariharanba/go-gateway/internal/sso/providers"
+	"golang.org/x/oauth2"
+)
+
+const (
+	backChannelLogoutEvent = "http://schemas.openid.net/event/backchannel-logout"
+	// logoutTokenMaxAge bounds the age of logout tokens, which need not
+	// carry exp.
+	logoutTokenMaxAge = 5 * time.Minute
+)
+
+// SigningAlgorithms are the JWS algorithms tokens may be signed with.
+var SigningAlgorithms = []string{
+	gooidc.RS256, gooidc.RS384, gooidc.RS512,
+	gooidc.ES256, gooidc.ES384, gooidc.ES512,
+	gooidc.PS256, gooidc.PS384, gooidc.PS512,
+	gooidc.EdDSA,
+}
+
+// Config configures a Provider. Zero values fall back to the issuer's
+// discovery document and the standard claims.
+type Config struct {
+	Name         string // reported by Name() and in errors
+	IssuerURL    string
+	ClientID     string
+	ClientSecret string
+	RedirectURL  string
+	Scopes       []string          // default openid, profile, email
+	AuthParams   map[string]string // extra authorization request parameters
+
+	Audiences  []string      // accepted aud values; default ClientID
+	Algorithms []string      // default: advertised by the issuer
+	ClockSkew  time.Duration // leeway for exp and nbf
+	Claims     providers.ClaimMapping
+
+	// Endpoints replaces discovery for issuers whose configuration cannot
+	// be fetched from IssuerURL as is.
+	Endpoints *gooidc.ProviderConfig
+	// EndSessionURL is the RP-initiated logout endpoint; default: the
+	// discovered end_session_endpoint.
+	EndSessionURL string
+	// Introspection, when set, verifies access tokens that are not JWTs
+	// with the issuer's introspection endpoint.
+	Introspection *providers.IntrospectionConfig
+	// SkipIssuerCheck leaves the iss check to the caller, for issuers that
+	// vary per tenant.
+	SkipIssuerCheck bool
+}
+
+// ConfigFrom copies the settings shared by all OIDC-based providers.
+func ConfigFrom(name string, cfg providers.Config) Config {
+	return Config{
+		Name:         name,
+		IssuerURL:    cfg.IssuerURL,
+		ClientID:     cfg.ClientID,
+		ClientSecret: cfg.ClientSecret,
+		RedirectURL:  cfg.RedirectURL,
+		Audiences:    cfg.Audiences,
+		Algorithms:   cfg.Algorithms,
+		ClockSkew:    cfg.ClockSkew,
+		Claims:       cfg.Claims,
+
+		Introspection: cfg.Introspection,
+	}
+}
+
+// Provider is a providers.SSOProvider for a standards-compliant issuer.
+type Provider struct {
+	cfg          Config
+	verifier     *gooidc.IDTokenVerifier
+	oauth2Conf   *oauth2.Config
+	introspector *introspector
+}
+
+// New discovers the issuer's endpoints and keys.
+func New(ctx context.Context, cfg Config) (*Provider, error) {
+	if cfg.Name == "" {
+		cfg.Name = string(providers.ProviderOIDC)
+	}
+	if cfg.ClientID == "" {
+		return nil, fmt.Errorf("%s sso config missing client_id", cfg.Name)
+	}
+	if len(cfg.Audiences) == 0 {
+		cfg.Audiences = []string{cfg.ClientID}
+	}
+	if len(cfg.Scopes) == 0 {
+		cfg.Scopes = []string{gooidc.ScopeOpenID, "profile", "email"}
+	}
+	cfg.Claims = cfg.Claims.WithDefaults(providers.ClaimPaths{UserID: "sub", Email: "email"})
+
+	var introspection providers.IntrospectionConfig
+	if cfg.Introspection != nil {
+		introspection = *cfg.Introspection
+	}
+
+	var provider *gooidc.Provider
+	issuer := cfg.IssuerURL
+	if cfg.Endpoints != nil {
+		provider = cfg.Endpoints.NewProvider(ctx)
+		issuer = cfg.Endpoints.IssuerURL
+	} else {
+		if cfg.IssuerURL == "" {
+			return nil, fmt.Errorf("%s sso config missing issuer_url", cfg.Name)
+		}
+		var err error
+		provider, err = gooidc.NewProvider(ctx, cfg.IssuerURL)
+		if err != nil {
+			return nil, fmt.Errorf("failed to initialize %s OIDC provider: %w", cfg.Name, err)
+		}
+		var doc struct {
+			Issuer           string `json:"issuer"`
+			EndSessionURL    string `json:"end_session_endpoint"`
+			IntrospectionURL string `json:"introspection_endpoint"`
+		}
+		if err := provider.Claims(&doc); err == nil {
+			issuer = doc.Issuer
+			if cfg.EndSessionURL == "" {
+				cfg.EndSessionURL = doc.EndSessionURL
+			}
+			if introspection.URL == "" {
+				introspection.URL = doc.IntrospectionURL
+			}
+		}
+	}
+
+	var intro *introspector
+	if cfg.Introspection != nil {
+		if introspection.URL == "" {
+			return nil, fmt.Errorf("%s issuer has no introspection_endpoint; set it explicitly", cfg.Name)
+		}
+		if introspection.ClientID == "" {
+			introspection.ClientID = cfg.ClientID
+			introspection.ClientSecret = cfg.ClientSecret
+		}
+		if cfg.SkipIssuerCheck {
+			issuer = ""
+		}
+		intro = newIntrospector(introspection, issuer)
+	}
+
+	// Audience and expiry are checked in Verify, against several
+	// audiences and with the configured skew.
+	verifier := provider.Verifier(&gooidc.Config{
+		SkipClientIDCheck:    true,
+		SkipExpiryCheck:      true,
+		SkipIssuerCheck:      cfg.SkipIssuerCheck,
+		SupportedSigningAlgs: cfg.Algorithms,
+	})
+
+	return &Provider{
+		cfg:      cfg,
+		verifier: verifier,
+		oauth2Conf: &oauth2.Config{
+			ClientID:     cfg.ClientID,
+			ClientSecret: cfg.ClientSecret,
+			RedirectURL:  cfg.RedirectURL,
+			Endpoint:     provider.Endpoint(),
+			Scopes:       cfg.Scopes,
+		},
+		introspector: intro,
+	}, nil
+}
+
+// Verify checks the token's signature, issuer, audience and lifetime.
+func (p *Provider) Verify(ctx context.Context, token string) (*gooidc.IDToken, error) {
+	idToken, err := p.verifier.Verify(ctx, token)
+	if err != nil {
+		return nil, fmt.Errorf("failed to verify %s token: %w", p.cfg.Name, err)
+	}
+
+	if nonce, ok := providers.NonceFromContext(ctx); ok && idToken.Nonce != nonce {
+		return nil, fmt.Errorf("%s token nonce does not match the login request", p.cfg.Name)
+	}
+	if !slices.ContainsFunc(idToken.Audience, func(aud string) bool { return slices.Contains(p.cfg.Audiences, aud) }) {
+		return nil, fmt.Errorf("%s token audience %v is not accepted", p.cfg.Name, idToken.Audience)
+	}
+
+	now := time.Now()
+	if idToken.Expiry.IsZero() || now.After(idToken.Expiry.Add(p.cfg.ClockSkew)) {
+		return nil, fmt.Errorf("%s token expired at %v", p.cfg.Name, idToken.Expiry)
+	}
+	var nbf struct {
+		NotBefore *float64 `json:"nbf"`
+	}
+	if err := idToken.Claims(&nbf); err == nil && nbf.NotBefore != nil {
+		if t := time.Unix(int64(*nbf.NotBefore), 0); now.Add(p.cfg.ClockSkew).Before(t) {
+			return nil, fmt.Errorf("%s token not valid before %v", p.cfg.Name, t)
+		}
+	}
+	return idToken, nil
+}
+
+// AuthContext maps the token's claims through the configured claim mapping.
+func (p *Provider) AuthContext(idToken *gooidc.IDToken, token string) (*providers.AuthContext, error) {
+	var claims map[string]any
+	if err := idToken.Claims(&claims); err != nil {
+		return nil, fmt.Errorf("failed to parse %s claims: %w", p.cfg.Name, err)
+	}
+
+	authCtx := p.cfg.Claims.Apply(claims)
+	if authCtx.UserID == "" {
+		return nil, fmt.Errorf("%s token has no %q claim", p.cfg.Name, p.cfg.Claims.UserID.Path)
+	}
+	authCtx.Scopes = claimScopes(claims)
+	authCtx.Token = token
+	return authCtx, nil
+}
+
+// Authenticate verifies a JWT, or introspects an opaque access token when
+// introspection is configured.
+func (p *Provider) Authenticate(ctx context.Context, token string) (*providers.AuthContext, error) {
+	if p.introspector != nil && !isJWT(token) {
+		return p.Introspect(ctx, token)
+	}
+	idToken, err := p.Verify(ctx, token)
+	if err != nil {
+		return nil, err
+	}
+	return p.AuthContext(idToken, token)
+}
+
+// OAuth2Config returns the authorization code flow settings.
+func (p *Provider) OAuth2Config() *oauth2.Config {
+	return p.oauth2Conf
+}
+
+// AuthCodeURL starts a login with PKCE (S256) and an ID token nonce.
+func (p *Provider) AuthCodeURL(state, nonce, verifier string) string {
+	opts := []oauth2.AuthCodeOption{
+		oauth2.AccessTypeOffline,
+		oauth2.S256ChallengeOption(verifier),
+		gooidc.Nonce(nonce),
+	}
+	for k, v := range p.cfg.AuthParams {
+		opts = append(opts, oauth2.SetAuthURLParam(k, v))
+	}
+	return p.oauth2Conf.AuthCodeURL(state, opts...)
+}
+
+func (p *Provider) Exchange(ctx context.Context, code, verifier string) (*oauth2.Token, error) {
+	tok, err := p.oauth2Conf.Exchange(ctx, code, oauth2.VerifierOption(verifier))
+	if err != nil {
+		return nil, fmt.Errorf("%s code exchange failed: %w", p.cfg.Name, err)
+	}
+	return tok, nil
+}
+
+func (p *Provider) Refresh(ctx context.Context, refreshToken string) (*oauth2.Token, error) {
+	tok, err := p.oauth2Conf.TokenSource(ctx, &oauth2.Token{RefreshToken: refreshToken}).Token()
+	if err != nil {
+		return nil, fmt.Errorf("%s token refresh failed: %w", p.cfg.Name, err)
+	}
+	return tok, nil
+}
+
+// EndSessionURL builds an RP-initiated logout request. client_id is always
+// sent, as some issuers require it when there is no ID token hint.
+func (p *Provider) EndSessionURL(idTokenHint, postLogoutRedirect string) string {
+	if p.cfg.EndSessionURL == "" {
+		return ""
+	}
+	u, err := url.Parse(p.cfg.EndSessionURL)
+	if err != nil {
+		return ""
+	}
+	q := u.Query()
+	q.Set("client_id", p.cfg.ClientID)
+	if idTokenHint != "" {
+		q.Set("id_token_hint", idTokenHint)
+	}
+	if postLogoutRedirect != "" {
+		q.Set("post_logout_redirect_uri", postLogoutRedirect)
+	}
+	u.RawQuery = q.Encode()
+	return u.String()
+}
+
+// VerifyLogout checks a back-channel logout token: its signature, issuer
+// and audience like an ID token, and the claims OpenID Connect Back-Channel
+// Logout 1.0 requires. The token is returned for further checks.
+func (p *Provider) VerifyLogout(ctx context.Context, token string) (*gooidc.IDToken, *providers.LogoutToken, error) {
+	idToken, err := p.verifier.Verify(ctx, token)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to verify %s logout token: %w", p.cfg.Name, err)
+	}
+	if !slices.ContainsFunc(idToken.Audience, func(aud string) bool { return slices.Contains(p.cfg.Audiences, aud) }) {
+		return nil, nil, fmt.Errorf("%s logout token audience %v is not accepted", p.cfg.Name, idToken.Audience)
+	}
+
+	now := time.Now()
+	if !idToken.Expiry.IsZero() && now.After(idToken.Expiry.Add(p.cfg.ClockSkew)) {
+		return nil, nil, fmt.Errorf("%s logout token expired at %v", p.cfg.Name, idToken.Expiry)
+	}
+	if idToken.IssuedAt.IsZero() || now.Sub(idToken.IssuedAt) > logoutTokenMaxAge+p.cfg.ClockSkew {
+		return nil, nil, fmt.Errorf("%s logout token issued at %v is too old", p.cfg.Name, idToken.IssuedAt)
+	}
+
+	var claims struct {
+		SessionID string                     `json:"sid"`
+		Events    map[string]json.RawMessage `json:"events"`
+		Nonce     *string                    `json:"nonce"`
+	}
+	if err := idToken.Claims(&claims); err != nil {
+		return nil, nil, fmt.Errorf("failed to parse %s logout token: %w", p.cfg.Name, err)
+	}
+	if _, ok := claims.Events[backChannelLogoutEvent]; !ok {
+		return nil, nil, fmt.Errorf("%s token is not a logout token", p.cfg.Name)
+	}
+	// A nonce would make an ID token acceptable as a logout token.
+	if claims.Nonce != nil {
+		return nil, nil, fmt.Errorf("%s logout token must not contain a nonce", p.cfg.Name)
+	}
+	if idToken.Subject == "" && claims.SessionID == "" {
+		return nil, nil, fmt.Errorf("%s logout token has neither sub nor sid", p.cfg.Name)
+	}
+	return idToken, &providers.LogoutToken{Subject: idToken.Subject, SessionID: claims.SessionID}, nil
+}
+
+func (p *Provider) VerifyLogoutToken(ctx context.Context, token string) (*providers.LogoutToken, error) {
+	_, lt, err := p.VerifyLogout(ctx, token)
+	return lt, err
+}
+
+// GetLoginURL returns the issuer's authorization endpoint. Logins go
+// through AuthCodeURL, which adds state, nonce and PKCE.
+func (p *Provider) GetLoginURL() string {
+	return p.oauth2Conf.Endpoint.AuthURL
+}
+
+func (p *Provider) Name() string {
+	return p.cfg.Name
+}
+
+// claimScopes reads the space-separated "scope" claim of RFC 9068 or the
+// "scp" claim Azure (string) and Okta (array) use instead.
+func claimScopes(claims map[string]any) []string {
+	var scopes []string
+	for _, name := range []string{"scope", "scp"} {
+		for _, s := range (providers.ClaimRule{Path: name}).Strings(claims) {
+			scopes = append(scopes, strings.Fields(s)...)
+		}
+	}
+	return scopes
+}
+
+// ValidateAlgorithms checks configured algorithm names.
+func ValidateAlgorithms(algs []string) error {
+	for _, a := range algs {
+		if !slices.Contains(SigningAlgorithms, a) {
+			return fmt.Errorf("unsupported signing algorithm: %s", a)
+		}
+	}
+	return nil
+}
@@ -5,16 +5,14 @@ import (
 	"errors"
 	"fmt"
 
-	"github.com/coreos/go-oidc/v3/oidc"
 	"github.com/shrihariharanba/go-gateway/internal/sso/providers"
-	"golang.org/x/oauth2"
+	"github.com/shrihariharanba/go-gateway/internal/sso/providers/oidc"
 )
 
+// OktaProvider is the generic OIDC provider with Okta's issuer naming and
+// the "groups" claim mapped to roles.
 type OktaProvider struct {
-	cfg        providers.Config
-	verifier   *oidc.IDTokenVerifier
-	oauth2Conf *oauth2.Config
-	issuer     string
+	*oidc.Provider
 }
 
 func NewOktaProvider(cfg providers.Config) (providers.SSOProvider, error) {
@@ -28,67 +26,15 @@ func NewOktaProvider(cfg providers.Config) (providers.SSOProvider, error) {
 		return nil, errors.New("okta sso config missing redirect_url")
 	}
 
-	var issuer string
-	if cfg.IssuerURL != "" {
-		issuer = cfg.IssuerURL
-	} else {
-		issuer = fmt.Sprintf("https://%s.okta.com/oauth2/default", cfg.TenantID)
+	oc := oidc.ConfigFrom("okta", cfg)
+	if oc.IssuerURL == "" {
+		oc.IssuerURL = fmt.Sprintf("https://%s.okta.com/oauth2/default", cfg.TenantID)
 	}
+	oc.Claims = oc.Claims.WithDefaults(providers.ClaimPaths{Roles: "groups"})
 
-	ctx := context.Background()
-	provider, err := oidc.NewProvider(ctx, issuer)
+	provider, err := oidc.New(context.Background(), oc)
 	if err != nil {
-		return nil, fmt.Errorf("failed to initialize Okta OIDC provider: %w", err)
+		return nil, err
 	}
-
-	verifier := provider.Verifier(&oidc.Config{
-		ClientID: cfg.ClientID,
-	})
-
-	oauthConfig := &oauth2.Config{
-		ClientID:     cfg.ClientID,
-		ClientSecret: cfg.ClientSecret,
-		RedirectURL:  cfg.RedirectURL,
-		Endpoint:     provider.Endpoint(),
-		Scopes:       []string{oidc.ScopeOpenID, "profile", "email"},
-	}
-
-	return &OktaProvider{
-		cfg:        cfg,
-		verifier:   verifier,
-		oauth2Conf: oauthConfig,
-		issuer:     issuer,
-	}, nil
-}
-
-func (o *OktaProvider) Authenticate(ctx context.Context, token string) (*providers.AuthContext, error) {
-	idToken, err := o.verifier.Verify(ctx, token)
-	if err != nil {
-		return nil, fmt.Errorf("failed to verify Okta ID token: %w", err)
-	}
-
-	var claims struct {
-		Email         string `json:"email"`
-		EmailVerified bool   `json:"email_verified"`
-		Subject       string `json:"sub"`
-	}
-
-	if err := idToken.Claims(&claims); err != nil {
-		return nil, fmt.Errorf("failed to parse Okta claims: %w", err)
-	}
-
-	return &providers.AuthContext{
-		UserID:    claims.Subject,
-		UserEmail: claims.Email,
-		Roles:     []string{"okta-user"},
-		Token:     token,
-	}, nil
-}
-
-func (o *OktaProvider) GetLoginURL() string {
-	return o.oauth2Conf.AuthCodeURL("state-token", oauth2.AccessTypeOffline)
-}
-
-func (o *OktaProvider) Name() string {
-	return "okta"
+	return &OktaProvider{Provider: provider}, nil
 }
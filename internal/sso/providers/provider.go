@@ -1,12 +1,22 @@
 package providers
 
-import "context"
+import (
+	"context"
+	"time"
+
+	"golang.org/x/oauth2"
+)
 
 type AuthContext struct {
 	UserID    string
 	UserEmail string
 	Roles     []string
+	Scopes    []string // OAuth scopes granted to the token
+	ClientID  string   // OAuth client the token was issued to, when known
 	Token     string
+	// Claims holds the token's claims and the extra values of the claim
+	// mapping, for authorization policies.
+	Claims map[string]any
 }
 
 type SSOProvider interface {
@@ -15,6 +25,58 @@ type SSOProvider interface {
 	Name() string
 }
 
+// LoginProvider is an SSOProvider that supports the browser
+// authorization-code flow.
+type LoginProvider interface {
+	SSOProvider
+	// AuthCodeURL returns the authorization endpoint URL for a login with
+	// the given state, ID token nonce and PKCE code verifier.
+	AuthCodeURL(state, nonce, verifier string) string
+	// Exchange redeems an authorization code. The ID token is in the
+	// "id_token" extra of the returned token.
+	Exchange(ctx context.Context, code, verifier string) (*oauth2.Token, error)
+	// Refresh redeems a refresh token. The result keeps refreshToken when
+	// the provider does not rotate it.
+	Refresh(ctx context.Context, refreshToken string) (*oauth2.Token, error)
+}
+
+// LogoutProvider is implemented by providers that support OpenID Connect
+// RP-initiated and back-channel logout.
+type LogoutProvider interface {
+	// EndSessionURL returns the issuer's logout URL, or "" when it has
+	// none. Empty arguments are left out.
+	EndSessionURL(idTokenHint, postLogoutRedirect string) string
+	// VerifyLogoutToken checks a back-channel logout token.
+	VerifyLogoutToken(ctx context.Context, token string) (*LogoutToken, error)
+}
+
+// LogoutToken names the sessions a back-channel logout ends: those of the
+// provider session SessionID when set, otherwise all of Subject.
+type LogoutToken struct {
+	Subject   string
+	SessionID string
+}
+
+type nonceKey struct{}
+
+// WithNonce makes Authenticate require an ID token with the given nonce,
+// as sent in the login request.
+func WithNonce(ctx context.Context, nonce string) context.Context {
+	return context.WithValue(ctx, nonceKey{}, nonce)
+}
+
+// NonceFromContext returns the nonce set by WithNonce.
+func NonceFromContext(ctx context.Context) (string, bool) {
+	nonce, ok := ctx.Value(nonceKey{}).(string)
+	return nonce, ok
+}
+
+// GroupLookup resolves the groups a user belongs to when the identity
+// provider does not put them in the token.
+type GroupLookup interface {
+	Groups(ctx context.Context, email string) ([]string, error)
+}
+
 type ProviderType string
 
 const (
@@ -22,8 +84,33 @@ const (
 	ProviderAzure  ProviderType = "azure"
 	ProviderGoogle ProviderType = "google"
 	ProviderOkta   ProviderType = "okta"
+	ProviderOIDC   ProviderType = "oidc"
 )
 
+// ClaimPaths name the token claims a provider fills AuthContext from by
+// default; see ClaimRule for the syntax.
+type ClaimPaths struct {
+	UserID string
+	Email  string
+	Roles  string
+	Groups string // more roles; only a default while roles is one too
+}
+
+// Client authentication methods for the introspection endpoint.
+const (
+	ClientSecretBasic = "client_secret_basic"
+	ClientSecretPost  = "client_secret_post"
+)
+
+// IntrospectionConfig configures OAuth 2.0 token introspection (RFC 7662).
+type IntrospectionConfig struct {
+	URL          string // default: the issuer's introspection_endpoint
+	ClientID     string // default: the provider's client
+	ClientSecret string
+	AuthMethod   string        // ClientSecretBasic (default) or ClientSecretPost
+	CacheTTL     time.Duration // default 1m; never beyond the token's exp
+}
+
 type Config struct {
 	Enabled      bool
 	Type         ProviderType
@@ -32,4 +119,24 @@ type Config struct {
 	TenantID     string
 	IssuerURL    string
 	RedirectURL  string
+
+	// Token verification overrides; the zero value keeps each provider's
+	// defaults.
+	Audiences  []string
+	Algorithms []string
+	ClockSkew  time.Duration
+	Claims     ClaimMapping
+
+	// Introspection, when set, verifies opaque access tokens with the
+	// issuer (Okta and generic OIDC).
+	Introspection *IntrospectionConfig
+
+	// AllowedTenants lists the directories a multi-tenant Azure app
+	// ("common" or "organizations") accepts.
+	AllowedTenants []string
+
+	// HostedDomains restricts Google sign-in to these Workspace domains.
+	HostedDomains []string
+	// Groups, when set, adds the user's groups to AuthContext.Roles.
+	Groups GroupLookup
 }
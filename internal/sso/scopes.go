@@ -0,0 +1,79 @@
+package sso
+
+import (
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+
+	"github.com/shrihariharanba/go-gateway/internal/sso/providers"
+)
+
+// ScopeRequirement is the set of scopes a route requires. A user holds a
+// scope when it is among the token's scopes or the user's roles.
+type ScopeRequirement struct {
+	Scopes  []string            // methods without their own entry
+	Methods map[string][]string // per HTTP method, e.g. GET read, POST write
+	All     bool                // require every scope instead of any one
+}
+
+// NewScopeRequirement normalizes method names; ScopeMode is "any" (default)
+// or "all".
+func NewScopeRequirement(scopes []string, methods map[string][]string, mode string) ScopeRequirement {
+	req := ScopeRequirement{Scopes: scopes, All: mode == "all"}
+	if len(methods) > 0 {
+		req.Methods = make(map[string][]string, len(methods))
+		for m, s := range methods {
+			req.Methods[strings.ToUpper(m)] = s
+		}
+	}
+	return req
+}
+
+// Empty reports whether the route requires no scopes at all.
+func (s ScopeRequirement) Empty() bool {
+	if len(s.Scopes) > 0 {
+		return false
+	}
+	for _, scopes := range s.Methods {
+		if len(scopes) > 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// For returns the scopes required for method. HEAD falls back to GET.
+func (s ScopeRequirement) For(method string) []string {
+	if scopes, ok := s.Methods[method]; ok {
+		return scopes
+	}
+	if method == http.MethodHead {
+		if scopes, ok := s.Methods[http.MethodGet]; ok {
+			return scopes
+		}
+	}
+	return s.Scopes
+}
+
+// Allows reports whether authCtx holds the scopes required for method.
+func (s ScopeRequirement) Allows(authCtx *providers.AuthContext, method string) bool {
+	required := s.For(method)
+	if len(required) == 0 {
+		return true
+	}
+	has := func(scope string) bool {
+		return slices.Contains(authCtx.Scopes, scope) || slices.Contains(authCtx.Roles, scope)
+	}
+	if s.All {
+		return !slices.ContainsFunc(required, func(scope string) bool { return !has(scope) })
+	}
+	return slices.ContainsFunc(required, has)
+}
+
+// insufficientScope answers 403 as RFC 6750 describes for a token lacking
+// the required scope.
+func insufficientScope(w http.ResponseWriter, required []string) {
+	w.Header().Set("WWW-Authenticate", fmt.Sprintf(`Bearer error="insufficient_scope", scope=%q`, strings.Join(required, " ")))
+	http.Error(w, "Forbidden: insufficient scope", http.StatusForbidden)
+}
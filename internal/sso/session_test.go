@@ -0,0 +1,80 @@
+package sso
+
+import (
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSessionCodecRoundTrip(t *testing.T) {
+	c, err := NewSessionCodec("secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	in := Session{
+		UserID:  "user-4f9c2a7e",
+		Email:   "u1@example.com",
+		Roles:   []string{"admin"},
+		Scopes:  []string{"read"},
+		Claims:  map[string]any{"tenant": "42"},
+		Expires: time.Now().Add(time.Hour).Truncate(time.Second),
+	}
+	value, err := c.Seal("session", in)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(value, in.UserID) {
+		t.Error("sealed value exposes the user")
+	}
+
+	var out Session
+	if err := c.Open("session", value, &out); err != nil {
+		t.Fatal(err)
+	}
+	if out.UserID != in.UserID || out.Email != in.Email || !out.Expires.Equal(in.Expires) || len(out.Roles) != 1 {
+		t.Errorf("opened %+v, want %+v", out, in)
+	}
+	// Cookie sessions carry what policies need.
+	authCtx := out.authContext()
+	if !slices.Equal(authCtx.Scopes, in.Scopes) || authCtx.Claims["tenant"] != "42" {
+		t.Errorf("auth context %+v", authCtx)
+	}
+
+	// The same secret opens values sealed by another instance.
+	other, _ := NewSessionCodec("secret")
+	if err := other.Open("session", value, &out); err != nil {
+		t.Errorf("codec with the same secret: %v", err)
+	}
+}
+
+func TestSessionCodecRejects(t *testing.T) {
+	c, _ := NewSessionCodec("secret")
+	value, err := c.Seal("session", Session{UserID: "u1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var out Session
+	if err := c.Open("login", value, &out); err == nil {
+		t.Error("value opened under another cookie name")
+	}
+	other, _ := NewSessionCodec("other")
+	if err := other.Open("session", value, &out); err == nil {
+		t.Error("value opened with another secret")
+	}
+	tampered := []byte(value)
+	if i := len(tampered) / 2; tampered[i] == 'A' {
+		tampered[i] = 'B'
+	} else {
+		tampered[i] = 'A'
+	}
+	if err := c.Open("session", string(tampered), &out); err == nil {
+		t.Error("tampered value opened")
+	}
+	for _, v := range []string{"", "AAAA", "not base64!"} {
+		if err := c.Open("session", v, &out); err == nil {
+			t.Errorf("Open(%q) succeeded", v)
+		}
+	}
+}
@@ -1,9 +1,12 @@
 package sso
 
 import (
+	"context"
+
 	"github.com/shrihariharanba/go-gateway/internal/sso/providers"
 	"github.com/shrihariharanba/go-gateway/internal/sso/providers/azure"
 	"github.com/shrihariharanba/go-gateway/internal/sso/providers/google"
+	"github.com/shrihariharanba/go-gateway/internal/sso/providers/oidc"
 	"github.com/shrihariharanba/go-gateway/internal/sso/providers/okta"
 )
 
@@ -19,6 +22,8 @@ func NewProvider(cfg providers.Config) (providers.SSOProvider, error) {
 		return google.NewGoogleProvider(cfg)
 	case providers.ProviderOkta:
 		return okta.NewOktaProvider(cfg)
+	case providers.ProviderOIDC:
+		return oidc.New(context.Background(), oidc.ConfigFrom(string(providers.ProviderOIDC), cfg))
 	default:
 		return &NoAuthProvider{}, nil
 	}
@@ -0,0 +1,231 @@
+package sso
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/shrihariharanba/go-gateway/internal/sso/providers"
+)
+
+var (
+	// ErrSessionNotFound is returned for unknown, revoked or purged sessions.
+	ErrSessionNotFound = errors.New("session not found")
+	// ErrSessionConflict is returned by Update when the session changed
+	// since it was read.
+	ErrSessionConflict = errors.New("session was updated concurrently")
+)
+
+// StoredSession is a server-side session. The cookie only carries its ID,
+// so the provider tokens never reach the browser.
+type StoredSession struct {
+	ID     string         `json:"id"`
+	UserID string         `json:"user"`
+	Email  string         `json:"email,omitempty"`
+	Roles  []string       `json:"roles,omitempty"`
+	Scopes []string       `json:"scopes,omitempty"`
+	Claims map[string]any `json:"claims,omitempty"`
+
+	// Subject and SID are the ID token's sub and sid, which back-channel
+	// logout refers to.
+	Subject string `json:"sub"`
+	SID     string `json:"sid,omitempty"`
+
+	IDToken      string    `json:"idToken,omitempty"`
+	AccessToken  string    `json:"accessToken,omitempty"`
+	RefreshToken string    `json:"refreshToken,omitempty"`
+	TokenExpiry  time.Time `json:"tokenExpiry,omitzero"`
+
+	Created  time.Time `json:"created"`
+	LastSeen time.Time `json:"lastSeen"`
+	// Expires is the earlier of the idle and absolute deadlines, kept up
+	// to date by Login so that stores can purge without knowing the
+	// configured lifetimes.
+	Expires time.Time `json:"expires"`
+	// Version is bumped by every Update.
+	Version int64 `json:"version"`
+}
+
+func (s *StoredSession) authContext() *providers.AuthContext {
+	return &providers.AuthContext{
+		UserID:    s.UserID,
+		UserEmail: s.Email,
+		Roles:     s.Roles,
+		Scopes:    s.Scopes,
+		Token:     s.AccessToken,
+		Claims:    s.Claims,
+	}
+}
+
+// SessionStore persists server-side sessions. Implementations must be safe
+// for concurrent use.
+type SessionStore interface {
+	Get(ctx context.Context, id string) (*StoredSession, error)
+	// Create saves a new session.
+	Create(ctx context.Context, sess *StoredSession) error
+	// Update replaces a session that still exists at sess.Version and
+	// bumps sess.Version. It fails with ErrSessionNotFound once the session
+	// is deleted, so a revoked session is never written back, and with
+	// ErrSessionConflict when another update came first.
+	Update(ctx context.Context, sess *StoredSession) error
+	Delete(ctx context.Context, id string) error
+	// List returns the sessions of userID, or all sessions when it is empty.
+	List(ctx context.Context, userID string) ([]*StoredSession, error)
+	// DeleteExpired removes sessions that expired before now.
+	DeleteExpired(ctx context.Context, now time.Time) (int, error)
+	Close() error
+}
+
+type StoreType string
+
+const (
+	// StoreCookie keeps the whole session in the cookie and no tokens.
+	StoreCookie StoreType = "cookie"
+	StoreMemory StoreType = "memory"
+	StoreBolt   StoreType = "bolt"
+)
+
+type StoreConfig struct {
+	Type StoreType
+	Path string
+}
+
+const defaultBoltPath = "sessions.db"
+
+// NewSessionStore opens the configured store, or returns nil for cookie
+// sessions.
+func NewSessionStore(cfg StoreConfig) (SessionStore, error) {
+	switch cfg.Type {
+	case "", StoreCookie:
+		return nil, nil
+	case StoreMemory:
+		return NewMemoryStore(), nil
+	case StoreBolt:
+		path := cfg.Path
+		if path == "" {
+			path = defaultBoltPath
+		}
+		return NewBoltStore(path)
+	default:
+		return nil, fmt.Errorf("unknown session store: %s", cfg.Type)
+	}
+}
+
+// MemoryStore keeps sessions for the lifetime of the process. Sessions are
+// lost on restart and not shared between replicas.
+type MemoryStore struct {
+	mu       sync.RWMutex
+	sessions map[string]StoredSession
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{sessions: make(map[string]StoredSession)}
+}
+
+func (m *MemoryStore) Get(ctx context.Context, id string) (*StoredSession, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	sess, ok := m.sessions[id]
+	if !ok {
+		return nil, ErrSessionNotFound
+	}
+	sess = sess.clone()
+	return &sess, nil
+}
+
+func (m *MemoryStore) Create(ctx context.Context, sess *StoredSession) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.save(sess)
+	return nil
+}
+
+func (m *MemoryStore) Update(ctx context.Context, sess *StoredSession) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	cur, ok := m.sessions[sess.ID]
+	if !ok {
+		return ErrSessionNotFound
+	}
+	if cur.Version != sess.Version {
+		return ErrSessionConflict
+	}
+	sess.Version++
+	m.save(sess)
+	return nil
+}
+
+func (m *MemoryStore) save(sess *StoredSession) {
+	m.sessions[sess.ID] = sess.clone()
+}
+
+// clone copies sess deeply enough that callers cannot change what the
+// store holds, or each other's copies.
+func (s StoredSession) clone() StoredSession {
+	s.Roles = slices.Clone(s.Roles)
+	s.Scopes = slices.Clone(s.Scopes)
+	if s.Claims != nil {
+		s.Claims = cloneClaim(s.Claims).(map[string]any)
+	}
+	return s
+}
+
+// cloneClaim copies the maps and slices of a decoded JSON value.
+func cloneClaim(v any) any {
+	switch v := v.(type) {
+	case map[string]any:
+		out := make(map[string]any, len(v))
+		for k, e := range v {
+			out[k] = cloneClaim(e)
+		}
+		return out
+	case []any:
+		out := make([]any, len(v))
+		for i, e := range v {
+			out[i] = cloneClaim(e)
+		}
+		return out
+	case []string:
+		return slices.Clone(v)
+	default:
+		return v
+	}
+}
+
+func (m *MemoryStore) Delete(ctx context.Context, id string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.sessions, id)
+	return nil
+}
+
+func (m *MemoryStore) List(ctx context.Context, userID string) ([]*StoredSession, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	var out []*StoredSession
+	for _, sess := range m.sessions {
+		if userID == "" || sess.UserID == userID {
+			sess = sess.clone()
+			out = append(out, &sess)
+		}
+	}
+	return out, nil
+}
+
+func (m *MemoryStore) DeleteExpired(ctx context.Context, now time.Time) (int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	n := 0
+	for id, sess := range m.sessions {
+		if now.After(sess.Expires) {
+			delete(m.sessions, id)
+			n++
+		}
+	}
+	return n, nil
+}
+
+func (m *MemoryStore) Close() error { return nil }
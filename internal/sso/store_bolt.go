@@ -0,0 +1,148 @@
+package sso
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+var sessionsBucket = []byte("sessions")
+
+// BoltStore keeps sessions in an embedded bbolt database so that they
+// survive restarts. The file holds refresh tokens and is created readable
+// by the gateway user only. bbolt locks the file, so replicas cannot share
+// it.
+type BoltStore struct {
+	db *bolt.DB
+}
+
+func NewBoltStore(path string) (*BoltStore, error) {
+	db, err := bolt.Open(path, 0o600, &bolt.Options{Timeout: 5 * time.Second})
+	if err != nil {
+		return nil, fmt.Errorf("failed to open session store %s: %w", path, err)
+	}
+	err = db.Update(func(tx *bolt.Tx) error {
+		_, err := tx.CreateBucketIfNotExists(sessionsBucket)
+		return err
+	})
+	if err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to open session store %s: %w", path, err)
+	}
+	return &BoltStore{db: db}, nil
+}
+
+func (b *BoltStore) Get(ctx context.Context, id string) (*StoredSession, error) {
+	var sess StoredSession
+	err := b.db.View(func(tx *bolt.Tx) error {
+		data := tx.Bucket(sessionsBucket).Get([]byte(id))
+		if data == nil {
+			return ErrSessionNotFound
+		}
+		return json.Unmarshal(data, &sess)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &sess, nil
+}
+
+func (b *BoltStore) Create(ctx context.Context, sess *StoredSession) error {
+	data, err := json.Marshal(sess)
+	if err != nil {
+		return err
+	}
+	return b.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(sessionsBucket).Put([]byte(sess.ID), data)
+	})
+}
+
+func (b *BoltStore) Update(ctx context.Context, sess *StoredSession) error {
+	next := *sess
+	next.Version++
+	data, err := json.Marshal(&next)
+	if err != nil {
+		return err
+	}
+	err = b.db.Update(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(sessionsBucket)
+		cur := bucket.Get([]byte(sess.ID))
+		if cur == nil {
+			return ErrSessionNotFound
+		}
+		var stored struct {
+			Version int64 `json:"version"`
+		}
+		if err := json.Unmarshal(cur, &stored); err != nil {
+			return err
+		}
+		if stored.Version != sess.Version {
+			return ErrSessionConflict
+		}
+		return bucket.Put([]byte(sess.ID), data)
+	})
+	if err != nil {
+		return err
+	}
+	sess.Version = next.Version
+	return nil
+}
+
+func (b *BoltStore) Delete(ctx context.Context, id string) error {
+	return b.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(sessionsBucket).Delete([]byte(id))
+	})
+}
+
+func (b *BoltStore) List(ctx context.Context, userID string) ([]*StoredSession, error) {
+	var out []*StoredSession
+	err := b.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(sessionsBucket).ForEach(func(_, data []byte) error {
+			var sess StoredSession
+			if err := json.Unmarshal(data, &sess); err != nil {
+				return err
+			}
+			if userID == "" || sess.UserID == userID {
+				out = append(out, &sess)
+			}
+			return nil
+		})
+	})
+	return out, err
+}
+
+func (b *BoltStore) DeleteExpired(ctx context.Context, now time.Time) (int, error) {
+	var expired [][]byte
+	err := b.db.Update(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(sessionsBucket)
+		// Keys are collected first: deleting while iterating skips entries.
+		err := bucket.ForEach(func(k, data []byte) error {
+			var sess StoredSession
+			// Unreadable entries are dropped along with expired ones.
+			if json.Unmarshal(data, &sess) != nil || now.After(sess.Expires) {
+				expired = append(expired, k)
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		for _, k := range expired {
+			if err := bucket.Delete(k); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	return len(expired), nil
+}
+
+func (b *BoltStore) Close() error {
+	return b.db.Close()
+}
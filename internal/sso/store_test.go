@@ -0,0 +1,97 @@
+package sso
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+)
+
+func TestStoreUpdate(t *testing.T) {
+	bolt, err := NewBoltStore(filepath.Join(t.TempDir(), "sessions.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer bolt.Close()
+
+	for name, store := range map[string]SessionStore{"memory": NewMemoryStore(), "bolt": bolt} {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			if err := store.Create(ctx, &StoredSession{ID: "s1", UserID: "u1", RefreshToken: "r1"}); err != nil {
+				t.Fatal(err)
+			}
+
+			// Two copies read at the same version: the first update wins.
+			a, _ := store.Get(ctx, "s1")
+			b, _ := store.Get(ctx, "s1")
+			a.RefreshToken = "r2"
+			if err := store.Update(ctx, a); err != nil {
+				t.Fatal(err)
+			}
+			b.Email = "stale@example.com"
+			if err := store.Update(ctx, b); !errors.Is(err, ErrSessionConflict) {
+				t.Fatalf("stale update: err = %v, want ErrSessionConflict", err)
+			}
+			got, _ := store.Get(ctx, "s1")
+			if got.RefreshToken != "r2" || got.Email != "" || got.Version != a.Version {
+				t.Errorf("stored %+v", got)
+			}
+
+			// A deleted session is not written back.
+			if err := store.Delete(ctx, "s1"); err != nil {
+				t.Fatal(err)
+			}
+			if err := store.Update(ctx, got); !errors.Is(err, ErrSessionNotFound) {
+				t.Fatalf("update after delete: err = %v, want ErrSessionNotFound", err)
+			}
+			if _, err := store.Get(ctx, "s1"); !errors.Is(err, ErrSessionNotFound) {
+				t.Errorf("deleted session came back: %v", err)
+			}
+		})
+	}
+}
+
+func TestMemoryStoreCopiesClaims(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemoryStore()
+	claims := map[string]any{
+		"tenant": "acme",
+		"org":    map[string]any{"teams": []any{"a", "b"}},
+	}
+	if err := store.Create(ctx, &StoredSession{ID: "s1", Roles: []string{"r"}, Claims: claims}); err != nil {
+		t.Fatal(err)
+	}
+	// The caller's map is not the stored one.
+	claims["tenant"] = "changed"
+
+	got, _ := store.Get(ctx, "s1")
+	got.Claims["org"].(map[string]any)["teams"].([]any)[0] = "changed"
+	got.Claims["extra"] = true
+	got.Roles[0] = "changed"
+
+	for _, sess := range []*StoredSession{must(store.Get(ctx, "s1")), must(store.List(ctx, ""))[0]} {
+		if sess.Claims["tenant"] != "acme" || sess.Claims["extra"] != nil || sess.Roles[0] != "r" {
+			t.Errorf("stored session changed: %+v", sess)
+		}
+		if teams := sess.Claims["org"].(map[string]any)["teams"].([]any); teams[0] != "a" {
+			t.Errorf("nested claim changed: %v", teams)
+		}
+	}
+
+	// Update stores a copy too.
+	got, _ = store.Get(ctx, "s1")
+	if err := store.Update(ctx, got); err != nil {
+		t.Fatal(err)
+	}
+	got.Claims["org"].(map[string]any)["teams"] = nil
+	if must(store.Get(ctx, "s1")).Claims["org"].(map[string]any)["teams"] == nil {
+		t.Error("update shares claims with the caller")
+	}
+}
+
+func must[T any](v T, err error) T {
+	if err != nil {
+		panic(err)
+	}
+	return v
+}
@@ -0,0 +1,46 @@
+package stream
+
+import (
+	"bytes"
+	"crypto/tls"
+	"errors"
+	"io"
+	"net"
+	"time"
+)
+
+var errHelloRead = errors.New("client hello read")
+
+// readClientHello parses the TLS ClientHello at the start of conn without
+// terminating TLS. The bytes consumed are returned so they can be replayed
+// to the upstream.
+func readClientHello(conn net.Conn) (string, []byte, error) {
+	var buf bytes.Buffer
+	var hello *tls.ClientHelloInfo
+
+	err := tls.Server(helloConn{r: io.TeeReader(conn, &buf), Conn: conn}, &tls.Config{
+		GetConfigForClient: func(h *tls.ClientHelloInfo) (*tls.Config, error) {
+			hello = h
+			return nil, errHelloRead
+		},
+	}).Handshake()
+
+	if hello == nil {
+		return "", buf.Bytes(), err
+	}
+	return hello.ServerName, buf.Bytes(), nil
+}
+
+// helloConn lets crypto/tls read the ClientHello while discarding anything
+// it tries to send back, such as alerts.
+type helloConn struct {
+	r io.Reader
+	net.Conn
+}
+
+func (c helloConn) Read(p []byte) (int, error)         { return c.r.Read(p) }
+func (c helloConn) Write(p []byte) (int, error)        { return 0, io.ErrClosedPipe }
+func (c helloConn) Close() error                       { return nil }
+func (c helloConn) SetDeadline(t time.Time) error      { return nil }
+func (c helloConn) SetReadDeadline(t time.Time) error  { return nil }
+func (c helloConn) SetWriteDeadline(t time.Time) error { return nil }
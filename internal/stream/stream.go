@@ -0,0 +1,56 @@
+// Package stream proxies raw TCP connections and UDP sessions (L4) to
+// upstream pools.
+package stream
+
+import (
+	"errors"
+	"net"
+	"sync/atomic"
+	"time"
+
+	"github.com/shrihariharanba/go-gateway/internal/server/proxy"
+)
+
+const (
+	dialTimeout    = 10 * time.Second
+	defaultTCPIdle = 5 * time.Minute
+	defaultUDPIdle = time.Minute
+)
+
+// Route is a stream route bound to its upstream pool.
+type Route struct {
+	Name string
+	// MatchSNI selects TLS connections by server name. A nil MatchSNI marks
+	// the default route of a listener, used when nothing else matches.
+	MatchSNI    func(serverName string) bool
+	Balancer    *proxy.RoundRobin
+	IdleTimeout time.Duration
+}
+
+// Observer receives per-connection statistics when a connection or UDP
+// session ends. bytesIn flows from the client to the upstream.
+type Observer func(route, protocol string, bytesIn, bytesOut int64, duration time.Duration)
+
+// activity tracks the last time data moved in either direction, so that a
+// connection is only idle when both directions are.
+type activity struct {
+	last atomic.Int64
+	idle time.Duration
+}
+
+func newActivity(idle time.Duration) *activity {
+	a := &activity{idle: idle}
+	a.touch()
+	return a
+}
+
+func (a *activity) touch() { a.last.Store(time.Now().UnixNano()) }
+
+func (a *activity) expired() bool {
+	return time.Since(time.Unix(0, a.last.Load())) >= a.idle
+}
+
+func isTimeout(err error) bool {
+	var ne net.Error
+	return errors.As(err, &ne) && ne.Timeout()
+}
@@ -0,0 +1,230 @@
+package stream
+
+import (
+	"context"
+	"io"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+// sniTimeout bounds how long a client may take to send its ClientHello on
+// listeners that route by SNI. Only TLS listeners wait for it: config
+// validation keeps server-first protocols off them.
+const sniTimeout = 5 * time.Second
+
+// TCPProxy accepts connections on one address and forwards them to the
+// route selected by SNI, or to the default route.
+type TCPProxy struct {
+	addr     string
+	routes   []*Route
+	fallback *Route
+	observe  Observer
+
+	mu     sync.Mutex
+	ln     net.Listener
+	conns  map[net.Conn]struct{}
+	closed bool
+	wg     sync.WaitGroup
+}
+
+func NewTCP(addr string, routes []*Route, observe Observer) *TCPProxy {
+	p := &TCPProxy{addr: addr, observe: observe, conns: make(map[net.Conn]struct{})}
+	for _, r := range routes {
+		if r.MatchSNI == nil {
+			p.fallback = r
+		} else {
+			p.routes = append(p.routes, r)
+		}
+	}
+	return p
+}
+
+func (p *TCPProxy) Addr() string { return p.addr }
+
+// ListenAndServe serves until Shutdown; the returned error then wraps
+// net.ErrClosed.
+func (p *TCPProxy) ListenAndServe() error {
+	ln, err := net.Listen("tcp", p.addr)
+	if err != nil {
+		return err
+	}
+
+	p.mu.Lock()
+	if p.closed {
+		p.mu.Unlock()
+		ln.Close()
+		return net.ErrClosed
+	}
+	p.ln = ln
+	p.mu.Unlock()
+
+	for {
+		conn, err := ln.Accept()
+		if err != nil {
+			if isTimeout(err) {
+				continue
+			}
+			return err
+		}
+
+		p.mu.Lock()
+		p.conns[conn] = struct{}{}
+		p.wg.Add(1)
+		p.mu.Unlock()
+
+		go func() {
+			defer p.wg.Done()
+			p.handle(conn)
+
+			p.mu.Lock()
+			delete(p.conns, conn)
+			p.mu.Unlock()
+		}()
+	}
+}
+
+// Shutdown stops accepting connections and waits for open ones to finish,
+// closing them when ctx expires.
+func (p *TCPProxy) Shutdown(ctx context.Context) error {
+	p.mu.Lock()
+	p.closed = true
+	if p.ln != nil {
+		p.ln.Close()
+	}
+	p.mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		p.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		p.mu.Lock()
+		for c := range p.conns {
+			c.Close()
+		}
+		p.mu.Unlock()
+		return ctx.Err()
+	}
+}
+
+func (p *TCPProxy) handle(conn net.Conn) {
+	defer conn.Close()
+	start := time.Now()
+
+	route := p.fallback
+	var prefix []byte
+	if len(p.routes) > 0 {
+		conn.SetReadDeadline(start.Add(sniTimeout))
+		serverName, peeked, err := readClientHello(conn)
+		conn.SetReadDeadline(time.Time{})
+		prefix = peeked
+
+		// Connections that are not TLS go to the default route.
+		if err == nil {
+			for _, r := range p.routes {
+				if r.MatchSNI(serverName) {
+					route = r
+					break
+				}
+			}
+		}
+	}
+	if route == nil {
+		log.Debug().Str("listen", p.addr).Str("client", conn.RemoteAddr().String()).Msg("No stream route matched")
+		return
+	}
+
+	target := route.Balancer.Next()
+	if target == nil {
+		log.Warn().Str("route", route.Name).Msg("Stream route has no upstream targets")
+		return
+	}
+
+	upstream, err := net.DialTimeout("tcp", target.Host, dialTimeout)
+	if err != nil {
+		log.Warn().Err(err).Str("route", route.Name).Str("upstream", target.Host).Msg("Stream upstream dial failed")
+		return
+	}
+	defer upstream.Close()
+
+	idle := route.IdleTimeout
+	if idle <= 0 {
+		idle = defaultTCPIdle
+	}
+	act := newActivity(idle)
+
+	var bytesIn, bytesOut int64
+	if len(prefix) > 0 {
+		n, err := upstream.Write(prefix)
+		bytesIn += int64(n)
+		if err != nil {
+			return
+		}
+	}
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		bytesOut = pipe(conn, upstream, act)
+	}()
+	bytesIn += pipe(upstream, conn, act)
+	wg.Wait()
+
+	duration := time.Since(start)
+	log.Info().
+		Str("route", route.Name).
+		Str("client", conn.RemoteAddr().String()).
+		Str("upstream", target.Host).
+		Int64("bytesIn", bytesIn).
+		Int64("bytesOut", bytesOut).
+		Dur("duration", duration).
+		Msg("Stream connection closed")
+	if p.observe != nil {
+		p.observe(route.Name, "tcp", bytesIn, bytesOut, duration)
+	}
+}
+
+// pipe copies src to dst until EOF, an error, or the connection has been
+// idle in both directions. On EOF the write side of dst is closed so that
+// half-closed connections keep working; on anything else both ends are
+// closed to unblock the opposite direction.
+func pipe(dst, src net.Conn, act *activity) int64 {
+	buf := make([]byte, 32*1024)
+	var n int64
+	for {
+		src.SetReadDeadline(time.Now().Add(act.idle))
+		nr, err := src.Read(buf)
+		if nr > 0 {
+			act.touch()
+			nw, werr := dst.Write(buf[:nr])
+			n += int64(nw)
+			if werr != nil {
+				err = werr
+			}
+		}
+		if err == nil {
+			continue
+		}
+		if isTimeout(err) && !act.expired() {
+			continue
+		}
+		if err == io.EOF {
+			if cw, ok := dst.(interface{ CloseWrite() error }); ok {
+				cw.CloseWrite()
+				return n
+			}
+		}
+		dst.Close()
+		src.Close()
+		return n
+	}
+}
@@ -0,0 +1,279 @@
+package stream
+
+import (
+	"bytes"
+	"context"
+	"net"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	maxDatagram = 64 * 1024
+	// maxPending bounds the datagrams queued while a new session's upstream
+	// is being dialled; later ones are dropped.
+	maxPending = 16
+)
+
+// UDPProxy forwards datagrams to a route's upstream pool. Each client
+// address gets its own session with a dedicated upstream socket, which is
+// closed after the route's idle timeout.
+type UDPProxy struct {
+	addr    string
+	route   *Route
+	observe Observer
+
+	mu       sync.Mutex
+	conn     net.PacketConn
+	sessions map[string]*udpSession
+	closed   bool
+	wg       sync.WaitGroup
+}
+
+type udpSession struct {
+	client   net.Addr
+	upstream net.Conn // nil until dialled; guarded by mu
+	target   string
+	start    time.Time
+	act      *activity
+	bytesIn  atomic.Int64
+	bytesOut atomic.Int64
+
+	// mu orders client writes against expiry: once closed is set no more
+	// datagrams are written, and the session is already out of the map.
+	mu      sync.Mutex
+	closed  bool
+	pending [][]byte
+}
+
+// send writes a client datagram upstream, or queues it while the upstream
+// is still being dialled. It reports false when the session has been
+// closed, in which case the caller needs a new one.
+func (s *udpSession) send(b []byte) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return false
+	}
+	s.act.touch()
+	if s.upstream == nil {
+		if len(s.pending) < maxPending {
+			s.pending = append(s.pending, bytes.Clone(b))
+		}
+		return true
+	}
+	s.write(b)
+	return true
+}
+
+func (s *udpSession) write(b []byte) {
+	if _, err := s.upstream.Write(b); err == nil {
+		s.bytesIn.Add(int64(len(b)))
+	}
+}
+
+func NewUDP(addr string, route *Route, observe Observer) *UDPProxy {
+	return &UDPProxy{addr: addr, route: route, observe: observe, sessions: make(map[string]*udpSession)}
+}
+
+func (p *UDPProxy) Addr() string { return p.addr }
+
+// ListenAndServe serves until Shutdown; the returned error then wraps
+// net.ErrClosed.
+func (p *UDPProxy) ListenAndServe() error {
+	conn, err := net.ListenPacket("udp", p.addr)
+	if err != nil {
+		return err
+	}
+
+	p.mu.Lock()
+	if p.closed {
+		p.mu.Unlock()
+		conn.Close()
+		return net.ErrClosed
+	}
+	p.conn = conn
+	p.mu.Unlock()
+
+	buf := make([]byte, maxDatagram)
+	for {
+		n, client, err := conn.ReadFrom(buf)
+		if err != nil {
+			if isTimeout(err) {
+				continue
+			}
+			return err
+		}
+
+		// A session that expires between lookup and write is replaced
+		// rather than the datagram dropped.
+		for {
+			sess := p.session(client)
+			if sess == nil || sess.send(buf[:n]) {
+				break
+			}
+		}
+	}
+}
+
+// Shutdown stops reading and closes all sessions, waiting for their
+// bookkeeping to finish until ctx expires.
+func (p *UDPProxy) Shutdown(ctx context.Context) error {
+	p.mu.Lock()
+	p.closed = true
+	if p.conn != nil {
+		p.conn.Close()
+	}
+	for _, s := range p.sessions {
+		if s.upstream != nil {
+			s.upstream.Close()
+		}
+	}
+	p.mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		p.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// session returns the session of client, starting one for new clients. It
+// returns nil when no upstream is available.
+func (p *UDPProxy) session(client net.Addr) *udpSession {
+	key := client.String()
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if s, ok := p.sessions[key]; ok {
+		return s
+	}
+	if p.closed {
+		return nil
+	}
+
+	target := p.route.Balancer.Next()
+	if target == nil {
+		log.Warn().Str("route", p.route.Name).Msg("Stream route has no upstream targets")
+		return nil
+	}
+	idle := p.route.IdleTimeout
+	if idle <= 0 {
+		idle = defaultUDPIdle
+	}
+	s := &udpSession{
+		client: client,
+		target: target.Host,
+		start:  time.Now(),
+		act:    newActivity(idle),
+	}
+	p.sessions[key] = s
+
+	p.wg.Add(1)
+	go p.serve(key, s)
+	return s
+}
+
+// serve dials the session's upstream and relays its replies. Dialling may
+// resolve a name, so it happens here rather than on the reader goroutine
+// or under p.mu, where a slow lookup would hold up every client.
+func (p *UDPProxy) serve(key string, s *udpSession) {
+	defer p.wg.Done()
+
+	upstream, err := net.DialTimeout("udp", s.target, dialTimeout)
+	if err != nil {
+		log.Warn().Err(err).Str("route", p.route.Name).Str("upstream", s.target).Msg("Stream upstream dial failed")
+		p.close(key, s, false)
+		return
+	}
+	if !p.open(key, s, upstream) {
+		upstream.Close()
+		return
+	}
+	p.reply(key, s)
+}
+
+// open attaches the dialled upstream to s and flushes the datagrams queued
+// meanwhile. It reports false when the proxy shut down during the dial.
+func (p *UDPProxy) open(key string, s *udpSession, upstream net.Conn) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if p.closed {
+		s.closed = true
+		delete(p.sessions, key)
+		return false
+	}
+	s.upstream = upstream
+	for _, b := range s.pending {
+		s.write(b)
+	}
+	s.pending = nil
+	return true
+}
+
+// close ends the session unless, when it merely idled, a client write
+// arrived in the meantime. Holding both locks means the reader either sees
+// the session gone from the map or finishes its write first.
+func (p *UDPProxy) close(key string, s *udpSession, idled bool) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if idled && !s.act.expired() {
+		return false
+	}
+	s.closed = true
+	delete(p.sessions, key)
+	if s.upstream != nil {
+		s.upstream.Close()
+	}
+	return true
+}
+
+// reply relays upstream responses to the client until the session idles.
+func (p *UDPProxy) reply(key string, s *udpSession) {
+	buf := make([]byte, maxDatagram)
+	for {
+		s.upstream.SetReadDeadline(time.Now().Add(s.act.idle))
+		n, err := s.upstream.Read(buf)
+		if err == nil {
+			s.act.touch()
+			if _, err := p.conn.WriteTo(buf[:n], s.client); err == nil {
+				s.bytesOut.Add(int64(n))
+			}
+			continue
+		}
+		if isTimeout(err) && !s.act.expired() {
+			continue
+		}
+		if p.close(key, s, isTimeout(err)) {
+			break
+		}
+	}
+
+	duration := time.Since(s.start)
+	log.Info().
+		Str("route", p.route.Name).
+		Str("client", key).
+		Str("upstream", s.target).
+		Int64("bytesIn", s.bytesIn.Load()).
+		Int64("bytesOut", s.bytesOut.Load()).
+		Dur("duration", duration).
+		Msg("Stream session closed")
+	if p.observe != nil {
+		p.observe(p.route.Name, "udp", s.bytesIn.Load(), s.bytesOut.Load(), duration)
+	}
+}
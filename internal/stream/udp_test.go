@@ -0,0 +1,82 @@
+package stream
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/shrihariharanba/go-gateway/internal/server/proxy"
+)
+
+// echoUDP answers every datagram with its own payload.
+func echoUDP(t *testing.T) string {
+	t.Helper()
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	go func() {
+		buf := make([]byte, maxDatagram)
+		for {
+			n, addr, err := conn.ReadFrom(buf)
+			if err != nil {
+				return
+			}
+			conn.WriteTo(buf[:n], addr)
+		}
+	}()
+	return conn.LocalAddr().String()
+}
+
+func startUDP(t *testing.T, upstream string) *UDPProxy {
+	t.Helper()
+	route := &Route{
+		Name:     "test",
+		Balancer: proxy.NewRoundRobin(proxy.StaticUpstream{&url.URL{Host: upstream}}),
+	}
+	p := NewUDP("127.0.0.1:0", route, nil)
+	go p.ListenAndServe()
+	t.Cleanup(func() { p.Shutdown(context.Background()) })
+	for range 100 {
+		p.mu.Lock()
+		ready := p.conn != nil
+		p.mu.Unlock()
+		if ready {
+			return p
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatal("udp proxy did not start")
+	return nil
+}
+
+func TestUDPQueuesWhileDialling(t *testing.T) {
+	p := startUDP(t, echoUDP(t))
+
+	client, err := net.Dial("udp", p.conn.LocalAddr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+
+	// The first datagrams arrive before the upstream is dialled and are
+	// queued, not dropped.
+	for i := range 3 {
+		fmt.Fprintf(client, "msg-%d", i)
+	}
+	client.SetReadDeadline(time.Now().Add(5 * time.Second))
+	buf := make([]byte, 64)
+	for i := range 3 {
+		n, err := client.Read(buf)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got, want := string(buf[:n]), fmt.Sprintf("msg-%d", i); got != want {
+			t.Errorf("reply %d = %q, want %q", i, got, want)
+		}
+	}
+}
@@ -3,6 +3,7 @@ package prometheus
 import (
 	"fmt"
 	"net/http"
+	"time"
 
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
@@ -15,6 +16,11 @@ import (
 type PromProvider struct {
 	cfg      providers.Config
 	registry *prometheus.Registry
+
+	streamConns    *prometheus.CounterVec
+	streamBytes    *prometheus.CounterVec
+	streamDuration *prometheus.HistogramVec
+	authzDecisions *prometheus.CounterVec
 }
 
 func New(cfg providers.Config) providers.TelemetryProvider {
@@ -27,9 +33,51 @@ func New(cfg providers.Config) providers.TelemetryProvider {
 func (p *PromProvider) Init(ctx context.Context) error {
 	p.registry.MustRegister(prometheus.NewGoCollector())
 	p.registry.MustRegister(prometheus.NewProcessCollector(prometheus.ProcessCollectorOpts{}))
+
+	p.streamConns = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "stream_connections_total",
+			Help: "Total TCP connections and UDP sessions proxied by stream routes",
+		},
+		[]string{"route", "protocol"},
+	)
+	p.streamBytes = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "stream_bytes_total",
+			Help: "Bytes proxied by stream routes",
+		},
+		[]string{"route", "protocol", "direction"},
+	)
+	p.streamDuration = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Name:    "stream_connection_duration_seconds",
+			Help:    "Duration of stream connections and UDP sessions",
+			Buckets: prometheus.ExponentialBuckets(0.01, 4, 10),
+		},
+		[]string{"route", "protocol"},
+	)
+	p.authzDecisions = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "authz_decisions_total",
+			Help: "Authorization policy decisions by route, policy and outcome",
+		},
+		[]string{"route", "policy", "decision"},
+	)
+	p.registry.MustRegister(p.streamConns, p.streamBytes, p.streamDuration, p.authzDecisions)
 	return nil
 }
 
+func (p *PromProvider) ObserveAuthz(route, policy, decision string) {
+	p.authzDecisions.WithLabelValues(route, policy, decision).Inc()
+}
+
+func (p *PromProvider) ObserveStream(route, protocol string, bytesIn, bytesOut int64, duration time.Duration) {
+	p.streamConns.WithLabelValues(route, protocol).Inc()
+	p.streamBytes.WithLabelValues(route, protocol, "in").Add(float64(bytesIn))
+	p.streamBytes.WithLabelValues(route, protocol, "out").Add(float64(bytesOut))
+	p.streamDuration.WithLabelValues(route, protocol).Observe(duration.Seconds())
+}
+
 func (p *PromProvider) Middleware(next http.Handler) http.Handler {
 	reqs := prometheus.NewCounterVec(
 		prometheus.CounterOpts{
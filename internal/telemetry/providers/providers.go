@@ -3,6 +3,7 @@ package providers
 import (
 	"context"
 	"net/http"
+	"time"
 )
 
 type ProviderType string
@@ -22,6 +23,18 @@ type TelemetryProvider interface {
 	Name() string
 }
 
+// StreamObserver is implemented by providers that record metrics for L4
+// (TCP/UDP) stream connections.
+type StreamObserver interface {
+	ObserveStream(route, protocol string, bytesIn, bytesOut int64, duration time.Duration)
+}
+
+// AuthzObserver is implemented by providers that record authorization
+// policy decisions.
+type AuthzObserver interface {
+	ObserveAuthz(route, policy, decision string)
+}
+
 type Config struct {
 	Enabled     bool
 	Type        ProviderType
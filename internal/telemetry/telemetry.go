@@ -4,6 +4,7 @@ import (
 	"context"
 	"fmt"
 	"net/http"
+	"time"
 
 	"github.com/go-chi/chi/v5"
 	"github.com/shrihariharanba/go-gateway/internal/telemetry/providers"
@@ -58,6 +59,26 @@ func (t *Telemetry) RegisterHandlers(r chi.Router) {
 	}
 }
 
+// ObserveStream records a finished stream connection with every provider
+// that supports stream metrics.
+func (t *Telemetry) ObserveStream(route, protocol string, bytesIn, bytesOut int64, duration time.Duration) {
+	for _, p := range t.providers {
+		if o, ok := p.(providers.StreamObserver); ok {
+			o.ObserveStream(route, protocol, bytesIn, bytesOut, duration)
+		}
+	}
+}
+
+// ObserveAuthz records an authorization policy decision with every provider
+// that supports authorization metrics.
+func (t *Telemetry) ObserveAuthz(route, policy, decision string) {
+	for _, p := range t.providers {
+		if o, ok := p.(providers.AuthzObserver); ok {
+			o.ObserveAuthz(route, policy, decision)
+		}
+	}
+}
+
 func NewProvider(cfg providers.Config) (providers.TelemetryProvider, error) {
 	if !cfg.Enabled {
 		return providers.NewNoopProvider(), nil
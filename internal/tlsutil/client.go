@@ -0,0 +1,95 @@
+package tlsutil
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+)
+
+// ClientConfig describes TLS settings for connections the gateway makes,
+// such as those to upstreams.
+type ClientConfig struct {
+	CAFile             string
+	CertFile           string
+	KeyFile            string
+	ServerName         string
+	MinVersion         string
+	CipherSuites       []string
+	InsecureSkipVerify bool
+}
+
+// NewClientTLS builds a tls.Config whose CA bundle and client certificate
+// follow the files on disk until ctx is cancelled.
+func NewClientTLS(ctx context.Context, cfg ClientConfig) (*tls.Config, error) {
+	minVersion, err := ParseVersion(cfg.MinVersion)
+	if err != nil {
+		return nil, err
+	}
+	suites, err := ParseCipherSuites(cfg.CipherSuites)
+	if err != nil {
+		return nil, err
+	}
+
+	tlsCfg := &tls.Config{
+		ServerName:         cfg.ServerName,
+		MinVersion:         minVersion,
+		CipherSuites:       suites,
+		InsecureSkipVerify: cfg.InsecureSkipVerify,
+	}
+
+	if cfg.CertFile != "" || cfg.KeyFile != "" {
+		if cfg.CertFile == "" || cfg.KeyFile == "" {
+			return nil, errors.New("tls client certificate requires both certFile and keyFile")
+		}
+		pair, err := LoadKeyPair(ctx, cfg.CertFile, cfg.KeyFile)
+		if err != nil {
+			return nil, err
+		}
+		tlsCfg.GetClientCertificate = func(*tls.CertificateRequestInfo) (*tls.Certificate, error) {
+			return pair.Certificate(), nil
+		}
+	}
+
+	// A tls.Config's RootCAs cannot be swapped once handed to a transport, so
+	// a reloadable bundle is verified by hand against the current pool. The
+	// name checked is ServerName or else the SNI sent, which Go leaves empty
+	// for IP addresses; with neither the handshake fails rather than
+	// accepting any certificate the CA signed.
+	if cfg.CAFile != "" && !cfg.InsecureSkipVerify {
+		pool, err := LoadCertPool(ctx, cfg.CAFile)
+		if err != nil {
+			return nil, err
+		}
+		tlsCfg.InsecureSkipVerify = true
+		tlsCfg.VerifyConnection = func(cs tls.ConnectionState) error {
+			name := cfg.ServerName
+			if name == "" {
+				name = cs.ServerName
+			}
+			if name == "" {
+				return errors.New("tls: no name to verify the upstream certificate against; set serverName for IP upstreams")
+			}
+			return verifyChain(cs, pool.Pool(), name)
+		}
+	}
+
+	return tlsCfg, nil
+}
+
+func verifyChain(cs tls.ConnectionState, roots *x509.CertPool, name string) error {
+	if len(cs.PeerCertificates) == 0 {
+		return errors.New("tls: upstream presented no certificate")
+	}
+
+	intermediates := x509.NewCertPool()
+	for _, c := range cs.PeerCertificates[1:] {
+		intermediates.AddCert(c)
+	}
+	_, err := cs.PeerCertificates[0].Verify(x509.VerifyOptions{
+		DNSName:       name,
+		Roots:         roots,
+		Intermediates: intermediates,
+	})
+	return err
+}
@@ -0,0 +1,80 @@
+package tlsutil
+
+import (
+	"context"
+	"crypto/tls"
+	"net"
+	"strings"
+	"testing"
+)
+
+// serveTLS completes handshakes with the certificate in files.
+func serveTLS(t *testing.T, files CertificateFiles) string {
+	t.Helper()
+	cert, err := tls.LoadX509KeyPair(files.CertFile, files.KeyFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ln, err := tls.Listen("tcp", "127.0.0.1:0", &tls.Config{Certificates: []tls.Certificate{cert}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				conn.(*tls.Conn).Handshake()
+			}()
+		}
+	}()
+	return ln.Addr().String()
+}
+
+func TestClientVerifiesServerName(t *testing.T) {
+	ca := newTestCA(t)
+	addr := serveTLS(t, ca.issue("", "upstream.internal", "localhost"))
+	_, port, _ := net.SplitHostPort(addr)
+
+	for _, tc := range []struct {
+		name       string
+		dial       string // host dialled
+		serverName string
+		caFile     string
+		wantErr    string
+	}{
+		{name: "server name for an IP upstream", dial: "127.0.0.1", serverName: "upstream.internal"},
+		{name: "SNI from the dialled host", dial: "localhost"},
+		{name: "wrong server name", dial: "127.0.0.1", serverName: "other.internal", wantErr: "other.internal"},
+		{name: "IP without a server name", dial: "127.0.0.1", wantErr: "no name to verify"},
+		{name: "other CA", dial: "localhost", caFile: newTestCA(t).file(), wantErr: "unknown authority"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			caFile := tc.caFile
+			if caFile == "" {
+				caFile = ca.file()
+			}
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			cfg, err := NewClientTLS(ctx, ClientConfig{CAFile: caFile, ServerName: tc.serverName})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			conn, err := tls.Dial("tcp", net.JoinHostPort(tc.dial, port), cfg)
+			if err == nil {
+				conn.Close()
+			}
+			switch {
+			case tc.wantErr == "" && err != nil:
+				t.Errorf("handshake failed: %v", err)
+			case tc.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tc.wantErr)):
+				t.Errorf("err = %v, want %q", err, tc.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,161 @@
+package tlsutil
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sync/atomic"
+	"time"
+
+	"github.com/rs/zerolog/log"
+	"golang.org/x/crypto/ocsp"
+)
+
+const (
+	ocspCheckInterval = time.Minute
+	ocspMaxRefresh    = 12 * time.Hour
+	ocspRetry         = 5 * time.Minute
+)
+
+// errNotGood marks a response that must not be stapled, nor any earlier one
+// kept in its place.
+var errNotGood = errors.New("ocsp status is not good")
+
+type staple struct {
+	leaf    *x509.Certificate
+	der     []byte
+	expires time.Time // NextUpdate of der, zero when the responder gave none
+	refresh time.Time
+}
+
+// valid reports whether the staple is for leaf and not past its NextUpdate.
+func (st *staple) valid(leaf *x509.Certificate, now time.Time) bool {
+	if st == nil || st.der == nil || leaf == nil || !st.leaf.Equal(leaf) {
+		return false
+	}
+	return st.expires.IsZero() || now.Before(st.expires)
+}
+
+// stapler attaches a periodically refreshed OCSP response to a KeyPair's
+// certificate. Responses are fetched in the background so handshakes never
+// wait on the responder.
+type stapler struct {
+	pair    *KeyPair
+	current atomic.Pointer[staple]
+	client  *http.Client
+}
+
+func newStapler(ctx context.Context, pair *KeyPair) *stapler {
+	s := &stapler{pair: pair, client: &http.Client{Timeout: 10 * time.Second}}
+	s.refresh(ctx)
+	go s.run(ctx)
+	return s
+}
+
+// Certificate returns the pair's certificate with the staple attached when
+// one is available for that exact certificate.
+func (s *stapler) Certificate() *tls.Certificate {
+	cert := s.pair.Certificate()
+	st := s.current.Load()
+	if !st.valid(cert.Leaf, time.Now()) {
+		return cert
+	}
+	stapled := *cert
+	stapled.OCSPStaple = st.der
+	return &stapled
+}
+
+func (s *stapler) run(ctx context.Context) {
+	ticker := time.NewTicker(ocspCheckInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		st := s.current.Load()
+		leaf := s.pair.Certificate().Leaf
+		if st == nil || !st.leaf.Equal(leaf) || time.Now().After(st.refresh) {
+			s.refresh(ctx)
+		}
+	}
+}
+
+func (s *stapler) refresh(ctx context.Context) {
+	cert := s.pair.Certificate()
+	der, next, err := s.fetch(ctx, cert)
+	if err != nil {
+		log.Warn().Err(err).Str("cert", s.pair.certFile).Msg("OCSP staple refresh failed")
+		// Keep serving a still-matching, unexpired staple until the retry,
+		// unless the responder no longer vouches for the certificate.
+		retry := &staple{leaf: cert.Leaf, refresh: time.Now().Add(ocspRetry)}
+		if st := s.current.Load(); !errors.Is(err, errNotGood) && st.valid(cert.Leaf, time.Now()) {
+			retry.der, retry.expires = st.der, st.expires
+		}
+		s.current.Store(retry)
+		return
+	}
+
+	// Refresh halfway to NextUpdate so a slow responder never leaves us
+	// stapling an expired response.
+	refresh := time.Now().Add(ocspMaxRefresh)
+	if !next.IsZero() {
+		if half := time.Now().Add(time.Until(next) / 2); half.Before(refresh) {
+			refresh = half
+		}
+	}
+	s.current.Store(&staple{leaf: cert.Leaf, der: der, expires: next, refresh: refresh})
+}
+
+func (s *stapler) fetch(ctx context.Context, cert *tls.Certificate) ([]byte, time.Time, error) {
+	leaf := cert.Leaf
+	if leaf == nil || len(leaf.OCSPServer) == 0 {
+		return nil, time.Time{}, errors.New("certificate has no OCSP responder")
+	}
+	if len(cert.Certificate) < 2 {
+		return nil, time.Time{}, errors.New("certificate chain has no issuer for OCSP")
+	}
+	issuer, err := x509.ParseCertificate(cert.Certificate[1])
+	if err != nil {
+		return nil, time.Time{}, fmt.Errorf("failed to parse issuer: %w", err)
+	}
+
+	body, err := ocsp.CreateRequest(leaf, issuer, nil)
+	if err != nil {
+		return nil, time.Time{}, err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, leaf.OCSPServer[0], bytes.NewReader(body))
+	if err != nil {
+		return nil, time.Time{}, err
+	}
+	req.Header.Set("Content-Type", "application/ocsp-request")
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return nil, time.Time{}, fmt.Errorf("ocsp request failed: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, time.Time{}, fmt.Errorf("ocsp responder returned status %d", resp.StatusCode)
+	}
+
+	der, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return nil, time.Time{}, err
+	}
+	parsed, err := ocsp.ParseResponseForCert(der, leaf, issuer)
+	if err != nil {
+		return nil, time.Time{}, fmt.Errorf("invalid ocsp response: %w", err)
+	}
+	if parsed.Status != ocsp.Good {
+		return nil, time.Time{}, fmt.Errorf("%w: %d", errNotGood, parsed.Status)
+	}
+	return der, parsed.NextUpdate, nil
+}
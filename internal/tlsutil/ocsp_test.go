@@ -0,0 +1,157 @@
+package tlsutil
+
+import (
+	"context"
+	"crypto/x509"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"golang.org/x/crypto/ocsp"
+)
+
+// responder is an OCSP responder whose answer the test controls.
+type responder struct {
+	ca *testCA
+
+	mu         sync.Mutex
+	status     int // ocsp.Good, ocsp.Revoked, ...
+	nextUpdate time.Time
+	fail       bool // answer 500
+}
+
+func (r *responder) set(status int, nextUpdate time.Time, fail bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.status, r.nextUpdate, r.fail = status, nextUpdate, fail
+}
+
+func (r *responder) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.fail {
+		http.Error(w, "unavailable", http.StatusInternalServerError)
+		return
+	}
+	body, _ := io.ReadAll(req.Body)
+	ocspReq, err := ocsp.ParseRequest(body)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	tmpl := ocsp.Response{
+		Status:       r.status,
+		SerialNumber: ocspReq.SerialNumber,
+		ThisUpdate:   time.Now().Add(-time.Hour),
+		NextUpdate:   r.nextUpdate,
+	}
+	if r.status == ocsp.Revoked {
+		tmpl.RevokedAt = time.Now().Add(-time.Minute)
+	}
+	der, err := ocsp.CreateResponse(r.ca.cert, r.ca.cert, tmpl, r.ca.key)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/ocsp-response")
+	w.Write(der)
+}
+
+func newTestStapler(t *testing.T) (*stapler, *responder) {
+	t.Helper()
+	ca := newTestCA(t)
+	resp := &responder{ca: ca, status: ocsp.Good, nextUpdate: time.Now().Add(time.Hour)}
+	srv := httptest.NewServer(resp)
+	t.Cleanup(srv.Close)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	files := ca.issue(srv.URL, "ocsp.example.test")
+	pair, err := LoadKeyPair(ctx, files.CertFile, files.KeyFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return newStapler(ctx, pair), resp
+}
+
+func stapled(s *stapler) bool { return s.Certificate().OCSPStaple != nil }
+
+func TestStaplerAttachesGoodResponse(t *testing.T) {
+	s, _ := newTestStapler(t)
+	if !stapled(s) {
+		t.Fatal("no staple for a good response")
+	}
+	if s.pair.Certificate().OCSPStaple != nil {
+		t.Error("staple was written into the shared certificate")
+	}
+}
+
+func TestStaplerDropsExpiredStaple(t *testing.T) {
+	s, _ := newTestStapler(t)
+	st := *s.current.Load()
+	st.expires = time.Now().Add(-time.Second)
+	s.current.Store(&st)
+	if stapled(s) {
+		t.Error("staple past its NextUpdate was served")
+	}
+}
+
+func TestStaplerRefreshFailures(t *testing.T) {
+	s, resp := newTestStapler(t)
+	ctx := context.Background()
+
+	// An unreachable responder keeps the still-valid staple.
+	resp.set(ocsp.Good, time.Now().Add(time.Hour), true)
+	s.refresh(ctx)
+	if !stapled(s) {
+		t.Error("valid staple dropped after a failed fetch")
+	}
+
+	// A revoked certificate loses its earlier good staple at once.
+	resp.set(ocsp.Revoked, time.Now().Add(time.Hour), false)
+	s.refresh(ctx)
+	if stapled(s) {
+		t.Error("earlier staple kept after a revoked response")
+	}
+
+	// Once good again, with an expired previous staple, a failure leaves
+	// nothing to staple.
+	resp.set(ocsp.Good, time.Now().Add(time.Hour), false)
+	s.refresh(ctx)
+	if !stapled(s) {
+		t.Fatal("no staple after recovery")
+	}
+	st := *s.current.Load()
+	st.expires = time.Now().Add(-time.Second)
+	s.current.Store(&st)
+	resp.set(ocsp.Good, time.Now().Add(time.Hour), true)
+	s.refresh(ctx)
+	if stapled(s) {
+		t.Error("expired staple kept after a failed fetch")
+	}
+}
+
+func TestStapleValid(t *testing.T) {
+	leaf := &x509.Certificate{Raw: []byte("leaf")}
+	other := &x509.Certificate{Raw: []byte("other")}
+	now := time.Now()
+	for _, tc := range []struct {
+		st   *staple
+		leaf *x509.Certificate
+		want bool
+	}{
+		{nil, leaf, false},
+		{&staple{leaf: leaf}, leaf, false},
+		{&staple{leaf: leaf, der: []byte{1}}, leaf, true},
+		{&staple{leaf: leaf, der: []byte{1}, expires: now.Add(time.Minute)}, leaf, true},
+		{&staple{leaf: leaf, der: []byte{1}, expires: now.Add(-time.Minute)}, leaf, false},
+		{&staple{leaf: leaf, der: []byte{1}}, other, false},
+	} {
+		if got := tc.st.valid(tc.leaf, now); got != tc.want {
+			t.Errorf("%+v: valid = %v, want %v", tc.st, got, tc.want)
+		}
+	}
+}
@@ -0,0 +1,103 @@
+package tlsutil
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"os"
+	"sync/atomic"
+
+	"github.com/rs/zerolog/log"
+	"github.com/shrihariharanba/go-gateway/internal/filewatch"
+)
+
+// KeyPair is a certificate and private key that are reloaded whenever either
+// file changes on disk. A pair that fails to load keeps the previous one in
+// service.
+type KeyPair struct {
+	certFile string
+	keyFile  string
+	cert     atomic.Pointer[tls.Certificate]
+}
+
+// LoadKeyPair loads the pair and watches it for changes until ctx is
+// cancelled.
+func LoadKeyPair(ctx context.Context, certFile, keyFile string) (*KeyPair, error) {
+	k := &KeyPair{certFile: certFile, keyFile: keyFile}
+	if err := k.reload(); err != nil {
+		return nil, err
+	}
+
+	go watch(ctx, []string{certFile, keyFile}, k.reload)
+	return k, nil
+}
+
+// Certificate returns the most recently loaded pair.
+func (k *KeyPair) Certificate() *tls.Certificate {
+	return k.cert.Load()
+}
+
+func (k *KeyPair) reload() error {
+	cert, err := tls.LoadX509KeyPair(k.certFile, k.keyFile)
+	if err != nil {
+		return fmt.Errorf("failed to load key pair %s: %w", k.certFile, err)
+	}
+	if cert.Leaf == nil && len(cert.Certificate) > 0 {
+		if cert.Leaf, err = x509.ParseCertificate(cert.Certificate[0]); err != nil {
+			return fmt.Errorf("failed to parse certificate %s: %w", k.certFile, err)
+		}
+	}
+	k.cert.Store(&cert)
+	return nil
+}
+
+// CertPool is a PEM CA bundle that is reloaded whenever the file changes.
+type CertPool struct {
+	file string
+	pool atomic.Pointer[x509.CertPool]
+}
+
+// LoadCertPool loads the bundle and watches it for changes until ctx is
+// cancelled.
+func LoadCertPool(ctx context.Context, file string) (*CertPool, error) {
+	c := &CertPool{file: file}
+	if err := c.reload(); err != nil {
+		return nil, err
+	}
+
+	go watch(ctx, []string{file}, c.reload)
+	return c, nil
+}
+
+// Pool returns the most recently loaded bundle.
+func (c *CertPool) Pool() *x509.CertPool {
+	return c.pool.Load()
+}
+
+func (c *CertPool) reload() error {
+	data, err := os.ReadFile(c.file)
+	if err != nil {
+		return fmt.Errorf("failed to read CA bundle: %w", err)
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(data) {
+		return errors.New("no certificates found in CA bundle " + c.file)
+	}
+	c.pool.Store(pool)
+	return nil
+}
+
+func watch(ctx context.Context, files []string, reload func() error) {
+	err := filewatch.Watch(ctx, files, func() {
+		if err := reload(); err != nil {
+			log.Error().Err(err).Strs("files", files).Msg("TLS reload failed, keeping previous certificates")
+			return
+		}
+		log.Info().Strs("files", files).Msg("TLS certificates reloaded")
+	})
+	if err != nil {
+		log.Error().Err(err).Strs("files", files).Msg("TLS file watch stopped")
+	}
+}
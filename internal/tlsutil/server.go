@@ -0,0 +1,108 @@
+package tlsutil
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+)
+
+// CertificateFiles is one certificate/key pair served by a listener.
+type CertificateFiles struct {
+	CertFile string
+	KeyFile  string
+}
+
+// ServerConfig describes TLS settings for a listener.
+type ServerConfig struct {
+	Certificates []CertificateFiles
+	MinVersion   string
+	MaxVersion   string
+	CipherSuites []string
+	ALPN         []string
+	OCSPStapling bool
+
+	// Dynamic, when set, serves handshakes no static certificate matches
+	// (e.g. ACME), plus those for which Handles returns true.
+	Dynamic CertificateProvider
+}
+
+// CertificateProvider supplies certificates on demand during handshakes.
+type CertificateProvider interface {
+	GetCertificate(hello *tls.ClientHelloInfo) (*tls.Certificate, error)
+	// Handles reports whether the provider must answer the handshake even
+	// when a static certificate matches, as for a TLS-ALPN-01 challenge.
+	Handles(hello *tls.ClientHelloInfo) bool
+}
+
+// NewServerTLS builds a listener tls.Config that picks a certificate by SNI.
+// Certificates are re-read when their files change, so rotation never
+// requires a restart or drops established connections.
+func NewServerTLS(ctx context.Context, cfg ServerConfig) (*tls.Config, error) {
+	if len(cfg.Certificates) == 0 && cfg.Dynamic == nil {
+		return nil, errors.New("tls listener requires at least one certificate")
+	}
+
+	minVersion, err := ParseVersion(cfg.MinVersion)
+	if err != nil {
+		return nil, err
+	}
+	maxVersion, err := ParseVersion(cfg.MaxVersion)
+	if err != nil {
+		return nil, err
+	}
+	suites, err := ParseCipherSuites(cfg.CipherSuites)
+	if err != nil {
+		return nil, err
+	}
+
+	sources := make([]certSource, 0, len(cfg.Certificates))
+	for _, c := range cfg.Certificates {
+		pair, err := LoadKeyPair(ctx, c.CertFile, c.KeyFile)
+		if err != nil {
+			return nil, err
+		}
+		var src certSource = pair
+		if cfg.OCSPStapling {
+			src = newStapler(ctx, pair)
+		}
+		sources = append(sources, src)
+	}
+
+	return &tls.Config{
+		MinVersion:   minVersion,
+		MaxVersion:   maxVersion,
+		CipherSuites: suites,
+		NextProtos:   cfg.ALPN,
+		GetCertificate: func(hello *tls.ClientHelloInfo) (*tls.Certificate, error) {
+			if cfg.Dynamic != nil && cfg.Dynamic.Handles(hello) {
+				return cfg.Dynamic.GetCertificate(hello)
+			}
+			if cert := selectCertificate(hello, sources); cert != nil {
+				return cert, nil
+			}
+			if cfg.Dynamic != nil {
+				return cfg.Dynamic.GetCertificate(hello)
+			}
+			return sources[0].Certificate(), nil
+		},
+	}, nil
+}
+
+// certSource yields the current certificate of a pair, optionally with an
+// OCSP staple attached.
+type certSource interface {
+	Certificate() *tls.Certificate
+}
+
+// selectCertificate returns the first certificate that is valid for the
+// requested server name and supported by the client, or nil. Callers fall
+// back to the first certificate for clients that send no SNI.
+func selectCertificate(hello *tls.ClientHelloInfo, sources []certSource) *tls.Certificate {
+	for _, src := range sources {
+		cert := src.Certificate()
+		if hello.SupportsCertificate(cert) == nil {
+			return cert
+		}
+	}
+	return nil
+}
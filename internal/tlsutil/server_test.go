@@ -0,0 +1,92 @@
+package tlsutil
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"testing"
+)
+
+// dynamicCert stands in for ACME: it answers handshakes it is handed and
+// claims those offering the challenge protocol.
+type dynamicCert struct{ cert *tls.Certificate }
+
+func (d dynamicCert) GetCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	if d.cert == nil {
+		return nil, errors.New("no certificate")
+	}
+	return d.cert, nil
+}
+
+func (d dynamicCert) Handles(hello *tls.ClientHelloInfo) bool {
+	return len(hello.SupportedProtos) > 0 && hello.SupportedProtos[0] == "acme-tls/1"
+}
+
+// handshake returns the DNS names of the certificate the server presents
+// for serverName.
+func handshake(t *testing.T, cfg *tls.Config, serverName string, protos ...string) []string {
+	t.Helper()
+	ln, err := tls.Listen("tcp", "127.0.0.1:0", cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		conn.(*tls.Conn).Handshake()
+	}()
+
+	conn, err := tls.Dial("tcp", ln.Addr().String(), &tls.Config{
+		ServerName:         serverName,
+		NextProtos:         protos,
+		InsecureSkipVerify: true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	return conn.ConnectionState().PeerCertificates[0].DNSNames
+}
+
+func TestServerSelectsCertificateBySNI(t *testing.T) {
+	ca := newTestCA(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	acmeFiles := ca.issue("", "acme.example.test")
+	dynamic, err := tls.LoadX509KeyPair(acmeFiles.CertFile, acmeFiles.KeyFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cfg, err := NewServerTLS(ctx, ServerConfig{
+		Certificates: []CertificateFiles{
+			ca.issue("", "a.example.test"),
+			ca.issue("", "*.b.example.test"),
+		},
+		Dynamic: dynamicCert{cert: &dynamic},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tc := range []struct {
+		sni    string
+		protos []string
+		want   string
+	}{
+		{"a.example.test", nil, "a.example.test"},
+		{"x.b.example.test", nil, "*.b.example.test"},
+		{"", nil, "a.example.test"},                                     // no SNI: first certificate
+		{"other.example.test", nil, "acme.example.test"},                // unmatched: dynamic
+		{"a.example.test", []string{"acme-tls/1"}, "acme.example.test"}, // challenge wins over static
+	} {
+		if got := handshake(t, cfg, tc.sni, tc.protos...); len(got) == 0 || got[0] != tc.want {
+			t.Errorf("sni %q protos %v: got %v, want %s", tc.sni, tc.protos, got, tc.want)
+		}
+	}
+}
@@ -0,0 +1,55 @@
+package tlsutil
+
+import (
+	"crypto/tls"
+	"fmt"
+	"strings"
+)
+
+var versions = map[string]uint16{
+	"1.0": tls.VersionTLS10,
+	"1.1": tls.VersionTLS11,
+	"1.2": tls.VersionTLS12,
+	"1.3": tls.VersionTLS13,
+}
+
+// ParseVersion converts "1.2" style version strings (an optional "TLS"
+// prefix is accepted). An empty string returns 0, leaving the Go default.
+func ParseVersion(v string) (uint16, error) {
+	if v == "" {
+		return 0, nil
+	}
+	norm := strings.TrimPrefix(strings.ToUpper(strings.TrimSpace(v)), "TLS")
+	norm = strings.TrimPrefix(norm, "V")
+	if id, ok := versions[norm]; ok {
+		return id, nil
+	}
+	return 0, fmt.Errorf("unknown tls version: %s", v)
+}
+
+// ParseCipherSuites converts IANA cipher suite names (as listed by
+// tls.CipherSuites) into their IDs. Suites Go considers insecure are
+// accepted so that legacy upstreams can still be reached deliberately.
+func ParseCipherSuites(names []string) ([]uint16, error) {
+	if len(names) == 0 {
+		return nil, nil
+	}
+
+	known := make(map[string]uint16)
+	for _, cs := range tls.CipherSuites() {
+		known[cs.Name] = cs.ID
+	}
+	for _, cs := range tls.InsecureCipherSuites() {
+		known[cs.Name] = cs.ID
+	}
+
+	ids := make([]uint16, 0, len(names))
+	for _, name := range names {
+		id, ok := known[strings.TrimSpace(name)]
+		if !ok {
+			return nil, fmt.Errorf("unknown cipher suite: %s", name)
+		}
+		ids = append(ids, id)
+	}
+	return ids, nil
+}
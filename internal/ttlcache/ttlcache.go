@@ -0,0 +1,83 @@
+// Package ttlcache is a size-bounded cache of expiring entries. When it is
+// full the least recently used entry makes room, so memory and the cost of
+// every operation stay constant however many distinct keys arrive.
+package ttlcache
+
+import (
+	"container/list"
+	"sync"
+	"time"
+)
+
+// Cache maps string keys to values until their expiry. It is safe for
+// concurrent use.
+type Cache[V any] struct {
+	size int
+
+	mu    sync.Mutex
+	order *list.List // most recently used first
+	items map[string]*list.Element
+}
+
+type entry[V any] struct {
+	key     string
+	value   V
+	expires time.Time
+}
+
+// New returns a cache holding at most size entries.
+func New[V any](size int) *Cache[V] {
+	return &Cache[V]{
+		size:  max(size, 1),
+		order: list.New(),
+		items: make(map[string]*list.Element),
+	}
+}
+
+// Get returns the value of key unless it is missing or expired.
+func (c *Cache[V]) Get(key string) (V, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	el, ok := c.items[key]
+	if !ok {
+		var zero V
+		return zero, false
+	}
+	e := el.Value.(*entry[V])
+	if !time.Now().Before(e.expires) {
+		c.remove(el)
+		var zero V
+		return zero, false
+	}
+	c.order.MoveToFront(el)
+	return e.value, true
+}
+
+// Set stores value under key until expires, evicting the least recently
+// used entry when the cache is full.
+func (c *Cache[V]) Set(key string, value V, expires time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if el, ok := c.items[key]; ok {
+		e := el.Value.(*entry[V])
+		e.value, e.expires = value, expires
+		c.order.MoveToFront(el)
+		return
+	}
+	if c.order.Len() >= c.size {
+		c.remove(c.order.Back())
+	}
+	c.items[key] = c.order.PushFront(&entry[V]{key: key, value: value, expires: expires})
+}
+
+// Len returns the number of entries, expired ones included.
+func (c *Cache[V]) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.order.Len()
+}
+
+func (c *Cache[V]) remove(el *list.Element) {
+	c.order.Remove(el)
+	delete(c.items, el.Value.(*entry[V]).key)
+}
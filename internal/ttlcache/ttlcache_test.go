@@ -0,0 +1,60 @@
+package ttlcache
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestEvictsLeastRecentlyUsed(t *testing.T) {
+	c := New[int](2)
+	exp := time.Now().Add(time.Minute)
+	c.Set("a", 1, exp)
+	c.Set("b", 2, exp)
+	c.Get("a")
+	c.Set("c", 3, exp)
+
+	if _, ok := c.Get("b"); ok {
+		t.Error("b should have been evicted")
+	}
+	for key, want := range map[string]int{"a": 1, "c": 3} {
+		if v, ok := c.Get(key); !ok || v != want {
+			t.Errorf("Get(%s) = %d, %v", key, v, ok)
+		}
+	}
+	if c.Len() != 2 {
+		t.Errorf("Len = %d", c.Len())
+	}
+}
+
+func TestExpiry(t *testing.T) {
+	c := New[string](10)
+	c.Set("old", "x", time.Now().Add(-time.Second))
+	c.Set("new", "y", time.Now().Add(time.Minute))
+	if _, ok := c.Get("old"); ok {
+		t.Error("expired entry returned")
+	}
+	if c.Len() != 1 {
+		t.Errorf("expired entry not removed, Len = %d", c.Len())
+	}
+
+	// Setting an existing key replaces its value and expiry.
+	c.Set("new", "z", time.Now().Add(-time.Second))
+	if _, ok := c.Get("new"); ok {
+		t.Error("replaced entry kept its old expiry")
+	}
+}
+
+func TestBounded(t *testing.T) {
+	c := New[int](100)
+	exp := time.Now().Add(time.Minute)
+	for i := range 10000 {
+		c.Set(fmt.Sprint(i), i, exp)
+	}
+	if c.Len() != 100 {
+		t.Errorf("Len = %d, want 100", c.Len())
+	}
+	if v, ok := c.Get("9999"); !ok || v != 9999 {
+		t.Errorf("latest entry missing: %d, %v", v, ok)
+	}
+}